@@ -0,0 +1,127 @@
+// Package admin provides an HTTP handler exposing the state of a running
+// pipeline: stream positions, lag and rollback counts, the subscribers of a
+// server, and a trigger for manual checkpoints.
+//
+// The handler can be mounted into an existing mux:
+//
+//	mux.Handle("/admin/", http.StripPrefix("/admin", h))
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/server"
+)
+
+// Handler serves
+//
+//	GET  /status      positions, lag and rollbacks of each stream, and the
+//	                  clients and consumer positions of the server
+//	GET  /healthz     liveness of all streams (see StreamStats.Healthz)
+//	GET  /readyz      catch-up state of all streams (see StreamStats.Readyz)
+//	GET  /metrics     stream stats in the Prometheus text format
+//	POST /checkpoint  calls Checkpoint
+//
+// All fields are optional.
+type Handler struct {
+	Streams    map[string]*events.StreamStats
+	Server     *server.Server
+	Checkpoint func() error
+
+	MaxSilence time.Duration // for /healthz; see StreamStats.Healthz
+	MaxLag     uint64        // for /readyz
+}
+
+// Status is the response of GET /status.
+type Status struct {
+	Streams   []StreamStatus      `json:"streams"`
+	Clients   []server.ClientInfo `json:"clients,omitempty"`
+	Consumers map[string]uint64   `json:"consumers,omitempty"`
+}
+
+type StreamStatus struct {
+	Name string `json:"name"`
+	events.StatsSnapshot
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/status", "/status/":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.serveStatus(w)
+	case "/healthz":
+		Healthz(h.Streams, h.MaxSilence).ServeHTTP(w, r)
+	case "/readyz":
+		Readyz(h.Streams, h.MaxLag).ServeHTTP(w, r)
+	case "/metrics":
+		Metrics(h.Streams).ServeHTTP(w, r)
+	case "/checkpoint", "/checkpoint/":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.serveCheckpoint(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Status returns the current status.
+func (h *Handler) Status() (*Status, error) {
+	st := &Status{
+		Streams: make([]StreamStatus, 0, len(h.Streams)),
+	}
+	for name, stats := range h.Streams {
+		st.Streams = append(st.Streams, StreamStatus{
+			Name:          name,
+			StatsSnapshot: stats.Snapshot(),
+		})
+	}
+	sort.Slice(st.Streams, func(i, j int) bool {
+		return st.Streams[i].Name < st.Streams[j].Name
+	})
+	if h.Server != nil {
+		st.Clients = h.Server.Clients()
+		consumers, err := h.Server.Positions.All()
+		if err != nil {
+			return nil, err
+		}
+		st.Consumers = consumers
+	}
+	return st, nil
+}
+
+func (h *Handler) serveStatus(w http.ResponseWriter) {
+	st, err := h.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, st)
+}
+
+func (h *Handler) serveCheckpoint(w http.ResponseWriter) {
+	if h.Checkpoint == nil {
+		http.Error(w, "checkpointing not configured", http.StatusNotImplemented)
+		return
+	}
+	if err := h.Checkpoint(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Metrics returns a handler writing the stats of the streams in the
+// Prometheus text exposition format.
+func Metrics(streams map[string]*events.StreamStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w, streams)
+	})
+}
+
+type metric struct {
+	name  string
+	typ   string
+	help  string
+	value func(s *events.StatsSnapshot) float64
+}
+
+var streamMetrics = []metric{
+	{"eventlog_next_block", "gauge", "Next block to stream.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.Next)
+	}},
+	{"eventlog_head_block", "gauge", "Latest chain head seen.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.Head)
+	}},
+	{"eventlog_lag_blocks", "gauge", "Blocks between the stream position and the chain head.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.Lag)
+	}},
+	{"eventlog_rate_blocks_per_second", "gauge", "Smoothed rate the stream advances at.", func(s *events.StatsSnapshot) float64 {
+		return s.Rate
+	}},
+	{"eventlog_catchup_eta_seconds", "gauge", "Estimated time to catch up with the chain head; 0 at head or if unknown.", func(s *events.StatsSnapshot) float64 {
+		return s.ETA.Seconds()
+	}},
+	{"eventlog_rollbacks_total", "counter", "Rollbacks sent because of chain reorganizations.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.Rollbacks)
+	}},
+	{"eventlog_tx_missing_total", "counter", "Events whose transaction details the node did not have.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.TxMissing)
+	}},
+	{"eventlog_last_progress_timestamp_seconds", "gauge", "Unix time of the last processed batch.", func(s *events.StatsSnapshot) float64 {
+		if s.LastProgress.IsZero() {
+			return 0
+		}
+		return float64(s.LastProgress.UnixNano()) / 1e9
+	}},
+	{"eventlog_batch_overlap_blocks", "gauge", "Blocks refetched on every poll to detect reorganizations.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.BatchOverlap)
+	}},
+	{"eventlog_reorg_depth_max", "gauge", "Deepest rollback seen, in blocks.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.MaxReorgDepth)
+	}},
+	{"eventlog_suggested_overlap_blocks", "gauge", "Suggested batch overlap given the deepest rollback seen.", func(s *events.StatsSnapshot) float64 {
+		return float64(s.SuggestedOverlap)
+	}},
+}
+
+// reorgDepthBuckets are the upper bounds of the reorg depth histogram.
+var reorgDepthBuckets = []uint64{1, 2, 4, 8, 16, 32, 64, 128}
+
+// WriteMetrics writes the stats of the streams in the Prometheus text
+// exposition format.
+func WriteMetrics(w io.Writer, streams map[string]*events.StreamStats) error {
+	names := make([]string, 0, len(streams))
+	for name := range streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	snaps := make([]events.StatsSnapshot, len(names))
+	for i, name := range names {
+		snaps[i] = streams[name].Snapshot()
+	}
+
+	for _, m := range streamMetrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ); err != nil {
+			return err
+		}
+		for i, name := range names {
+			if _, err := fmt.Fprintf(w, "%s{stream=%q} %g\n", m.name, name, m.value(&snaps[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return writeReorgDepths(w, names, snaps)
+}
+
+func writeReorgDepths(w io.Writer, names []string, snaps []events.StatsSnapshot) error {
+	const name = "eventlog_reorg_depth_blocks"
+	if _, err := fmt.Fprintf(w, "# HELP %s Depth of rollbacks, in blocks.\n# TYPE %s histogram\n", name, name); err != nil {
+		return err
+	}
+	for i, stream := range names {
+		var count, sum uint64
+		for d, n := range snaps[i].ReorgDepths {
+			count += n
+			sum += d * n
+		}
+		for _, le := range reorgDepthBuckets {
+			var n uint64
+			for d, c := range snaps[i].ReorgDepths {
+				if d <= le {
+					n += c
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket{stream=%q,le=\"%d\"} %d\n", name, stream, le, n); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{stream=%q,le=\"+Inf\"} %d\n%s_sum{stream=%q} %d\n%s_count{stream=%q} %d\n",
+			name, stream, count, name, stream, sum, name, stream, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Healthz returns a handler for liveness probes. It responds 503 if any of
+// the streams is stuck.
+func Healthz(streams map[string]*events.StreamStats, maxSilence time.Duration) http.Handler {
+	return probe(streams, func(s *events.StreamStats) error {
+		return s.Healthz(maxSilence)
+	})
+}
+
+// Readyz returns a handler for readiness probes. It responds 503 until all
+// streams are within maxLag blocks of the chain head.
+func Readyz(streams map[string]*events.StreamStats, maxLag uint64) http.Handler {
+	return probe(streams, func(s *events.StreamStats) error {
+		return s.Readyz(maxLag)
+	})
+}
+
+func probe(streams map[string]*events.StreamStats, check func(*events.StreamStats) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(streams))
+		for name := range streams {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var failures []string
+		for _, name := range names {
+			if err := check(streams[name]); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			for _, f := range failures {
+				fmt.Fprintln(w, f)
+			}
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,212 @@
+package aggregate
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+)
+
+// ArgValue returns a value function for TopK reading a decoded numeric
+// argument, e.g. "value".
+func ArgValue(name string) func(*decode.Event) (*big.Int, bool) {
+	return func(de *decode.Event) (*big.Int, bool) {
+		return rules.ToBigInt(de.Args[name])
+	}
+}
+
+// TopEntry is one entry of a TopK: an event, or with TopK.Key the total of
+// a key.
+type TopEntry struct {
+	Key   string
+	Event *decode.Event // nil with TopK.Key
+	Value *big.Int
+}
+
+// topBlock are the values of the events of one block.
+type topBlock struct {
+	number  uint64
+	entries []TopEntry
+}
+
+// TopK is a Sink keeping the K largest values over the last Blocks blocks,
+// e.g. the largest transfers of the last hour. With Key set it finds heavy
+// hitters instead: the keys with the largest total, e.g. the addresses
+// sending the most.
+//
+// The values are kept exactly per block rather than in an approximate
+// structure such as a count-min sketch, which cannot remove the values of
+// the blocks a Rollback drops. Blocks older than the window are kept for
+// another Retain blocks, so that a Rollback moves the window back over
+// them.
+//
+// A TopK is safe for concurrent use, so it can be queried while it is
+// streamed to.
+type TopK struct {
+	K      int
+	Blocks uint64
+
+	// Key groups the values of events by key; nil ranks single events.
+	Key func(de *decode.Event) string
+
+	// Retain is the number of blocks that can be rolled back;
+	// DefaultWindowRetain if zero.
+	Retain uint64
+
+	decoder *decode.Decoder
+	value   func(*decode.Event) (*big.Int, bool)
+
+	mu     sync.RWMutex
+	blocks []topBlock // by increasing block number
+	next   uint64
+}
+
+// NewTopK returns a TopK of the k largest values that value returns for
+// the decoded events of the last blocks blocks. Events for which value
+// returns false are skipped.
+func NewTopK(decoder *decode.Decoder, k int, blocks uint64, value func(*decode.Event) (*big.Int, bool)) *TopK {
+	return &TopK{
+		K:       k,
+		Blocks:  blocks,
+		decoder: decoder,
+		value:   value,
+	}
+}
+
+func (t *TopK) retain() uint64 {
+	if t.Retain == 0 {
+		return DefaultWindowRetain
+	}
+	return t.Retain
+}
+
+func (t *TopK) Append(b *events.Block) error {
+	if t.K <= 0 || t.Blocks == 0 {
+		return fmt.Errorf("got k=%d, blocks=%d; want both > 0", t.K, t.Blocks)
+	}
+	decoded, err := t.decoder.DecodeBlock(b)
+	if err != nil {
+		return err
+	}
+	var entries []TopEntry
+	for _, de := range decoded {
+		v, ok := t.value(de)
+		if !ok {
+			continue
+		}
+		if t.Key != nil {
+			entries = append(entries, TopEntry{Key: t.Key(de), Value: v})
+		} else {
+			entries = append(entries, TopEntry{Event: de, Value: v})
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if b.Number < t.next {
+		return fmt.Errorf("got block %d; want block >= %d", b.Number, t.next)
+	}
+	if len(entries) > 0 {
+		t.blocks = append(t.blocks, topBlock{number: b.Number, entries: entries})
+	}
+	t.setNext(b.Number + 1)
+	return nil
+}
+
+// setNext moves to block n, dropping the blocks beyond the window and
+// Retain.
+func (t *TopK) setNext(n uint64) {
+	t.next = n
+	if n <= t.Blocks+t.retain() {
+		return
+	}
+	keep := n - t.Blocks - t.retain()
+	i := 0
+	for i < len(t.blocks) && t.blocks[i].number < keep {
+		i++
+	}
+	t.blocks = t.blocks[i:]
+}
+
+func (t *TopK) Rollback(n uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n > t.next {
+		return fmt.Errorf("got rollback to %d; want <= %d", n, t.next)
+	}
+	if n+t.retain() < t.next {
+		return fmt.Errorf("got rollback to %d from %d; want at most %d blocks", n, t.next, t.retain())
+	}
+	i := len(t.blocks)
+	for i > 0 && t.blocks[i-1].number >= n {
+		i--
+	}
+	t.blocks = t.blocks[:i]
+	t.next = n
+	return nil
+}
+
+func (t *TopK) SetNext(n uint64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n < t.next {
+		return fmt.Errorf("got SetNext(%d); want >= %d", n, t.next)
+	}
+	t.setNext(n)
+	return nil
+}
+
+// Top returns the up to K largest entries of the last Blocks blocks,
+// largest first. Ties go to the earlier event, or the smaller key.
+func (t *TopK) Top() []TopEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var from uint64
+	if t.next > t.Blocks {
+		from = t.next - t.Blocks
+	}
+
+	var top []TopEntry
+	if t.Key == nil {
+		for _, b := range t.blocks {
+			if b.number >= from {
+				top = append(top, b.entries...)
+			}
+		}
+	} else {
+		totals := make(map[string]*big.Int)
+		for _, b := range t.blocks {
+			if b.number < from {
+				continue
+			}
+			for _, e := range b.entries {
+				s, ok := totals[e.Key]
+				if !ok {
+					s = new(big.Int)
+					totals[e.Key] = s
+				}
+				s.Add(s, e.Value)
+			}
+		}
+		for k, s := range totals {
+			top = append(top, TopEntry{Key: k, Value: s})
+		}
+		sort.Slice(top, func(i, j int) bool { return top[i].Key < top[j].Key })
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Value.Cmp(top[j].Value) > 0 })
+	if len(top) > t.K {
+		top = top[:t.K]
+	}
+	return top
+}
+
+// NextBlock returns the block after the last one applied.
+func (t *TopK) NextBlock() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.next
+}
@@ -0,0 +1,262 @@
+// Package aggregate folds decoded events into derived state, such as token
+// balances, in a way that survives chain reorganizations: every operator
+// keeps what it needs to undo the blocks a Rollback drops.
+package aggregate
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Delta is a change of one key of a View.
+type Delta struct {
+	Key    string
+	Amount *big.Int
+}
+
+// Fold returns the changes a decoded event makes to a View.
+type Fold func(de *decode.Event) []Delta
+
+// TransferBalances is a Fold for ERC-20 Transfer events, keeping the
+// balance of every holder of every token under BalanceKey. Mints and burns
+// move value from and to the zero address, whose balance goes negative.
+func TransferBalances(de *decode.Event) []Delta {
+	if de.Name != "Transfer" {
+		return nil
+	}
+	from, ok1 := de.Args["from"].(common.Address)
+	to, ok2 := de.Args["to"].(common.Address)
+	v, ok3 := de.Args["value"].(*big.Int)
+	if !ok1 || !ok2 || !ok3 {
+		return nil
+	}
+	return []Delta{
+		{Key: BalanceKey(de.Address, from), Amount: new(big.Int).Neg(v)},
+		{Key: BalanceKey(de.Address, to), Amount: v},
+	}
+}
+
+// BalanceKey is the View key of the balance of holder in token.
+func BalanceKey(token, holder common.Address) string {
+	return token.Hex() + "/" + holder.Hex()
+}
+
+// blockDeltas are the changes of one block, kept to undo it.
+type blockDeltas struct {
+	number uint64
+	deltas []Delta
+}
+
+// View is a Sink maintaining a materialized view: a sum per key of the
+// deltas that Fold returns for the decoded events. It keeps the deltas of
+// every block as undo records, to roll back and to answer queries as of
+// past blocks, e.g. for audits. Retain limits the undo records to that
+// many blocks; older blocks can no longer be rolled back or queried.
+//
+// A View is safe for concurrent use, so it can be queried while it is
+// streamed to.
+type View struct {
+	Retain uint64 // blocks of undo records; 0 keeps all
+
+	decoder *decode.Decoder
+	fold    Fold
+
+	mu      sync.RWMutex
+	state   map[string]*big.Int
+	undo    []blockDeltas // by increasing block number
+	next    uint64
+	horizon uint64 // earliest block the view can be queried as of
+}
+
+func NewView(decoder *decode.Decoder, fold Fold) *View {
+	return &View{
+		decoder: decoder,
+		fold:    fold,
+		state:   make(map[string]*big.Int),
+	}
+}
+
+func (v *View) Append(b *events.Block) error {
+	decoded, err := v.decoder.DecodeBlock(b)
+	if err != nil {
+		return err
+	}
+	var deltas []Delta
+	for _, de := range decoded {
+		deltas = append(deltas, v.fold(de)...)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if b.Number < v.next {
+		return fmt.Errorf("got block %d; want block >= %d", b.Number, v.next)
+	}
+	for _, d := range deltas {
+		v.add(d.Key, d.Amount)
+	}
+	if len(deltas) > 0 {
+		v.undo = append(v.undo, blockDeltas{number: b.Number, deltas: deltas})
+	}
+	v.setNext(b.Number + 1)
+	return nil
+}
+
+func (v *View) add(key string, x *big.Int) {
+	s, ok := v.state[key]
+	if !ok {
+		s = new(big.Int)
+		v.state[key] = s
+	}
+	s.Add(s, x)
+	if s.Sign() == 0 {
+		delete(v.state, key)
+	}
+}
+
+func (v *View) sub(key string, x *big.Int) {
+	v.add(key, new(big.Int).Neg(x))
+}
+
+// setNext moves the view to block n, dropping undo records beyond Retain.
+func (v *View) setNext(n uint64) {
+	v.next = n
+	if v.Retain == 0 || n <= v.Retain {
+		return
+	}
+	keep := n - v.Retain
+	i := 0
+	for i < len(v.undo) && v.undo[i].number < keep {
+		i++
+	}
+	v.undo = v.undo[i:]
+	if keep-1 > v.horizon {
+		v.horizon = keep - 1
+	}
+}
+
+func (v *View) Rollback(n uint64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if n > v.next {
+		return fmt.Errorf("got rollback to %d; want <= %d", n, v.next)
+	}
+	if v.horizon > 0 && n <= v.horizon {
+		return fmt.Errorf("got rollback to %d; undo records start after block %d", n, v.horizon)
+	}
+	for len(v.undo) > 0 && v.undo[len(v.undo)-1].number >= n {
+		u := v.undo[len(v.undo)-1]
+		for _, d := range u.deltas {
+			v.sub(d.Key, d.Amount)
+		}
+		v.undo = v.undo[:len(v.undo)-1]
+	}
+	v.next = n
+	return nil
+}
+
+func (v *View) SetNext(n uint64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if n < v.next {
+		return fmt.Errorf("got SetNext(%d); want >= %d", n, v.next)
+	}
+	v.setNext(n)
+	return nil
+}
+
+// NextBlock returns the block after the last one applied.
+func (v *View) NextBlock() uint64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.next
+}
+
+// Get returns the current value of a key; zero if it has none.
+func (v *View) Get(key string) *big.Int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if s, ok := v.state[key]; ok {
+		return new(big.Int).Set(s)
+	}
+	return new(big.Int)
+}
+
+// GetAt returns the value of a key as of block n, i.e. after the events of
+// blocks up to n. It fails for blocks older than the undo records.
+func (v *View) GetAt(key string, n uint64) (*big.Int, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if err := v.checkAt(n); err != nil {
+		return nil, err
+	}
+	x := new(big.Int)
+	if s, ok := v.state[key]; ok {
+		x.Set(s)
+	}
+	for i := len(v.undo) - 1; i >= 0 && v.undo[i].number > n; i-- {
+		for _, d := range v.undo[i].deltas {
+			if d.Key == key {
+				x.Sub(x, d.Amount)
+			}
+		}
+	}
+	return x, nil
+}
+
+// SnapshotAt returns all non-zero values as of block n.
+func (v *View) SnapshotAt(n uint64) (map[string]*big.Int, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if err := v.checkAt(n); err != nil {
+		return nil, err
+	}
+	snap := make(map[string]*big.Int, len(v.state))
+	for k, s := range v.state {
+		snap[k] = new(big.Int).Set(s)
+	}
+	for i := len(v.undo) - 1; i >= 0 && v.undo[i].number > n; i-- {
+		for _, d := range v.undo[i].deltas {
+			x, ok := snap[d.Key]
+			if !ok {
+				x = new(big.Int)
+				snap[d.Key] = x
+			}
+			x.Sub(x, d.Amount)
+		}
+	}
+	for k, x := range snap {
+		if x.Sign() == 0 {
+			delete(snap, k)
+		}
+	}
+	return snap, nil
+}
+
+// Keys returns the keys with a non-zero current value, sorted.
+func (v *View) Keys() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	keys := make([]string, 0, len(v.state))
+	for k := range v.state {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *View) checkAt(n uint64) error {
+	if n < v.horizon {
+		return fmt.Errorf("got block %d; undo records only reach back to block %d", n, v.horizon)
+	}
+	if n+1 > v.next {
+		return fmt.Errorf("got block %d; the view is at block %d", n, v.next)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package aggregate
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+)
+
+// DefaultWindowRetain is the default number of blocks a Window can roll
+// back.
+const DefaultWindowRetain = 64
+
+// WindowResult is the aggregate of the matching events of one window.
+type WindowResult struct {
+	Start uint64 // first block of the window, or unix time with ByTime
+	End   uint64 // first block after the window, or unix time with ByTime
+
+	Count    uint64   // matching events
+	Sum      *big.Int // of the SumArg of the events; nil without SumArg
+	Distinct int      // distinct values of the DistinctArg of the events
+
+	// Retracted is set when a rollback reopened a window whose result
+	// was emitted before. The window is emitted again when it closes.
+	Retracted bool
+}
+
+// Window is a Sink computing aggregates over windows of blocks, or of block
+// time with ByTime, e.g. transfers per hour. Windows are Size long and
+// start every Slide; Slide zero or equal to Size makes tumbling windows,
+// smaller ones sliding windows. The first window starts at or after the
+// first block streamed.
+//
+// The result of a window is emitted once the stream passes its end. By
+// block, the window ends with its last block or a SetNext beyond it; by
+// time, with the first block at or after its end time, as SetNext carries
+// no time. A Rollback that reaches into emitted windows retracts them and
+// recomputes them from the remaining and new blocks, so the blocks of the
+// last Retain blocks' windows are kept.
+type Window struct {
+	Size   uint64
+	Slide  uint64
+	ByTime bool // needs block times; see ChainStreamer.BlockTimestamps
+
+	// Match selects the events to aggregate; nil matches all decoded
+	// events.
+	Match func(*decode.Event) bool
+
+	// SumArg is the decoded argument to sum, e.g. "value", and
+	// DistinctArg the one whose distinct values are counted, e.g.
+	// "from". Both are optional.
+	SumArg      string
+	DistinctArg string
+
+	// Retain is the number of blocks that can be rolled back;
+	// DefaultWindowRetain if zero.
+	Retain uint64
+
+	decoder *decode.Decoder
+	emit    func(*WindowResult) error
+
+	records    []windowRecord // by increasing block number
+	closed     []closedWindow // emitted windows that can still be reopened
+	nextWindow uint64         // index of the first window not emitted
+	started    bool
+	next       uint64
+}
+
+// windowRecord is the aggregate of one block.
+type windowRecord struct {
+	number   uint64
+	pos      uint64 // block number, or time
+	count    uint64
+	sum      *big.Int
+	distinct map[string]bool
+}
+
+// closedWindow is an emitted window, and the block that closed it.
+type closedWindow struct {
+	index    uint64
+	closedAt uint64
+}
+
+// NewWindow returns tumbling windows of size blocks, calling emit with the
+// result of every window. Set the fields to change the defaults.
+func NewWindow(decoder *decode.Decoder, size uint64, emit func(*WindowResult) error) *Window {
+	return &Window{
+		Size:    size,
+		decoder: decoder,
+		emit:    emit,
+	}
+}
+
+func (w *Window) slide() uint64 {
+	if w.Slide == 0 {
+		return w.Size
+	}
+	return w.Slide
+}
+
+func (w *Window) retain() uint64 {
+	if w.Retain == 0 {
+		return DefaultWindowRetain
+	}
+	return w.Retain
+}
+
+func (w *Window) bounds(k uint64) (uint64, uint64) {
+	start := k * w.slide()
+	return start, start + w.Size
+}
+
+func (w *Window) start(pos uint64) error {
+	if w.started {
+		return nil
+	}
+	if w.Size == 0 || w.Slide > w.Size {
+		return fmt.Errorf("got window size %d, slide %d; want 0 < slide <= size", w.Size, w.Slide)
+	}
+	s := w.slide()
+	w.nextWindow = (pos + s - 1) / s
+	w.started = true
+	return nil
+}
+
+func (w *Window) Append(b *events.Block) error {
+	pos := b.Number
+	if w.ByTime {
+		if b.Time == 0 {
+			return fmt.Errorf("block %d has no time; enable block timestamps", b.Number)
+		}
+		pos = b.Time
+	}
+	if err := w.start(pos); err != nil {
+		return err
+	}
+	if b.Number < w.next {
+		return fmt.Errorf("got block %d; want block >= %d", b.Number, w.next)
+	}
+	decoded, err := w.decoder.DecodeBlock(b)
+	if err != nil {
+		return err
+	}
+	r := windowRecord{number: b.Number, pos: pos}
+	for _, de := range decoded {
+		if w.Match != nil && !w.Match(de) {
+			continue
+		}
+		r.count++
+		if w.SumArg != "" {
+			if x, ok := rules.ToBigInt(de.Args[w.SumArg]); ok {
+				if r.sum == nil {
+					r.sum = new(big.Int)
+				}
+				r.sum.Add(r.sum, x)
+			}
+		}
+		if w.DistinctArg != "" {
+			if v, ok := de.Args[w.DistinctArg]; ok {
+				if r.distinct == nil {
+					r.distinct = make(map[string]bool)
+				}
+				r.distinct[fmt.Sprint(v)] = true
+			}
+		}
+	}
+	w.records = append(w.records, r)
+	w.next = b.Number + 1
+	if w.ByTime {
+		return w.advance(b.Time, b.Number)
+	}
+	return w.advance(b.Number+1, b.Number)
+}
+
+func (w *Window) SetNext(n uint64) error {
+	if w.ByTime {
+		w.next = n
+		return nil
+	}
+	if err := w.start(n); err != nil {
+		return err
+	}
+	if n < w.next {
+		return fmt.Errorf("got SetNext(%d); want >= %d", n, w.next)
+	}
+	w.next = n
+	if n == 0 {
+		return nil
+	}
+	return w.advance(n, n-1)
+}
+
+// advance emits the windows ending at or before pos, closed by block
+// closedAt.
+func (w *Window) advance(pos, closedAt uint64) error {
+	for {
+		start, end := w.bounds(w.nextWindow)
+		if end > pos {
+			break
+		}
+		if err := w.emit(w.result(start, end)); err != nil {
+			return err
+		}
+		w.closed = append(w.closed, closedWindow{index: w.nextWindow, closedAt: closedAt})
+		w.nextWindow++
+	}
+	w.prune()
+	return nil
+}
+
+func (w *Window) result(start, end uint64) *WindowResult {
+	res := &WindowResult{Start: start, End: end}
+	distinct := make(map[string]bool)
+	for _, r := range w.records {
+		if r.pos < start || r.pos >= end {
+			continue
+		}
+		res.Count += r.count
+		if r.sum != nil {
+			if res.Sum == nil {
+				res.Sum = new(big.Int)
+			}
+			res.Sum.Add(res.Sum, r.sum)
+		}
+		for v := range r.distinct {
+			distinct[v] = true
+		}
+	}
+	if w.SumArg != "" && res.Sum == nil {
+		res.Sum = new(big.Int)
+	}
+	res.Distinct = len(distinct)
+	return res
+}
+
+// prune drops the records no window needs, once they are beyond Retain.
+func (w *Window) prune() {
+	var minNumber uint64
+	if w.next > w.retain() {
+		minNumber = w.next - w.retain()
+	}
+	i := 0
+	for i < len(w.closed) && w.closed[i].closedAt < minNumber {
+		i++
+	}
+	w.closed = w.closed[i:]
+
+	minPos, _ := w.bounds(w.nextWindow)
+	if len(w.closed) > 0 {
+		minPos, _ = w.bounds(w.closed[0].index)
+	}
+	i = 0
+	for i < len(w.records) && w.records[i].pos < minPos && w.records[i].number < minNumber {
+		i++
+	}
+	w.records = w.records[i:]
+}
+
+func (w *Window) Rollback(n uint64) error {
+	if n > w.next {
+		return fmt.Errorf("got rollback to %d; want <= %d", n, w.next)
+	}
+	if n+w.retain() < w.next {
+		return fmt.Errorf("got rollback to %d from %d; want at most %d blocks", n, w.next, w.retain())
+	}
+	i := len(w.records)
+	for i > 0 && w.records[i-1].number >= n {
+		i--
+	}
+	w.records = w.records[:i]
+	w.next = n
+	for len(w.closed) > 0 && w.closed[len(w.closed)-1].closedAt >= n {
+		c := w.closed[len(w.closed)-1]
+		w.closed = w.closed[:len(w.closed)-1]
+		start, end := w.bounds(c.index)
+		if err := w.emit(&WindowResult{Start: start, End: end, Retracted: true}); err != nil {
+			return err
+		}
+		w.nextWindow = c.index
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package arrowexport writes events as Apache Arrow record batches, in the
+// IPC stream or file (Feather v2) format, to hand them to analytics tools
+// such as pyarrow and pandas without conversion:
+//
+//	import pyarrow.feather
+//	df = pyarrow.feather.read_table("events.arrow").to_pandas()
+package arrowexport
+
+import (
+	"io"
+
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+const DefaultBatchSize = 10000 // events per record batch
+
+// Schema is the schema of the exported records, one row per event. Hashes
+// and addresses are hex strings; the topic and transaction columns are null
+// where absent.
+var Schema = arrow.NewSchema([]arrow.Field{
+	{Name: "block_number", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "block_hash", Type: arrow.BinaryTypes.String},
+	{Name: "log_index", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "address", Type: arrow.BinaryTypes.String},
+	{Name: "topic0", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "topic1", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "topic2", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "topic3", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "data", Type: arrow.BinaryTypes.Binary},
+	{Name: "tx_hash", Type: arrow.BinaryTypes.String},
+	{Name: "tx_index", Type: arrow.PrimitiveTypes.Uint64},
+	{Name: "tx_from", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "tx_value", Type: arrow.BinaryTypes.String, Nullable: true}, // decimal
+	{Name: "tx_gas", Type: arrow.PrimitiveTypes.Uint64, Nullable: true},
+}, nil)
+
+type recordWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// Writer writes events in record batches of BatchSize events.
+type Writer struct {
+	BatchSize int
+
+	w recordWriter
+	b *array.RecordBuilder
+	n int
+}
+
+func newWriter(w recordWriter) *Writer {
+	return &Writer{
+		BatchSize: DefaultBatchSize,
+		w:         w,
+		b:         array.NewRecordBuilder(memory.DefaultAllocator, Schema),
+	}
+}
+
+// NewStreamWriter returns a Writer using the Arrow IPC stream format.
+func NewStreamWriter(w io.Writer) *Writer {
+	return newWriter(ipc.NewWriter(w, ipc.WithSchema(Schema)))
+}
+
+// NewFileWriter returns a Writer using the Arrow IPC file format, also known
+// as Feather v2.
+func NewFileWriter(w io.WriteSeeker) (*Writer, error) {
+	fw, err := ipc.NewFileWriter(w, ipc.WithSchema(Schema))
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(fw), nil
+}
+
+// WriteBlock adds the events of a block.
+func (w *Writer) WriteBlock(b *events.Block) error {
+	for i := range b.Events {
+		w.add(&b.Events[i])
+		w.n++
+		if w.n >= w.BatchSize {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) add(e *events.Event) {
+	f := w.b.Fields()
+	f[0].(*array.Uint64Builder).Append(e.BlockNumber)
+	f[1].(*array.StringBuilder).Append(e.BlockHash.Hex())
+	f[2].(*array.Uint64Builder).Append(e.Index)
+	f[3].(*array.StringBuilder).Append(e.Address.Hex())
+	for i := 0; i < 4; i++ {
+		if i < len(e.Topics) {
+			f[4+i].(*array.StringBuilder).Append(e.Topics[i].Hex())
+		} else {
+			f[4+i].AppendNull()
+		}
+	}
+	f[8].(*array.BinaryBuilder).Append(e.Data)
+	f[9].(*array.StringBuilder).Append(e.TxHash.Hex())
+	f[10].(*array.Uint64Builder).Append(e.TxIndex)
+	if e.TxValue != nil {
+		f[11].(*array.StringBuilder).Append(e.TxFrom.Hex())
+		f[12].(*array.StringBuilder).Append(e.TxValue.String())
+		f[13].(*array.Uint64Builder).Append(e.TxGas)
+	} else {
+		f[11].AppendNull()
+		f[12].AppendNull()
+		f[13].AppendNull()
+	}
+}
+
+func (w *Writer) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	rec := w.b.NewRecord()
+	defer rec.Release()
+	w.n = 0
+	return w.w.Write(rec)
+}
+
+// Close writes the last batch and finishes the output. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	defer w.b.Release()
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
+
+// WriteEventLog writes all events of an eventlog.
+func WriteEventLog(w *Writer, l events.EventLog) error {
+	done := make(chan struct{})
+	defer close(done)
+	sub, err := l.Stream(done, l.FirstBlock())
+	if err != nil {
+		return err
+	}
+	for m := range sub.C {
+		if m.Action != events.Append {
+			continue
+		}
+		if err := w.WriteBlock(m.Block); err != nil {
+			return err
+		}
+	}
+	return <-sub.Err
+}
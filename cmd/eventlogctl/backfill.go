@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+)
+
+var backfillCommand = &command{
+	name:  "backfill",
+	short: "fetch a historical block range into eventlog files",
+	run:   runBackfill,
+}
+
+// backfillState is stored in the progress file of the output directory.
+type backfillState struct {
+	From  uint64 `json:"from"`
+	To    uint64 `json:"to"`
+	Chunk uint64 `json:"chunk"`
+	Next  uint64 `json:"next"` // first block of the next chunk to fetch
+
+	// NewestFirst fetches the chunks from the newest backwards; the blocks
+	// from Next up to High are left to fetch.
+	NewestFirst bool   `json:"newest_first,omitempty"`
+	High        uint64 `json:"high,omitempty"`
+}
+
+const backfillStateFile = "backfill.json"
+
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	node := fs.String("node", "", "Ethereum JSON-RPC node url")
+	var addresses listFlag
+	fs.Var(&addresses, "address", "Contract address to fetch; may be repeated")
+	from := fs.Uint64("from", 0, "First block")
+	to := fs.Uint64("to", 0, "Last block (inclusive)")
+	out := fs.String("out", "", "Output directory; one eventlog file is written per chunk")
+	chunk := fs.Uint64("chunk", 100000, "Blocks per output file")
+	batch := fs.Uint64("batch", events.DefaultFetchBatchSize, "Blocks per getLogs call")
+	rate := fs.Float64("rate", 0, "Maximum getLogs calls per second; 0 is unlimited")
+	maxCalls := fs.Uint64("max-calls", 0, "Stop after this many RPC calls per hour; 0 is unlimited")
+	maxUnits := fs.Uint64("max-units", 0, "Stop after this many estimated provider compute units per hour; 0 is unlimited")
+	tx := fs.Bool("tx", false, "Fetch transaction details")
+	deployed := fs.Bool("deployed", true, "Start no earlier than the deployment of the contracts (needs an archive node)")
+	newest := fs.Bool("newest-first", false, "Fetch the chunks from the newest backwards, so that recent activity is available first")
+	verbose := fs.Bool("v", false, "Log progress messages instead of a progress bar")
+	applyProfile := profileFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
+	if *node == "" || *out == "" {
+		return fmt.Errorf("missing -node or -out")
+	}
+	if *to < *from || *chunk == 0 {
+		return fmt.Errorf("invalid range %d..%d with chunk %d", *from, *to, *chunk)
+	}
+	quietLog(*verbose)
+
+	var filter ethereum.FilterQuery
+	for _, a := range addresses {
+		if !common.IsHexAddress(a) {
+			return fmt.Errorf("invalid address %q", a)
+		}
+		filter.Addresses = append(filter.Addresses, common.HexToAddress(a))
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	state := &backfillState{From: *from, To: *to, Chunk: *chunk, Next: *from, NewestFirst: *newest}
+	if *newest {
+		state.High = *to + 1
+	}
+	statePath := filepath.Join(*out, backfillStateFile)
+	if bs, err := os.ReadFile(statePath); err == nil {
+		var prev backfillState
+		if err := json.Unmarshal(bs, &prev); err != nil {
+			return fmt.Errorf("%s: %w", statePath, err)
+		}
+		if prev.From != state.From || prev.To != state.To || prev.Chunk != state.Chunk || prev.NewestFirst != state.NewestFirst {
+			return fmt.Errorf("%s is for range %d..%d with chunk %d, newest first %t; use another -out directory", statePath, prev.From, prev.To, prev.Chunk, prev.NewestFirst)
+		}
+		state = &prev
+		if state.NewestFirst {
+			fmt.Fprintf(os.Stderr, "resuming below block %d\n", state.High)
+		} else {
+			fmt.Fprintf(os.Stderr, "resuming at block %d\n", state.Next)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	ctx := context.Background()
+	rpc, err := ethrpc.Dial(ctx, *node)
+	if err != nil {
+		return err
+	}
+	defer rpc.Close()
+	var client events.Client = rpc
+	if *maxCalls > 0 || *maxUnits > 0 {
+		client = (&events.Budget{MaxCalls: *maxCalls, MaxUnits: *maxUnits}).Client(client)
+	}
+
+	if *deployed && state.Next == state.From {
+		next, err := events.ClampToDeployment(ctx, client, filter, state.Next)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "not skipping to deployment block: %v\n", err)
+		} else if next > state.Next {
+			fmt.Fprintf(os.Stderr, "skipping to deployment block %d\n", next)
+			state.Next = next
+		}
+	}
+
+	bar := &progressBar{total: state.To - state.From + 1, quiet: *verbose}
+	bf := &events.Backfill{
+		Ctx:            ctx,
+		Client:         client,
+		Filter:         filter,
+		FetchBatchSize: *batch,
+		FetchTxDetails: *tx,
+		Progress: func(p events.BackfillProgress) {
+			if state.NewestFirst {
+				left := state.High - state.Next - (p.Next - p.From)
+				bar.update(state.To+1-state.High+p.Next-p.From, events.ETA(left, p.Rate))
+				return
+			}
+			bar.update(p.Next-state.From, events.ETA(state.To+1-p.Next, p.Rate))
+		},
+	}
+	if *rate > 0 {
+		bf.Interval = time.Duration(float64(time.Second) / *rate)
+	}
+
+	done := interrupted()
+	for state.Next <= state.To && (!state.NewestFirst || state.Next < state.High) {
+		select {
+		case <-done:
+			bar.finish()
+			return events.Canceled
+		default:
+		}
+		start, end := state.Next, state.Next+state.Chunk-1
+		if end > state.To {
+			end = state.To
+		}
+		if state.NewestFirst {
+			// The chunks keep the boundaries of a forward backfill.
+			start, end = state.Next+(state.High-1-state.Next)/state.Chunk*state.Chunk, state.High-1
+		}
+		slice, err := bf.Fetch(start, end)
+		if err != nil {
+			bar.finish()
+			return err
+		}
+		l := events.InMemoryEventLogFromBlockSlice(slice, filter)
+		fn := filepath.Join(*out, fmt.Sprintf("eventlog-%09d-%09d.pb", slice.Start, slice.End))
+		if err := events.SaveCheckpoint(l, fn); err != nil {
+			bar.finish()
+			return err
+		}
+		if state.NewestFirst {
+			state.High = start
+		} else {
+			state.Next = end + 1
+		}
+		if err := writeJSONFile(statePath, state); err != nil {
+			bar.finish()
+			return err
+		}
+	}
+	bar.finish()
+	return nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// progressBar draws a progress bar on stderr.
+type progressBar struct {
+	total uint64
+	quiet bool
+	last  time.Time
+	drawn bool
+}
+
+const progressBarWidth = 40
+
+// update draws the bar with done of total blocks, and the estimated time
+// left if not zero.
+func (b *progressBar) update(done uint64, eta time.Duration) {
+	if b.quiet || (time.Since(b.last) < 100*time.Millisecond && done < b.total) {
+		return
+	}
+	b.last = time.Now()
+	frac := float64(done) / float64(b.total)
+	if frac > 1 {
+		frac = 1
+	}
+	filled := int(frac * progressBarWidth)
+	left := ""
+	if eta > 0 && done < b.total {
+		left = fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %5.1f%% %d/%d blocks%s\x1b[K",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+		100*frac, done, b.total, left)
+	b.drawn = true
+}
+
+func (b *progressBar) finish() {
+	if b.drawn {
+		fmt.Fprintln(os.Stderr)
+	}
+}
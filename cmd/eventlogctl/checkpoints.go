@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+var mergeCommand = &command{
+	name:  "merge",
+	short: "merge sequential eventlog files into one",
+	run:   runMerge,
+}
+
+var compactCommand = &command{
+	name:  "compact",
+	short: "drop old blocks from an eventlog file and re-encode it",
+	run:   runCompact,
+}
+
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl merge -out merged.pb a.pb b.pb ...\n\n"+
+			"Where files overlap, the blocks of the file ending later win, so periodic\n"+
+			"checkpoints of one stream can be merged in any order.\n\n")
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "Output file; gzip compressed if it ends in .gz")
+	before := fs.Uint64("before", 0, "Drop blocks before this block number")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	logs := make([]*events.InMemoryEventLog, fs.NArg())
+	for i, fn := range fs.Args() {
+		l, err := events.LoadCheckpoint(fn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+		logs[i] = l
+	}
+	merged, err := mergeEventLogs(logs)
+	if err != nil {
+		return err
+	}
+	if err := merged.Prune(*before); err != nil {
+		return err
+	}
+	fmt.Printf("merged %d files into %s, blocks %d:%d\n", len(logs), *out, merged.FirstBlock(), merged.NextBlock())
+	return events.SaveCheckpoint(merged, *out)
+}
+
+// mergeEventLogs merges eventlogs with the same filter. They must not leave
+// gaps between them.
+func mergeEventLogs(logs []*events.InMemoryEventLog) (*events.InMemoryEventLog, error) {
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].FirstBlock() == logs[j].FirstBlock() {
+			return logs[i].NextBlock() < logs[j].NextBlock()
+		}
+		return logs[i].FirstBlock() < logs[j].FirstBlock()
+	})
+	filter := logs[0].Filter()
+	merged := events.NewInMemoryEventLog(logs[0].FirstBlock(), filter)
+	for _, l := range logs {
+		lf := l.Filter()
+		if !proto.Equal(events.FilterQueryToProto(&filter), events.FilterQueryToProto(&lf)) {
+			return nil, fmt.Errorf("eventlog %d:%d has a different filter", l.FirstBlock(), l.NextBlock())
+		}
+		if l.FirstBlock() > merged.NextBlock() {
+			return nil, fmt.Errorf("gap between blocks %d and %d", merged.NextBlock(), l.FirstBlock())
+		}
+		if l.NextBlock() <= merged.NextBlock() {
+			// Already covered by a file ending later.
+			continue
+		}
+		if err := merged.Rollback(l.FirstBlock()); err != nil {
+			return nil, err
+		}
+		done := make(chan struct{})
+		sub, err := l.Stream(done, l.FirstBlock())
+		if err != nil {
+			return nil, err
+		}
+		err = events.Drain(sub, merged)
+		close(done)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+func runCompact(args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl compact [flags] in.pb\n")
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "Output file, gzip compressed if it ends in .gz; defaults to rewriting the input")
+	before := fs.Uint64("before", 0, "Drop blocks before this block number")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	in := fs.Arg(0)
+	if *out == "" {
+		*out = in
+	}
+	l, err := events.LoadCheckpoint(in)
+	if err != nil {
+		return err
+	}
+	if err := l.Prune(*before); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s, blocks %d:%d\n", *out, l.FirstBlock(), l.NextBlock())
+	return events.SaveCheckpoint(l, *out)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+var coverageCommand = &command{
+	name:  "coverage",
+	short: "show the block ranges and gaps of eventlog files",
+	run:   runCoverage,
+}
+
+func runCoverage(args []string) error {
+	fs := flag.NewFlagSet("coverage", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl coverage [-from n -to n] [-out manifest.pb] a.pb b.pb ...\n\n"+
+			"Prints the blocks each file covers and the gaps of all files together.\n"+
+			"With -from and -to, prints the files to merge for that range instead.\n\n")
+		fs.PrintDefaults()
+	}
+	from := fs.Uint64("from", 0, "First block of the range to assemble")
+	to := fs.Uint64("to", 0, "Block after the range to assemble")
+	out := fs.String("out", "", "Write the RangeManifest proto of all files to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	ms := make([]*events.RangeManifest, fs.NArg())
+	for i, fn := range fs.Args() {
+		l, err := events.LoadCheckpoint(fn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+		ms[i] = events.ManifestOf(l, fn)
+	}
+	all, err := events.MergeManifests(ms...)
+	if err != nil {
+		return err
+	}
+
+	if *to > *from {
+		picked, gaps := events.AssembleCoverage(ms, *from, *to)
+		for _, m := range picked {
+			fmt.Printf("%s\t%d:%d\n", m.Files[0], m.Start, m.End)
+		}
+		for _, g := range gaps {
+			fmt.Printf("gap\t%v\n", g)
+		}
+		if len(gaps) > 0 {
+			return fmt.Errorf("blocks %d:%d are not fully covered", *from, *to)
+		}
+	} else {
+		for _, m := range ms {
+			fmt.Printf("%s\t%d:%d\n", m.Files[0], m.Start, m.End)
+		}
+		fmt.Printf("covered")
+		for _, r := range all.Ranges {
+			fmt.Printf(" %v", r)
+		}
+		fmt.Println()
+		for _, g := range all.Gaps() {
+			fmt.Printf("gap\t%v\n", g)
+		}
+	}
+
+	if *out != "" {
+		bs, err := proto.Marshal(events.RangeManifestToProto(all))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*out, bs, 0644)
+	}
+	return nil
+}
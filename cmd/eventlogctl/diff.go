@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+)
+
+var diffCommand = &command{
+	name:  "diff",
+	short: "compare two eventlog files, or a file with the chain",
+	run:   runDiff,
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl diff [flags] a.pb b.pb\n       eventlogctl diff -node url a.pb\n")
+		fs.PrintDefaults()
+	}
+	node := fs.String("node", "", "Compare the file with the chain at this JSON-RPC node url")
+	verbose := fs.Bool("v", false, "Log fetch progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	quietLog(*verbose)
+
+	var a, b *events.InMemoryEventLog
+	var err error
+	switch {
+	case fs.NArg() == 2 && *node == "":
+		if a, err = events.LoadCheckpoint(fs.Arg(0)); err != nil {
+			return err
+		}
+		if b, err = events.LoadCheckpoint(fs.Arg(1)); err != nil {
+			return err
+		}
+	case fs.NArg() == 1 && *node != "":
+		if a, err = events.LoadCheckpoint(fs.Arg(0)); err != nil {
+			return err
+		}
+		if b, err = fetchLike(*node, a); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	n, err := diffEventLogs(os.Stdout, a, b)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%d differences", n)
+	}
+	fmt.Println("no differences")
+	return nil
+}
+
+// fetchLike fetches the block range and filter of an eventlog from the chain.
+func fetchLike(node string, l *events.InMemoryEventLog) (*events.InMemoryEventLog, error) {
+	if l.NextBlock() == l.FirstBlock() {
+		return l, nil
+	}
+	ctx := context.Background()
+	client, err := ethrpc.Dial(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	bf := &events.Backfill{
+		Ctx:    ctx,
+		Client: client,
+		Filter: l.Filter(),
+	}
+	slice, err := bf.Fetch(l.FirstBlock(), l.NextBlock()-1)
+	if err != nil {
+		return nil, err
+	}
+	return events.InMemoryEventLogFromBlockSlice(slice, l.Filter()), nil
+}
+
+// diffEventLogs writes the differences of two eventlogs in the block range
+// they both cover, and returns their number.
+func diffEventLogs(w io.Writer, a, b *events.InMemoryEventLog) (int, error) {
+	start, end := a.FirstBlock(), a.NextBlock()
+	if b.FirstBlock() > start {
+		start = b.FirstBlock()
+	}
+	if b.NextBlock() < end {
+		end = b.NextBlock()
+	}
+	fmt.Fprintf(w, "a covers %d:%d, b covers %d:%d\n", a.FirstBlock(), a.NextBlock(), b.FirstBlock(), b.NextBlock())
+	if start >= end {
+		fmt.Fprintln(w, "no overlap")
+		return 1, nil
+	}
+	fmt.Fprintf(w, "comparing %d:%d\n", start, end)
+
+	diffs := events.Diff(a.BlockSlice(), b.BlockSlice())
+	for _, d := range diffs {
+		fmt.Fprintln(w, d)
+	}
+	return len(diffs), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jcjlcodes/eth-eventlog/arrowexport"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+var exportCommand = &command{
+	name:  "export",
+	short: "export eventlog files to Apache Arrow or JSON lines",
+	run:   runExport,
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl export [flags] in.pb...\n")
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "Output file; - writes the stream format to stdout")
+	format := fs.String("format", "arrow", "Output format: arrow, or jsonl for one JSON block per line")
+	bytesFormat := fs.String("bytes", "hex", "Encoding of byte fields in jsonl output: hex, or base64 for protobuf JSON")
+	stream := fs.Bool("stream", false, "Write the Arrow IPC stream format instead of the file (Feather) format")
+	batch := fs.Int("batch", arrowexport.DefaultBatchSize, "Events per record batch")
+	applyProfile := profileFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *out == "" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	switch *format {
+	case "arrow":
+	case "jsonl":
+		enc, err := events.ParseBytesEncoding(*bytesFormat)
+		if err != nil {
+			return err
+		}
+		return exportJSONLines(*out, fs.Args(), enc)
+	default:
+		return fmt.Errorf("unknown format %q; want arrow or jsonl", *format)
+	}
+
+	var w *arrowexport.Writer
+	if *out == "-" {
+		w = arrowexport.NewStreamWriter(os.Stdout)
+	} else {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if *stream {
+			w = arrowexport.NewStreamWriter(f)
+		} else if w, err = arrowexport.NewFileWriter(f); err != nil {
+			return err
+		}
+	}
+	w.BatchSize = *batch
+
+	for _, in := range fs.Args() {
+		l, err := events.LoadCheckpoint(in)
+		if err != nil {
+			return err
+		}
+		if err := arrowexport.WriteEventLog(w, l); err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+	}
+	return w.Close()
+}
+
+// exportJSONLines writes the blocks of the inputs as JSON lines of Message
+// protos, readable by protobuf JSON consumers with base64 bytes.
+func exportJSONLines(out string, ins []string, enc events.BytesEncoding) error {
+	var w io.Writer = os.Stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	for _, in := range ins {
+		l, err := events.LoadCheckpoint(in)
+		if err != nil {
+			return err
+		}
+		for _, b := range l.BlockSlice().Blocks {
+			bs, err := events.MarshalProtoJSON(events.MessageToProto(&events.Message{Action: events.Append, Block: b}), enc)
+			if err != nil {
+				return fmt.Errorf("%s: %w", in, err)
+			}
+			bw.Write(bs)
+			bw.WriteByte('\n')
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
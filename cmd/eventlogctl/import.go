@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/jcjlcodes/eth-eventlog/sinks"
+)
+
+var importCommand = &command{
+	name:  "import",
+	short: "load delimited proto streams into SQLite tables in batches",
+	run:   runImport,
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl import -db path [flags] in.delim...\n\n"+
+			"Applies delimited proto streams, as written by events.DelimitedWriter,\n"+
+			"to the tables of sinks.SQLite, one batch of messages per transaction,\n"+
+			"without loading a stream into memory. An input of - reads stdin. Needs\n"+
+			"eventlogctl built with the \"sqlite3\" database/sql driver.\n\n")
+		fs.PrintDefaults()
+	}
+	dbPath := fs.String("db", "", "SQLite database file")
+	prefix := fs.String("prefix", "", "Table prefix")
+	batch := fs.Int("batch", sinks.DefaultSQLiteBatch, "Messages per transaction")
+	verbose := fs.Bool("v", false, "Log progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dbPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *batch <= 0 {
+		return fmt.Errorf("got -batch=%d; want > 0", *batch)
+	}
+	quietLog(*verbose)
+
+	db, err := openDB("sqlite3", *dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	s, err := sinks.NewSQLite(context.Background(), db, *prefix)
+	if err != nil {
+		return err
+	}
+	for _, in := range fs.Args() {
+		n, err := importFile(s, in, *batch)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		log.Printf("imported %d messages from %s\n", n, in)
+	}
+	return nil
+}
+
+func importFile(s *sinks.SQLite, path string, batch int) (int, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return s.Import(r, batch)
+}
@@ -0,0 +1,114 @@
+// Command eventlogctl is a command line tool for working with event streams
+// and eventlog files.
+//
+// Usage:
+//
+//	eventlogctl <command> [flags]
+//
+// Run "eventlogctl <command> -h" for the flags of a command. Node URLs may
+// reference environment variables as ${NAME}, e.g. for API keys, which are
+// then redacted from the output. The flags of tail, backfill and export can
+// be saved as named profiles; see "eventlogctl profile".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	tailCommand,
+	monitorCommand,
+	backfillCommand,
+	replayCommand,
+	importCommand,
+	diffCommand,
+	mergeCommand,
+	compactCommand,
+	coverageCommand,
+	exportCommand,
+	verifyCommand,
+	signCommand,
+	profileCommand,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: eventlogctl <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
+	}
+}
+
+// listFlag is a flag that may be repeated or contain comma-separated values.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
+	}
+	return nil
+}
+
+// interrupted returns a channel that is closed on SIGINT or SIGTERM.
+func interrupted() chan struct{} {
+	done := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		close(done)
+	}()
+	return done
+}
+
+// quietLog silences the progress logging of the events package.
+func quietLog(verbose bool) {
+	if !verbose {
+		log.SetOutput(io.Discard)
+	}
+}
+
+func main() {
+
+	log.SetFlags(0)
+	log.SetOutput(events.RedactingWriter(os.Stderr))
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		if err != nil && !errors.Is(err, events.Canceled) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "error: %s\n", events.Redact(err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+)
+
+var monitorCommand = &command{
+	name:  "monitor",
+	short: "show a live dashboard of a stream in the terminal",
+	run:   runMonitor,
+}
+
+const (
+	monitorRateWindow = time.Minute // time the event rate is averaged over
+	monitorLagWarn    = 10          // lag in blocks shown in red
+)
+
+func runMonitor(args []string) error {
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	node := fs.String("node", "", "Ethereum JSON-RPC node url")
+	var addresses, abis listFlag
+	fs.Var(&addresses, "address", "Contract address to watch; may be repeated")
+	fs.Var(&abis, "abi", "Builtin ABI name (erc20) or ABI file used for decoding; may be repeated")
+	back := fs.Uint64("back", 0, "Start this many blocks behind head")
+	where := fs.String("where", "", "Only show events matching an expression, see tail")
+	addressFormat := fs.String("addresses", "checksum", "Address format: checksum (EIP-55) or lowercase")
+	lines := fs.Int("lines", 15, "Number of recent events shown")
+	refresh := fs.Duration("refresh", time.Second, "Time between screen updates")
+	applyProfile := profileFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
+	if *node == "" {
+		return fmt.Errorf("missing -node")
+	}
+	if *refresh <= 0 || *lines <= 0 {
+		return fmt.Errorf("got -refresh=%v, -lines=%d; want both > 0", *refresh, *lines)
+	}
+	quietLog(false)
+	af, err := events.ParseAddressFormat(*addressFormat)
+	if err != nil {
+		return err
+	}
+
+	decoder := decode.NewDecoder()
+	var loaded []*abi.ABI
+	for _, name := range abis {
+		a, err := decode.LoadABI(name)
+		if err != nil {
+			return err
+		}
+		decoder.Add(a)
+		loaded = append(loaded, a)
+	}
+	var filter ethereum.FilterQuery
+	for _, a := range addresses {
+		if !common.IsHexAddress(a) {
+			return fmt.Errorf("invalid address %q", a)
+		}
+		filter.Addresses = append(filter.Addresses, common.HexToAddress(a))
+	}
+	var expr *rules.Expr
+	if *where != "" {
+		if expr, err = rules.ParseExpr(*where, loaded...); err != nil {
+			return err
+		}
+		if filter, err = expr.Narrow(filter); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	client, err := ethrpc.Dial(ctx, *node)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	from := head
+	if *back < head {
+		from = head - *back
+	}
+
+	m := &monitor{
+		decoder:   decoder,
+		where:     expr,
+		addresses: af,
+		lines:     *lines,
+		node:      events.Redact(*node),
+		started:   time.Now(),
+		stats:     &events.StreamStats{},
+	}
+	cs := &events.ChainStreamer{
+		Ctx:        ctx,
+		Client:     client,
+		Filter:     filter,
+		Stats:      m.stats,
+		OnRollback: m.onRollback,
+	}
+	done := interrupted()
+	sub, err := cs.Stream(done, from)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+	stopped := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(*refresh)
+		defer t.Stop()
+		for {
+			m.draw(os.Stdout)
+			select {
+			case <-stopped:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	err = events.Drain(sub, m)
+	close(stopped)
+	wg.Wait()
+	return err
+}
+
+// monitorRollback is a rollback shown by the monitor.
+type monitorRollback struct {
+	at       time.Time
+	from, to uint64
+	dropped  int // emitted blocks dropped
+}
+
+// monitor is a Sink keeping the state the dashboard shows.
+type monitor struct {
+	decoder   *decode.Decoder
+	where     *rules.Expr // nil shows all events
+	addresses events.AddressFormat
+	lines     int
+	node      string
+	started   time.Time
+	stats     *events.StreamStats
+
+	mu        sync.Mutex
+	recent    []string    // newest last
+	times     []time.Time // of the events in the rate window
+	total     uint64
+	rollbacks []monitorRollback // newest last
+}
+
+func (m *monitor) onRollback(from, to uint64, dropped []*events.Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollbacks = append(m.rollbacks, monitorRollback{at: time.Now(), from: from, to: to, dropped: len(dropped)})
+	if len(m.rollbacks) > 5 {
+		m.rollbacks = m.rollbacks[len(m.rollbacks)-5:]
+	}
+}
+
+func (m *monitor) Append(b *events.Block) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range b.Events {
+		e := &b.Events[i]
+		de, err := m.decoder.Decode(e)
+		if err != nil {
+			de = &decode.Event{Event: e}
+		}
+		if m.where != nil && !m.where.Matches(de) {
+			continue
+		}
+		m.total++
+		m.times = append(m.times, now)
+		m.recent = append(m.recent, fmt.Sprintf("%d/%d %s %s",
+			e.BlockNumber, e.Index, m.addresses.Format(e.Address), de.Render(m.addresses)))
+	}
+	if len(m.recent) > m.lines {
+		m.recent = m.recent[len(m.recent)-m.lines:]
+	}
+	return nil
+}
+
+func (m *monitor) Rollback(n uint64) error {
+	return nil
+}
+
+func (m *monitor) SetNext(n uint64) error {
+	return nil
+}
+
+// rate returns the events per second over the rate window.
+func (m *monitor) rate(now time.Time) float64 {
+	cut := 0
+	for cut < len(m.times) && now.Sub(m.times[cut]) > monitorRateWindow {
+		cut++
+	}
+	m.times = m.times[cut:]
+	window := monitorRateWindow
+	if up := now.Sub(m.started); up < window {
+		window = up
+	}
+	if window <= 0 {
+		return 0
+	}
+	return float64(len(m.times)) / window.Seconds()
+}
+
+func (m *monitor) draw(w io.Writer) {
+	now := time.Now()
+	snap := m.stats.Snapshot()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "%s  %s\n\n", colorCyan+"eventlogctl monitor"+colorReset, m.node)
+	lag := colorGreen
+	if snap.Lag > monitorLagWarn {
+		lag = colorRed
+	}
+	fmt.Fprintf(&sb, "head %d   next %d   lag %s%d blocks%s", snap.Head, snap.Next, lag, snap.Lag, colorReset)
+	if snap.ETA > 0 {
+		fmt.Fprintf(&sb, "   caught up in %s", snap.ETA.Round(time.Second))
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "events %d   rate %.2f/s   rollbacks %d (deepest %d)\n", m.total, m.rate(now), snap.Rollbacks, snap.MaxReorgDepth)
+	if !snap.LastProgress.IsZero() {
+		fmt.Fprintf(&sb, "last progress %s ago\n", now.Sub(snap.LastProgress).Round(time.Second))
+	}
+
+	sb.WriteString("\n" + colorDim + "recent events" + colorReset + "\n")
+	for _, s := range m.recent {
+		sb.WriteString(s + "\n")
+	}
+	for i := len(m.recent); i < m.lines; i++ {
+		sb.WriteString("\n")
+	}
+
+	if len(m.rollbacks) > 0 {
+		sb.WriteString("\n" + colorDim + "rollbacks" + colorReset + "\n")
+		for i := len(m.rollbacks) - 1; i >= 0; i-- {
+			r := m.rollbacks[i]
+			fmt.Fprintf(&sb, "%s%s  blocks %d..%d reorganized, %d emitted blocks dropped%s\n",
+				colorRed, r.at.Format("15:04:05"), r.from, r.to-1, r.dropped, colorReset)
+		}
+	}
+	sb.WriteString("\n" + colorDim + "Ctrl-C to quit" + colorReset + "\n")
+	io.WriteString(w, sb.String())
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var profileCommand = &command{
+	name:  "profile",
+	short: "manage named sets of flags for the other commands",
+	run:   runProfile,
+}
+
+// A profile holds flag values by flag name, e.g.
+//
+//	{"node": "https://mainnet.example.org/v3/${NODE_KEY}", "address": ["0x..."], "abi": "erc20"}
+//
+// Commands with a -profile flag take the values of the flags they have and
+// were not given on the command line from the profile.
+type profile map[string]profileValues
+
+// profileValues is a flag value, or the values of a repeated flag.
+type profileValues []string
+
+func (v *profileValues) UnmarshalJSON(bs []byte) error {
+	var s string
+	if err := json.Unmarshal(bs, &s); err == nil {
+		*v = profileValues{s}
+		return nil
+	}
+	return json.Unmarshal(bs, (*[]string)(v))
+}
+
+var profileNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// profileDir returns the directory of the profiles: $EVENTLOGCTL_CONFIG_DIR,
+// or eventlogctl in the user config directory.
+func profileDir() (string, error) {
+	if dir := os.Getenv("EVENTLOGCTL_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "profiles"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "eventlogctl", "profiles"), nil
+}
+
+func profilePath(name string) (string, error) {
+	if !profileNameRE.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	dir, err := profileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func loadProfile(name string) (profile, error) {
+	fn, err := profilePath(name)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no profile %q; see eventlogctl profile", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p profile
+	if err := json.Unmarshal(bs, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return p, nil
+}
+
+// profileFlag adds a -profile flag to fs. The returned function, called
+// after parsing, sets the flags not given on the command line from the
+// profile. Values for flags fs does not have are ignored, so that one
+// profile serves several commands.
+func profileFlag(fs *flag.FlagSet) func() error {
+	name := fs.String("profile", "", "Take flags not given from this saved profile; see eventlogctl profile")
+	return func() error {
+		if *name == "" {
+			return nil
+		}
+		p, err := loadProfile(*name)
+		if err != nil {
+			return err
+		}
+		given := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+		for flagName, values := range p {
+			if given[flagName] || fs.Lookup(flagName) == nil {
+				continue
+			}
+			for _, v := range values {
+				if err := fs.Set(flagName, v); err != nil {
+					return fmt.Errorf("profile %s: -%s: %w", *name, flagName, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func runProfile(args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n"+
+			"  eventlogctl profile list\n"+
+			"  eventlogctl profile show <name>\n"+
+			"  eventlogctl profile save <name> -flag=value ...\n"+
+			"  eventlogctl profile delete <name>\n\n"+
+			"Profiles are named sets of flags, used by the commands with a -profile\n"+
+			"flag. Save stores the flags as given, e.g.\n\n"+
+			"  eventlogctl profile save usdc -node='https://mainnet.example.org/v3/${NODE_KEY}' \\\n"+
+			"    -address=0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48 -abi=erc20\n"+
+			"  eventlogctl tail -profile usdc -where 'value > 1e12'\n\n"+
+			"Profiles are stored in $EVENTLOGCTL_CONFIG_DIR/profiles, by default in\n"+
+			"the eventlogctl directory of the user config directory.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		dir, err := profileDir()
+		if err != nil {
+			return err
+		}
+		fns, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, fn := range fns {
+			fmt.Println(strings.TrimSuffix(filepath.Base(fn), ".json"))
+		}
+		return nil
+	case args[0] == "show" && len(args) == 2:
+		p, err := loadProfile(args[1])
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(p))
+		for name := range p {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, v := range p[name] {
+				fmt.Printf("-%s=%s\n", name, v)
+			}
+		}
+		return nil
+	case args[0] == "save" && len(args) >= 2:
+		p, err := parseProfileFlags(args[2:])
+		if err != nil {
+			return err
+		}
+		fn, err := profilePath(args[1])
+		if err != nil {
+			return err
+		}
+		bs, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			return err
+		}
+		return os.WriteFile(fn, append(bs, '\n'), 0600)
+	case args[0] == "delete" && len(args) == 2:
+		fn, err := profilePath(args[1])
+		if err != nil {
+			return err
+		}
+		return os.Remove(fn)
+	}
+	fs.Usage()
+	return flag.ErrHelp
+}
+
+// parseProfileFlags parses -name=value and -name value arguments. A flag
+// without a value, like -json, is true; repeated flags keep all values.
+func parseProfileFlags(args []string) (profile, error) {
+	p := make(profile)
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") || strings.TrimLeft(a, "-") == "" {
+			return nil, fmt.Errorf("got %q; want -flag=value", a)
+		}
+		a = strings.TrimLeft(a, "-")
+		name, value := a, ""
+		if j := strings.IndexByte(a, '='); j >= 0 {
+			name, value = a[:j], a[j+1:]
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			value = args[i]
+		} else {
+			value = "true"
+		}
+		if name == "profile" {
+			return nil, fmt.Errorf("profiles cannot reference other profiles")
+		}
+		p[name] = append(p[name], value)
+	}
+	return p, nil
+}
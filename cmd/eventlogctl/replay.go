@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/jcjlcodes/eth-eventlog/config"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+	"github.com/jcjlcodes/eth-eventlog/sinks"
+)
+
+var replayCommand = &command{
+	name:  "replay",
+	short: "push a stored eventlog through sinks, e.g. to rebuild tables",
+	run:   runReplay,
+}
+
+// batchSink is a Sink applying many messages at once, like sinks.SQLite.
+type batchSink interface {
+	events.Sink
+	ApplyBatch([]*events.Message) error
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl replay -from in.pb (-sink url | -config pipeline.yaml) [flags]\n\n"+
+			"Applies the blocks of a stored eventlog to sinks, without fetching from\n"+
+			"the chain. Sink urls are\n\n"+
+			"  postgres://...   the events table of sinks.Postgres\n"+
+			"  sqlite:path      the tables of sinks.SQLite\n"+
+			"  tcp://host:1883  an MQTT broker, also ssl://\n\n"+
+			"Database sinks need eventlogctl built with the database/sql driver,\n"+
+			"\"postgres\" or \"sqlite3\". With -config, the sinks of the pipeline\n"+
+			"config are used, with their write-ahead logs, and get only the events\n"+
+			"matching its filter where expression.\n\n")
+		fs.PrintDefaults()
+	}
+	from := fs.String("from", "", "Eventlog file to replay")
+	var sinkURLs listFlag
+	fs.Var(&sinkURLs, "sink", "Sink url; may be repeated")
+	configPath := fs.String("config", "", "Pipeline config whose sinks to replay to")
+	prefix := fs.String("prefix", "", "Table prefix of database sinks")
+	start := fs.Uint64("start", 0, "First block to replay; default the first stored")
+	batch := fs.Int("batch", sinks.DefaultSQLiteBatch, "Messages per transaction of sinks applying batches")
+	verbose := fs.Bool("v", false, "Log progress to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || len(sinkURLs) == 0 && *configPath == "" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *batch <= 0 {
+		return fmt.Errorf("got -batch=%d; want > 0", *batch)
+	}
+	quietLog(*verbose)
+
+	l, err := events.LoadCheckpoint(*from)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var targets []events.Sink
+	for _, u := range sinkURLs {
+		s, closer, err := openReplaySink(ctx, u, *prefix)
+		if err != nil {
+			return fmt.Errorf("sink %s: %w", events.Redact(u), err)
+		}
+		defer closer.Close()
+		targets = append(targets, s)
+	}
+	// The sinks of the config, from configured on, see the events matching
+	// its where expression only, as in the pipeline.
+	configured := len(targets)
+	var where *rules.Expr
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return err
+		}
+		decoder, err := cfg.Decoder()
+		if err != nil {
+			return err
+		}
+		if where, err = cfg.Where(); err != nil {
+			return err
+		}
+		ss, err := cfg.BuildSinks(ctx, decoder)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, ss...)
+	}
+
+	first := l.FirstBlock()
+	if *start > first {
+		first = *start
+	}
+	sub, err := l.Stream(interrupted(), first)
+	if err != nil {
+		return err
+	}
+	pending := make([][]*events.Message, len(targets))
+	flush := func(i int) error {
+		if len(pending[i]) == 0 {
+			return nil
+		}
+		err := targets[i].(batchSink).ApplyBatch(pending[i])
+		pending[i] = pending[i][:0]
+		return err
+	}
+	n := 0
+	for m := range sub.C {
+		for i, s := range targets {
+			m := m
+			if i >= configured && where != nil {
+				m = where.SelectMessage(m)
+			}
+			if _, ok := s.(batchSink); !ok {
+				if err := events.Apply(s, m); err != nil {
+					return err
+				}
+				continue
+			}
+			if pending[i] = append(pending[i], m); len(pending[i]) >= *batch {
+				if err := flush(i); err != nil {
+					return err
+				}
+			}
+		}
+		if n++; n%10000 == 0 {
+			log.Printf("replayed %d messages\n", n)
+		}
+	}
+	if err := <-sub.Err; err != nil {
+		return err
+	}
+	for i := range targets {
+		if err := flush(i); err != nil {
+			return err
+		}
+	}
+	log.Printf("replayed %d messages of blocks %d:%d\n", n, first, l.NextBlock())
+	return nil
+}
+
+// openReplaySink opens the sink of a url. The returned closer releases its
+// connection.
+func openReplaySink(ctx context.Context, u, prefix string) (events.Sink, io.Closer, error) {
+	switch {
+	case strings.HasPrefix(u, "postgres://") || strings.HasPrefix(u, "postgresql://"):
+		db, err := openDB("postgres", u)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := sinks.NewPostgres(ctx, db, prefix)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return s, db, nil
+	case strings.HasPrefix(u, "sqlite:"):
+		db, err := openDB("sqlite3", strings.TrimPrefix(strings.TrimPrefix(u, "sqlite:"), "//"))
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := sinks.NewSQLite(ctx, db, prefix)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return s, db, nil
+	case strings.HasPrefix(u, "tcp://") || strings.HasPrefix(u, "ssl://"):
+		s := &sinks.MQTTSink{Broker: u, ClientID: "eventlogctl-replay"}
+		return s, s, nil
+	}
+	return nil, nil, fmt.Errorf("unknown sink url; want postgres://, sqlite:, tcp:// or ssl://")
+}
+
+func openDB(driver, dsn string) (*sql.DB, error) {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return sql.Open(driver, dsn)
+		}
+	}
+	return nil, fmt.Errorf("eventlogctl was built without the %q database/sql driver", driver)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+var signCommand = &command{
+	name:  "sign",
+	short: "sign eventlog files with an ed25519 key",
+	run:   runSign,
+}
+
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl sign -key operator.key eventlog.pb ...\n"+
+			"       eventlogctl sign -new-key operator.key\n\n"+
+			"Writes the signature of each file to the file name plus %q. Check it\n"+
+			"with \"eventlogctl verify -pubkey operator.key.pub\".\n\n", events.SignatureSuffix)
+		fs.PrintDefaults()
+	}
+	keyPath := fs.String("key", "", "File with the hex seed of the signing key")
+	newKey := fs.String("new-key", "", "Generate a key into this file, and its public key into the file plus .pub")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *newKey != "" {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*newKey, []byte(hex.EncodeToString(priv.Seed())+"\n"), 0600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*newKey+".pub", []byte(hex.EncodeToString(pub)+"\n"), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s and %s.pub\n", *newKey, *newKey)
+		return nil
+	}
+	if *keyPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	key, err := events.ReadSigningKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	for _, fn := range fs.Args() {
+		if err := events.SignCheckpoint(fn, key); err != nil {
+			return err
+		}
+		fmt.Printf("signed %s\n", fn)
+	}
+	return nil
+}
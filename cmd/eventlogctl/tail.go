@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+)
+
+var tailCommand = &command{
+	name:  "tail",
+	short: "print live decoded events",
+	run:   runTail,
+}
+
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	node := fs.String("node", "", "Ethereum JSON-RPC node url")
+	var addresses, abis listFlag
+	fs.Var(&addresses, "address", "Contract address to watch; may be repeated")
+	fs.Var(&abis, "abi", "Builtin ABI name (erc20) or ABI file used for decoding; may be repeated")
+	back := fs.Uint64("back", 0, "Start this many blocks behind head")
+	jsonOut := fs.Bool("json", false, "Print one JSON object per line")
+	color := fs.String("color", "auto", "Colorize output: auto, always or never")
+	addressFormat := fs.String("addresses", "checksum", "Address format: checksum (EIP-55) or lowercase")
+	bytesFormat := fs.String("bytes", "hex", "Encoding of byte arguments in -json output: hex or base64")
+	tokens := fs.Bool("tokens", false, "Resolve the ERC-20 metadata of Transfer and Approval events")
+	where := fs.String("where", "", `Only print events matching an expression, e.g. "topic0 == Transfer && value > 1e6"`)
+	verbose := fs.Bool("v", false, "Log stream progress to stderr")
+	applyProfile := profileFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
+	if *node == "" {
+		return fmt.Errorf("missing -node")
+	}
+	quietLog(*verbose)
+	af, err := events.ParseAddressFormat(*addressFormat)
+	if err != nil {
+		return err
+	}
+	enc, err := events.ParseBytesEncoding(*bytesFormat)
+	if err != nil {
+		return err
+	}
+
+	decoder := decode.NewDecoder()
+	var loaded []*abi.ABI
+	for _, name := range abis {
+		a, err := decode.LoadABI(name)
+		if err != nil {
+			return err
+		}
+		decoder.Add(a)
+		loaded = append(loaded, a)
+	}
+	var filter ethereum.FilterQuery
+	for _, a := range addresses {
+		if !common.IsHexAddress(a) {
+			return fmt.Errorf("invalid address %q", a)
+		}
+		filter.Addresses = append(filter.Addresses, common.HexToAddress(a))
+	}
+	var expr *rules.Expr
+	if *where != "" {
+		if expr, err = rules.ParseExpr(*where, loaded...); err != nil {
+			return err
+		}
+		if filter, err = expr.Narrow(filter); err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	client, err := ethrpc.Dial(ctx, *node)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	from := head
+	if *back < head {
+		from = head - *back
+	}
+
+	cs := &events.ChainStreamer{
+		Ctx:    ctx,
+		Url:    *node,
+		Filter: filter,
+	}
+	sub, err := cs.Stream(interrupted(), from)
+	if err != nil {
+		return err
+	}
+
+	p := &tailPrinter{w: os.Stdout, decoder: decoder, json: *jsonOut, addresses: af, bytes: enc, where: expr}
+	if *tokens {
+		p.tokens = decode.NewTokenResolver(ctx, client)
+	}
+	switch *color {
+	case "always":
+		p.color = true
+	case "auto":
+		fi, err := os.Stdout.Stat()
+		p.color = err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+	return events.Drain(sub, p)
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// tailPrinter is a Sink printing events as text or JSON lines.
+type tailPrinter struct {
+	w         io.Writer
+	decoder   *decode.Decoder
+	json      bool
+	color     bool
+	addresses events.AddressFormat
+	bytes     events.BytesEncoding  // of -json arguments
+	tokens    *decode.TokenResolver // nil unless -tokens
+	where     *rules.Expr           // nil unless -where
+}
+
+func (p *tailPrinter) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+type tailEventJSON struct {
+	Action  string                 `json:"action"`
+	Block   uint64                 `json:"block"`
+	Index   uint64                 `json:"index"`
+	Address string                 `json:"address"`
+	TxHash  common.Hash            `json:"txHash"`
+	Event   string                 `json:"event,omitempty"`
+	Args    map[string]interface{} `json:"args,omitempty"`
+	Token   *decode.Token          `json:"token,omitempty"`
+	Amount  string                 `json:"amount,omitempty"` // value in token units
+	Topics  []common.Hash          `json:"topics,omitempty"`
+}
+
+func (p *tailPrinter) Append(b *events.Block) error {
+	for i := range b.Events {
+		e := &b.Events[i]
+		de, err := p.decoder.Decode(e)
+		if err != nil {
+			de = &decode.Event{Event: e}
+		}
+		if p.where != nil && !p.where.Matches(de) {
+			continue
+		}
+		if p.tokens != nil {
+			if err := p.tokens.Annotate(de); err != nil {
+				return err
+			}
+		}
+		if p.json {
+			out := &tailEventJSON{
+				Action:  "append",
+				Block:   e.BlockNumber,
+				Index:   e.Index,
+				Address: p.addresses.Format(e.Address),
+				TxHash:  e.TxHash,
+				Event:   de.Name,
+				Args:    decode.FormatJSONArgs(de.Args, p.addresses, p.bytes),
+				Token:   de.Token,
+			}
+			if de.Name == "" {
+				out.Topics = e.Topics
+			}
+			if v, ok := de.Args["value"].(*big.Int); ok && de.Token != nil {
+				out.Amount = decode.FormatAmount(v, de.Token.Decimals)
+			}
+			if err := p.writeJSON(out); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(p.w, "%s %s %s %s\n",
+			p.paint(colorDim, fmt.Sprintf("%d/%d", e.BlockNumber, e.Index)),
+			p.paint(colorCyan, p.addresses.Format(e.Address)),
+			p.paint(colorGreen, de.Render(p.addresses)),
+			p.paint(colorDim, e.TxHash.Hex())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *tailPrinter) Rollback(n uint64) error {
+	if p.json {
+		return p.writeJSON(map[string]interface{}{"action": "rollback", "number": n})
+	}
+	_, err := fmt.Fprintln(p.w, p.paint(colorRed, fmt.Sprintf("rollback to block %d", n)))
+	return err
+}
+
+func (p *tailPrinter) SetNext(n uint64) error {
+	return nil
+}
+
+func (p *tailPrinter) writeJSON(v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s\n", bs)
+	return err
+}
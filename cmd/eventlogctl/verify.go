@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+)
+
+var verifyCommand = &command{
+	name:  "verify",
+	short: "check an eventlog file's signature or on-chain receipts",
+	run:   runVerify,
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: eventlogctl verify [-pubkey key.pub] [-node url] eventlog.pb\n\n"+
+			"With -pubkey, checks the signature written by \"eventlogctl sign\".\n\n"+
+			"With -node, fetches the receipts of every block in the range of the file,\n"+
+			"checks them against the receipts root of the block and the events against\n"+
+			"the logs. This takes one call per transaction, so it is slow for large\n"+
+			"ranges.\n\n")
+		fs.PrintDefaults()
+	}
+	node := fs.String("node", "", "Ethereum JSON-RPC node url")
+	pubkey := fs.String("pubkey", "", "File with the hex public key that signed the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*node == "" && *pubkey == "") || fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	if *pubkey != "" {
+		key, err := events.ReadPublicKey(*pubkey)
+		if err != nil {
+			return err
+		}
+		if err := events.VerifyCheckpoint(fs.Arg(0), key); err != nil {
+			return err
+		}
+		fmt.Printf("signature of %s is valid\n", fs.Arg(0))
+		if *node == "" {
+			return nil
+		}
+	}
+
+	l, err := events.LoadCheckpoint(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := ethrpc.Dial(ctx, *node)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	bs := l.BlockSlice()
+	bar := &progressBar{total: bs.End - bs.Start}
+	done := interrupted()
+	i := 0
+	for n := bs.Start; n < bs.End; n++ {
+		select {
+		case <-done:
+			bar.finish()
+			return events.Canceled
+		default:
+		}
+		var b *events.Block
+		if i < len(bs.Blocks) && bs.Blocks[i].Number == n {
+			b = bs.Blocks[i]
+			i++
+		}
+		if err := events.VerifyBlockReceipts(ctx, client, l.Filter(), n, b); err != nil {
+			bar.finish()
+			return err
+		}
+		bar.update(n+1-bs.Start, 0)
+	}
+	bar.finish()
+	fmt.Printf("verified blocks %d:%d\n", bs.Start, bs.End)
+	return nil
+}
@@ -0,0 +1,96 @@
+// Command eventlogd runs the event pipeline described by a config file (see
+// package config), with checkpointing, and serves admin, health and metrics
+// endpoints:
+//
+//	/healthz, /readyz   probes for Kubernetes
+//	/metrics            Prometheus metrics
+//	/admin/status       stream status as JSON
+//	/admin/checkpoint   POST to write a checkpoint now
+//
+// On SIGINT or SIGTERM it stops streaming, writes a final checkpoint and
+// exits.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jcjlcodes/eth-eventlog/admin"
+	"github.com/jcjlcodes/eth-eventlog/config"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+var configFlag = flag.String("config", "eventlogd.yaml", "Pipeline config file")
+var listenFlag = flag.String("listen", ":8080", "Address of the admin, health and metrics endpoints; empty disables them")
+var maxLagFlag = flag.Uint64("max_lag", 10, "Lag in blocks up to which /readyz reports ready")
+
+func run() error {
+	cfg, err := config.Load(*configFlag)
+	if err != nil {
+		return err
+	}
+	p, err := cfg.Build(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Printf("starting at block %d", p.EventLog.NextBlock())
+
+	streams := map[string]*events.StreamStats{"chain": p.Stats}
+	var srv *http.Server
+	if *listenFlag != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", admin.Healthz(streams, 0))
+		mux.Handle("/readyz", admin.Readyz(streams, *maxLagFlag))
+		mux.Handle("/metrics", admin.Metrics(streams))
+		mux.Handle("/admin/", http.StripPrefix("/admin", &admin.Handler{
+			Streams:    streams,
+			Checkpoint: p.Checkpoint,
+			MaxLag:     *maxLagFlag,
+		}))
+		srv = &http.Server{Addr: *listenFlag, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("http server: %v", err)
+			}
+		}()
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		sig := <-sigs
+		log.Printf("got %v, shutting down", sig)
+		close(done)
+	}()
+
+	err = p.Run(done)
+	if srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}
+	if errors.Is(err, events.Canceled) {
+		log.Printf("stopped at block %d", p.EventLog.NextBlock())
+		return nil
+	}
+	return err
+}
+
+func main() {
+
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	log.SetOutput(events.RedactingWriter(os.Stderr))
+	flag.Parse()
+
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package compat keeps the channel-based streaming API working on top of
+// events.ContextStreamer, so programs can move to the context-based API one
+// component at a time: a ContextStreamer is still usable where an
+// events.Streamer is expected, and the other way around.
+//
+//	var s events.Streamer = compat.Streamer(cs) // done channel and Subscription
+//	var cs events.ContextStreamer = compat.Context(s)
+package compat
+
+import (
+	"context"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Streamer returns a Streamer emitting the messages of cs. Closing the done
+// channel cancels the context of cs, ending the stream with events.Canceled
+// as before.
+func Streamer(cs events.ContextStreamer) events.Streamer {
+	if s, ok := cs.(contextStreamer); ok {
+		return s.s
+	}
+	return streamer{cs: cs}
+}
+
+type streamer struct {
+	cs events.ContextStreamer
+}
+
+func (s streamer) Stream(done chan struct{}, from uint64) (*events.Subscription, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	c := make(chan *events.Message)
+	errc := make(chan error, 1)
+	go func() {
+		defer cancel()
+		err := s.cs.StreamContext(ctx, from, func(m events.TypedMessage) error {
+			select {
+			case <-done:
+				return events.Canceled
+			case c <- m.Message():
+				return nil
+			}
+		})
+		if err == context.Canceled {
+			err = events.Canceled
+		}
+		close(c)
+		errc <- err
+	}()
+	return &events.Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+// Context returns a ContextStreamer emitting the messages of s, see
+// events.StreamContext.
+func Context(s events.Streamer) events.ContextStreamer {
+	if cs, ok := s.(streamer); ok {
+		return cs.cs
+	}
+	return contextStreamer{s: s}
+}
+
+type contextStreamer struct {
+	s events.Streamer
+}
+
+func (cs contextStreamer) StreamContext(ctx context.Context, from uint64, fn func(events.TypedMessage) error) error {
+	return events.StreamContext(ctx, cs.s, from, fn)
+}
+
+// SinkFunc returns a function applying typed messages to a Sink, for use
+// with StreamContext.
+func SinkFunc(s events.Sink) func(events.TypedMessage) error {
+	return func(m events.TypedMessage) error {
+		return events.Apply(s, m.Message())
+	}
+}
@@ -0,0 +1,222 @@
+// Package config builds event pipelines from a declarative YAML file, so
+// that command line tools and user binaries share one configuration surface.
+//
+// An example config:
+//
+//	node: https://mainnet.example.org/v3/${NODE_KEY}  # ${NAME} is a secret
+//	secrets_dir: /run/secrets    # read secrets from files; default environment
+//	start: head-100            # or a block number; used without checkpoint
+//	contracts:
+//	  usdc:
+//	    address: "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+//	    abi: erc20               # builtin ABI name or path to an ABI file
+//	filter:
+//	  contracts: [usdc]          # empty means all contracts above
+//	  events: [Transfer]         # event names from the contract ABIs
+//	  where: value > 1e6         # optional expression, see rules.Expr
+//	streamer:
+//	  fetch_batch_size: 2000
+//	  batch_overlap: 10
+//	retention: 100000            # blocks kept in memory; 0 keeps all
+//	tx_data_retention: 1000      # blocks TxData is kept; 0 keeps it with the events
+//	tx_data_hash: true           # keep the keccak256 of dropped TxData
+//	checkpoint:
+//	  path: data/eventlog.pb     # or dir: data/checkpoints for deltas
+//	  every: 100                 # blocks between checkpoints; 0 for every batch
+//	  signing_key: operator.key  # optional; writes eventlog.pb.sig
+//	  trusted_keys: [op.pub]     # optional; verifies the signature on load
+//	sinks:
+//	  - type: discord
+//	    url: https://discord.com/api/webhooks/...
+//	    rules: big-transfers.json
+//	    wal: data/discord.wal      # optional write-ahead log
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Node       string              `yaml:"node"`
+	SecretsDir string              `yaml:"secrets_dir"`
+	Start      string              `yaml:"start"`
+	Contracts  map[string]Contract `yaml:"contracts"`
+	Filter     Filter              `yaml:"filter"`
+	Streamer   Streamer            `yaml:"streamer"`
+	Retention  uint64              `yaml:"retention"`
+	Checkpoint Checkpoint          `yaml:"checkpoint"`
+	Sinks      []Sink              `yaml:"sinks"`
+
+	// TxDataRetention is the number of blocks TxData is kept for; 0 keeps
+	// it as long as the events. If TxDataHash is set, the hash of the
+	// dropped data is kept.
+	TxDataRetention uint64 `yaml:"tx_data_retention"`
+	TxDataHash      bool   `yaml:"tx_data_hash"`
+}
+
+type Contract struct {
+	Address common.Address `yaml:"address"`
+	ABI     string         `yaml:"abi"`
+}
+
+type Filter struct {
+	Contracts []string `yaml:"contracts"`
+	Events    []string `yaml:"events"`
+
+	// Where is a rules.Expr the events passed to the sinks must match. Its
+	// address and topic comparisons also narrow the node filter.
+	Where string `yaml:"where"`
+}
+
+type Streamer struct {
+	FetchBatchSize uint64 `yaml:"fetch_batch_size"`
+	BatchOverlap   uint64 `yaml:"batch_overlap"`
+	FetchTxDetails bool   `yaml:"fetch_tx_details"`
+	TrackHeaders   bool   `yaml:"track_headers"`
+	EventsRoots    bool   `yaml:"events_roots"` // merkle root per block
+	Intern         bool   `yaml:"intern"`       // share equal TxData and Topics
+
+	// BatchOverlapDuration overrides BatchOverlap; see
+	// events.ChainStreamer.
+	BatchOverlapDuration time.Duration `yaml:"batch_overlap_duration"`
+	AutoTuneOverlap      bool          `yaml:"auto_tune_overlap"`
+	MaxBatchOverlap      uint64        `yaml:"max_batch_overlap"` // bound of auto-tuning
+	BlockTimestamps      bool          `yaml:"block_timestamps"`  // from logs or headers
+	ClampToEarliest      bool          `yaml:"clamp_to_earliest"` // for pruned nodes
+	WaitForStart         bool          `yaml:"wait_for_start"`    // start may be beyond head
+	RollbackBlocks       bool          `yaml:"rollback_blocks"`   // dropped blocks in rollbacks
+	Buffer               int           `yaml:"buffer"`            // messages
+	MaxBacklog           uint64        `yaml:"max_backlog"`       // blocks
+
+	// Restarts is the number of times a failed chain stream is restarted,
+	// waiting RestartDelay in between; see events.LiveEventLog.
+	Restarts     int           `yaml:"restarts"`
+	RestartDelay time.Duration `yaml:"restart_delay"`
+
+	// SkipToDeployment starts a new eventlog no earlier than the
+	// deployment of the filter's contracts; it needs an archive node.
+	SkipToDeployment bool `yaml:"skip_to_deployment"`
+
+	// OnAhead is what to do when the restored eventlog ends beyond the
+	// chain head: error (default), wait or rollback.
+	OnAhead string `yaml:"on_ahead"`
+
+	Budget Budget `yaml:"budget"`
+}
+
+// Budget limits the RPC calls of the streamer; see events.Budget. Without
+// limits there is no budget.
+type Budget struct {
+	MaxCalls uint64            `yaml:"max_calls"`
+	MaxUnits uint64            `yaml:"max_units"` // estimated compute units
+	Window   time.Duration     `yaml:"window"`    // default an hour
+	Units    map[string]uint64 `yaml:"units"`     // by call, e.g. FilterLogs
+	Wait     bool              `yaml:"wait"`      // pause instead of failing
+}
+
+// Checkpoint configures either a single checkpoint file (Path), rewritten at
+// every checkpoint, or a directory of delta checkpoints (Dir) where only the
+// changes since the previous checkpoint are written; see
+// events.DeltaCheckpoints.
+type Checkpoint struct {
+	Path          string `yaml:"path"`
+	Dir           string `yaml:"dir"`
+	SnapshotEvery int    `yaml:"snapshot_every"` // deltas between snapshots
+	Every         uint64 `yaml:"every"`
+	DedupData     bool   `yaml:"dedup_data"` // see events.DedupData; needs readers supporting it
+
+	// SigningKey is a file with an ed25519 key to sign the checkpoint
+	// with. TrustedKeys are files with public keys, one of which must
+	// have signed the checkpoint when it is loaded. Both need Path.
+	SigningKey  string   `yaml:"signing_key"`
+	TrustedKeys []string `yaml:"trusted_keys"`
+}
+
+func (c *Checkpoint) enabled() bool {
+	return c.Path != "" || c.Dir != ""
+}
+
+// Sink configures one sink. Which fields apply depends on Type:
+//
+//	discord, slack  url, template, rules, min_interval
+//	telegram        token, chat_id, template, rules, min_interval
+//	mqtt            url (the broker), client_id, username, password,
+//	                topic, qos, address_format
+//
+// If WAL is set, messages are logged to that file before delivery and
+// redelivered on restart if the sink did not acknowledge them.
+type Sink struct {
+	Type        string        `yaml:"type"`
+	URL         string        `yaml:"url"`
+	Token       string        `yaml:"token"`
+	ChatID      string        `yaml:"chat_id"`
+	Template    string        `yaml:"template"`
+	Rules       string        `yaml:"rules"`
+	MinInterval time.Duration `yaml:"min_interval"`
+	ClientID    string        `yaml:"client_id"`
+	Username    string        `yaml:"username"`
+	Password    string        `yaml:"password"`
+	Topic       string        `yaml:"topic"`
+	QoS         byte          `yaml:"qos"`
+	WAL         string        `yaml:"wal"`
+
+	// AddressFormat is checksum (EIP-55) or lowercase, the default of mqtt
+	// payloads.
+	AddressFormat string `yaml:"address_format"`
+}
+
+// Load reads a config file.
+func Load(path string) (*Config, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(bs)
+}
+
+// Parse parses the contents of a config file.
+func Parse(bs []byte) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(strings.NewReader(string(bs)))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *Config) startFromHead() bool {
+	s := strings.TrimSpace(c.Start)
+	return s == "" || strings.HasPrefix(s, "head")
+}
+
+// startBlock resolves the start setting, which is a block number, "head", or
+// "head-N".
+func (c *Config) startBlock(head uint64) (uint64, error) {
+	s := strings.ReplaceAll(c.Start, " ", "")
+	switch {
+	case s == "" || s == "head":
+		return head, nil
+	case strings.HasPrefix(s, "head-"):
+		n, err := strconv.ParseUint(strings.TrimPrefix(s, "head-"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid start %q", c.Start)
+		}
+		if n > head {
+			return 0, nil
+		}
+		return head - n, nil
+	}
+	n, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start %q", c.Start)
+	}
+	return n, nil
+}
@@ -0,0 +1,527 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"text/template"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+	"github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+	"github.com/jcjlcodes/eth-eventlog/rules"
+	"github.com/jcjlcodes/eth-eventlog/sinks"
+)
+
+// Pipeline streams from the chain into an in-memory eventlog, applying new
+// messages to the configured sinks, pruning the eventlog to the retention
+// and writing checkpoints.
+type Pipeline struct {
+	Config   *Config
+	EventLog *events.InMemoryEventLog
+	Streamer events.ChainStreamer
+	Stats    *events.StreamStats
+	Sinks    []events.Sink
+	Decoder  *decode.Decoder
+	Where    *rules.Expr // nil unless the filter has a where expression
+	OnAhead  events.AheadPolicy
+
+	mu             sync.Mutex // guards EventLog, deltas and lastCheckpoint
+	deltas         *events.DeltaCheckpoints
+	lastCheckpoint uint64
+	signingKey     ed25519.PrivateKey
+}
+
+// Build creates the pipeline described by the config. If a checkpoint file
+// exists, the eventlog is restored from it.
+func (c *Config) Build(ctx context.Context) (*Pipeline, error) {
+	if c.Node == "" {
+		return nil, fmt.Errorf("missing node")
+	}
+	if c.SecretsDir != "" {
+		events.RegisterSecrets(events.FileSecrets{Dir: c.SecretsDir})
+	}
+	abis, err := c.abis()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := c.filterQuery(abis)
+	if err != nil {
+		return nil, err
+	}
+	where, err := c.where(abis)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		if filter, err = where.Narrow(filter); err != nil {
+			return nil, fmt.Errorf("filter: %w", err)
+		}
+	}
+	decoder := decode.NewDecoder()
+	for _, a := range abis {
+		decoder.Add(a)
+	}
+
+	if (c.Checkpoint.SigningKey != "" || len(c.Checkpoint.TrustedKeys) > 0) && c.Checkpoint.Path == "" {
+		return nil, fmt.Errorf("checkpoint signing needs a checkpoint path")
+	}
+	var signingKey ed25519.PrivateKey
+	if c.Checkpoint.SigningKey != "" {
+		if signingKey, err = events.ReadSigningKey(c.Checkpoint.SigningKey); err != nil {
+			return nil, err
+		}
+	}
+	eventlog, deltas, err := c.loadEventLog(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{
+		Config:   c,
+		EventLog: eventlog,
+		Stats:    &events.StreamStats{},
+		Decoder:  decoder,
+		Where:    where,
+
+		deltas:         deltas,
+		lastCheckpoint: eventlog.NextBlock(),
+		signingKey:     signingKey,
+	}
+	p.Streamer = events.ChainStreamer{
+		Ctx:                  ctx,
+		Url:                  c.Node,
+		FetchBatchSize:       c.Streamer.FetchBatchSize,
+		BatchOverlap:         c.Streamer.BatchOverlap,
+		BatchOverlapDuration: c.Streamer.BatchOverlapDuration,
+		AutoTuneOverlap:      c.Streamer.AutoTuneOverlap,
+		MaxBatchOverlap:      c.Streamer.MaxBatchOverlap,
+		ClampToEarliest:      c.Streamer.ClampToEarliest,
+		WaitForFrom:          c.Streamer.WaitForStart,
+		RollbackBlocks:       c.Streamer.RollbackBlocks,
+		Buffer:               c.Streamer.Buffer,
+		MaxBacklog:           c.Streamer.MaxBacklog,
+		FetchTxDetails:       c.Streamer.FetchTxDetails,
+		TrackHeaders:         c.Streamer.TrackHeaders,
+		EventsRoots:          c.Streamer.EventsRoots,
+		BlockTimestamps:      c.Streamer.BlockTimestamps,
+		Stats:                p.Stats,
+	}
+	if err := p.Streamer.Validate(); err != nil {
+		return nil, fmt.Errorf("streamer: %w", err)
+	}
+	if p.OnAhead, err = events.ParseAheadPolicy(c.Streamer.OnAhead); err != nil {
+		return nil, fmt.Errorf("streamer: %w", err)
+	}
+	if b := c.Streamer.Budget; b.MaxCalls > 0 || b.MaxUnits > 0 {
+		p.Streamer.Budget = &events.Budget{
+			MaxCalls: b.MaxCalls,
+			MaxUnits: b.MaxUnits,
+			Window:   b.Window,
+			Units:    b.Units,
+			Wait:     b.Wait,
+		}
+	}
+	if c.Streamer.Intern {
+		p.Streamer.Interner = events.NewInterner()
+		eventlog.Intern(p.Streamer.Interner)
+	}
+	if p.Sinks, err = c.BuildSinks(ctx, decoder); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BuildSinks creates the configured sinks, with their write-ahead logs. It
+// lets other programs, like eventlogctl replay, feed the sinks of a
+// pipeline.
+func (c *Config) BuildSinks(ctx context.Context, decoder *decode.Decoder) ([]events.Sink, error) {
+	var out []events.Sink
+	for i, sc := range c.Sinks {
+		s, err := sc.build(ctx, decoder)
+		if err != nil {
+			return nil, fmt.Errorf("sink %d (%s): %w", i, sc.Type, err)
+		}
+		if sc.WAL != "" {
+			if s, err = sinks.OpenWAL(sc.WAL, s); err != nil {
+				return nil, fmt.Errorf("sink %d (%s): %w", i, sc.Type, err)
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Decoder returns a decoder for the events of the configured contracts.
+func (c *Config) Decoder() (*decode.Decoder, error) {
+	abis, err := c.abis()
+	if err != nil {
+		return nil, err
+	}
+	decoder := decode.NewDecoder()
+	for _, a := range abis {
+		decoder.Add(a)
+	}
+	return decoder, nil
+}
+
+// Where returns the where expression of the filter, or nil if there is
+// none, e.g. to apply it to the sinks of the pipeline outside of it.
+func (c *Config) Where() (*rules.Expr, error) {
+	abis, err := c.abis()
+	if err != nil {
+		return nil, err
+	}
+	return c.where(abis)
+}
+
+// abis loads the ABIs of the configured contracts, by contract name.
+func (c *Config) abis() (map[string]*abi.ABI, error) {
+	abis := make(map[string]*abi.ABI)
+	for name, contract := range c.Contracts {
+		if contract.ABI == "" {
+			continue
+		}
+		a, err := decode.LoadABI(contract.ABI)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %w", name, err)
+		}
+		abis[name] = a
+	}
+	return abis, nil
+}
+
+// where parses the where expression of the filter with all ABIs.
+func (c *Config) where(abis map[string]*abi.ABI) (*rules.Expr, error) {
+	if c.Filter.Where == "" {
+		return nil, nil
+	}
+	// Sorted, so that the filter is the same on every run.
+	names := make([]string, 0, len(abis))
+	for name := range abis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	all := make([]*abi.ABI, len(names))
+	for i, name := range names {
+		all[i] = abis[name]
+	}
+	where, err := rules.ParseExpr(c.Filter.Where, all...)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return where, nil
+}
+
+// filterQuery selects the configured contracts and events.
+func (c *Config) filterQuery(abis map[string]*abi.ABI) (ethereum.FilterQuery, error) {
+	names := c.Filter.Contracts
+	if len(names) == 0 {
+		for name := range c.Contracts {
+			names = append(names, name)
+		}
+	}
+	var q ethereum.FilterQuery
+	for _, name := range names {
+		contract, ok := c.Contracts[name]
+		if !ok {
+			return q, fmt.Errorf("filter: unknown contract %q", name)
+		}
+		q.Addresses = append(q.Addresses, contract.Address)
+	}
+	if len(c.Filter.Events) == 0 {
+		return q, nil
+	}
+	var topic0 []common.Hash
+	for _, ev := range c.Filter.Events {
+		found := false
+		for _, name := range names {
+			a, ok := abis[name]
+			if !ok {
+				continue
+			}
+			if e, ok := a.Events[ev]; ok {
+				topic0 = append(topic0, e.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return q, fmt.Errorf("filter: event %q not in the ABI of any filtered contract", ev)
+		}
+	}
+	q.Topics = [][]common.Hash{topic0}
+	return q, nil
+}
+
+func (c *Config) loadEventLog(ctx context.Context, filter ethereum.FilterQuery) (*events.InMemoryEventLog, *events.DeltaCheckpoints, error) {
+	var l *events.InMemoryEventLog
+	var deltas *events.DeltaCheckpoints
+	var err error
+	switch {
+	case c.Checkpoint.Dir != "":
+		if deltas, err = events.OpenDeltaCheckpoints(c.Checkpoint.Dir); err != nil {
+			return nil, nil, err
+		}
+		if c.Checkpoint.SnapshotEvery > 0 {
+			deltas.SnapshotEvery = c.Checkpoint.SnapshotEvery
+		}
+		l, err = deltas.Load()
+	case c.Checkpoint.Path != "" && len(c.Checkpoint.TrustedKeys) > 0:
+		var keys []ed25519.PublicKey
+		for _, fn := range c.Checkpoint.TrustedKeys {
+			k, err := events.ReadPublicKey(fn)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, k)
+		}
+		l, err = events.LoadSignedCheckpoint(c.Checkpoint.Path, keys...)
+	case c.Checkpoint.Path != "":
+		l, err = events.LoadCheckpoint(c.Checkpoint.Path)
+	default:
+		err = os.ErrNotExist
+	}
+	if err == nil {
+		lf := l.Filter()
+		if !proto.Equal(events.FilterQueryToProto(&lf), events.FilterQueryToProto(&filter)) {
+			return nil, nil, fmt.Errorf("checkpoint has a different filter than the config")
+		}
+		return l, deltas, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	var head uint64
+	var client *ethrpc.Client
+	if c.startFromHead() || c.Streamer.SkipToDeployment {
+		if client, err = ethrpc.Dial(ctx, c.Node); err != nil {
+			return nil, nil, err
+		}
+		defer client.Close()
+	}
+	if c.startFromHead() {
+		if head, err = client.BlockNumber(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+	start, err := c.startBlock(head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Streamer.SkipToDeployment {
+		if start, err = events.ClampToDeployment(ctx, client, filter, start); err != nil {
+			return nil, nil, fmt.Errorf("skip_to_deployment: %w", err)
+		}
+	}
+	return events.NewInMemoryEventLog(start, filter), deltas, nil
+}
+
+func (sc *Sink) build(ctx context.Context, decoder *decode.Decoder) (events.Sink, error) {
+	switch sc.Type {
+	case "discord", "slack", "telegram":
+		n := &sinks.Notifier{
+			Ctx:         ctx,
+			Decoder:     decoder,
+			MinInterval: sc.MinInterval,
+		}
+		switch sc.Type {
+		case "discord":
+			n.Webhook = &sinks.DiscordWebhook{URL: sc.URL}
+		case "slack":
+			n.Webhook = &sinks.SlackWebhook{URL: sc.URL}
+		case "telegram":
+			n.Webhook = &sinks.TelegramWebhook{BaseURL: sc.URL, Token: sc.Token, ChatID: sc.ChatID}
+		}
+		if sc.Template != "" {
+			t, err := template.New("notice").Parse(sc.Template)
+			if err != nil {
+				return nil, err
+			}
+			n.Template = t
+		}
+		if sc.Rules != "" {
+			cfg, err := rules.LoadConfig(sc.Rules)
+			if err != nil {
+				return nil, err
+			}
+			engine, err := rules.NewEngine(cfg, func(*rules.Alert) error { return nil })
+			if err != nil {
+				return nil, err
+			}
+			n.Decoder = engine.Decoder()
+			n.Match = engine.Matches
+		}
+		return n, nil
+	case "mqtt":
+		af := events.LowercaseAddress
+		if sc.AddressFormat != "" {
+			var err error
+			if af, err = events.ParseAddressFormat(sc.AddressFormat); err != nil {
+				return nil, err
+			}
+		}
+		s := &sinks.MQTTSink{
+			ChecksumAddresses: af == events.ChecksumAddress,
+			Broker:            sc.URL,
+			ClientID:          sc.ClientID,
+			Username:          sc.Username,
+			Password:          sc.Password,
+			QoS:               sc.QoS,
+		}
+		if sc.Topic != "" {
+			t, err := template.New("topic").Parse(sc.Topic)
+			if err != nil {
+				return nil, err
+			}
+			s.Topic = t
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+}
+
+// Checkpoint writes a checkpoint of the eventlog.
+func (p *Pipeline) Checkpoint() error {
+	if !p.Config.Checkpoint.enabled() {
+		return fmt.Errorf("no checkpoint path configured")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	if p.deltas != nil {
+		err = p.deltas.Save(p.EventLog)
+	} else {
+		err = events.SaveCheckpointWith(p.EventLog, p.Config.Checkpoint.Path, events.CheckpointOptions{
+			DedupData:  p.Config.Checkpoint.DedupData,
+			SigningKey: p.signingKey,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	p.lastCheckpoint = p.EventLog.NextBlock()
+	return nil
+}
+
+// checkpointed returns the next block at the last checkpoint, which
+// Checkpoint may set from another goroutine.
+func (p *Pipeline) checkpointed() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastCheckpoint
+}
+
+// Run streams new blocks into the eventlog and the sinks until done is
+// closed or an error occurs. A final checkpoint is written on return.
+func (p *Pipeline) Run(done chan struct{}) error {
+	locked := &lockedEventLog{InMemoryEventLog: p.EventLog, mu: &p.mu}
+	if p.deltas != nil {
+		locked.record = p.deltas
+	}
+	live := events.NewLiveEventLog(locked, p.Streamer)
+	live.MaxRestarts = p.Config.Streamer.Restarts
+	live.RestartDelay = p.Config.Streamer.RestartDelay
+	live.OnAhead = p.OnAhead
+	sub, err := live.Stream(done, p.EventLog.NextBlock())
+	if err != nil {
+		return err
+	}
+	err = p.consume(sub)
+	if p.Config.Checkpoint.enabled() {
+		if cerr := p.Checkpoint(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (p *Pipeline) consume(sub *events.Subscription) error {
+	for m := range sub.C {
+		sm := p.matching(m)
+		for _, s := range p.Sinks {
+			if err := events.Apply(s, sm); err != nil {
+				return err
+			}
+		}
+		if m.Action != events.SetNext {
+			continue
+		}
+		if p.Config.Retention > 0 && m.Number > p.Config.Retention {
+			p.mu.Lock()
+			err := p.EventLog.Prune(m.Number - p.Config.Retention)
+			if p.deltas != nil {
+				p.deltas.Prune(m.Number - p.Config.Retention)
+			}
+			p.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		if p.Config.TxDataRetention > 0 && m.Number > p.Config.TxDataRetention {
+			p.mu.Lock()
+			p.EventLog.StripTxData(m.Number-p.Config.TxDataRetention, p.Config.TxDataHash)
+			p.mu.Unlock()
+		}
+		if p.Config.Checkpoint.enabled() && m.Number >= p.checkpointed()+p.Config.Checkpoint.Every {
+			if err := p.Checkpoint(); err != nil {
+				return err
+			}
+			log.Printf("checkpoint at block %d\n", m.Number)
+		}
+	}
+	return <-sub.Err
+}
+
+// matching returns m with the events not matching the where expression
+// removed, or a SetNext past the block if none matches. The eventlog keeps
+// all events.
+func (p *Pipeline) matching(m *events.Message) *events.Message {
+	if p.Where == nil {
+		return m
+	}
+	return p.Where.SelectMessage(m)
+}
+
+// lockedEventLog serializes writes to an eventlog with checkpointing. The
+// writes are also applied to record, if set, under the same lock.
+type lockedEventLog struct {
+	*events.InMemoryEventLog
+	mu     *sync.Mutex
+	record events.Sink
+}
+
+func (l *lockedEventLog) Append(b *events.Block) error {
+	return l.apply(&events.Message{Action: events.Append, Block: b})
+}
+
+func (l *lockedEventLog) Rollback(n uint64) error {
+	return l.apply(&events.Message{Action: events.Rollback, Number: n})
+}
+
+func (l *lockedEventLog) SetNext(n uint64) error {
+	return l.apply(&events.Message{Action: events.SetNext, Number: n})
+}
+
+func (l *lockedEventLog) apply(m *events.Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := events.Apply(l.InMemoryEventLog, m); err != nil {
+		return err
+	}
+	if l.record != nil {
+		return events.Apply(l.record, m)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package decode
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// FormatAmount renders a raw token amount in token units, e.g. 1500000 with
+// 6 decimals as "1.5". It is exact: the digits are shifted, not converted
+// to floating point. Trailing zeros of the fraction are dropped.
+func FormatAmount(x *big.Int, decimals uint8) string {
+	if x == nil {
+		return "<nil>"
+	}
+	s := new(big.Int).Abs(x).String()
+	sign := ""
+	if x.Sign() < 0 {
+		sign = "-"
+	}
+	d := int(decimals)
+	if len(s) <= d {
+		s = strings.Repeat("0", d-len(s)+1) + s
+	}
+	whole, frac := s[:len(s)-d], strings.TrimRight(s[len(s)-d:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// ParseAmount parses an amount in token units, like "1.5", to a raw amount.
+// More fractional digits than decimals is an error, as the amount would
+// have to be rounded.
+func ParseAmount(s string, decimals uint8) (*big.Int, error) {
+	t := strings.TrimSpace(s)
+	neg := strings.HasPrefix(t, "-")
+	t = strings.TrimPrefix(strings.TrimPrefix(t, "-"), "+")
+	whole, frac := t, ""
+	if i := strings.IndexByte(t, '.'); i >= 0 {
+		whole, frac = t[:i], t[i+1:]
+	}
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("amount %q has %d fractional digits; want at most %d", s, len(frac), decimals)
+	}
+	x, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", int(decimals)-len(frac)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		x.Neg(x)
+	}
+	return x, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AmountFloat returns a raw token amount in token units as a big.Float,
+// e.g. for arithmetic, with enough precision that the integer part is
+// exact. Use FormatAmount for display.
+func AmountFloat(x *big.Int, decimals uint8) *big.Float {
+	prec := uint(x.BitLen()) + 64
+	f := new(big.Float).SetPrec(prec).SetInt(x)
+	scale := new(big.Float).SetPrec(prec).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	return f.Quo(f, scale)
+}
+
+// Amount returns an amount argument of the event in token units, followed
+// by the token symbol, if the event has a Token.
+func (de *Event) Amount(arg string) (string, bool) {
+	if de.Token == nil {
+		return "", false
+	}
+	v, ok := de.Args[arg].(*big.Int)
+	if !ok {
+		return "", false
+	}
+	s := FormatAmount(v, de.Token.Decimals)
+	if de.Token.Symbol != "" {
+		s += " " + de.Token.Symbol
+	}
+	return s, true
+}
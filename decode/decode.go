@@ -0,0 +1,141 @@
+// Package decode turns raw events into named events with decoded arguments,
+// using the events of one or more contract ABIs.
+package decode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// ErrUnknownEvent is returned by Decode when no ABI event matches.
+var ErrUnknownEvent = errors.New("unknown event")
+
+// ERC20ABI contains the events of the ERC-20 token standard.
+const ERC20ABI = `[
+{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"},
+{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Approval","type":"event"}
+]`
+
+// Event is an events.Event together with its decoded name and arguments.
+type Event struct {
+	*events.Event
+	Name string
+	Args map[string]interface{}
+
+	// Token is the metadata of the emitting token, if set by a
+	// TokenResolver.
+	Token *Token
+}
+
+// ParseABI parses a JSON contract ABI.
+func ParseABI(s string) (*abi.ABI, error) {
+	a, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Builtin returns one of the ABIs shipped with this package by name. The
+// only name currently known is "erc20".
+func Builtin(name string) (*abi.ABI, error) {
+	switch strings.ToLower(name) {
+	case "erc20":
+		return ParseABI(ERC20ABI)
+	}
+	return nil, fmt.Errorf("unknown builtin abi %q", name)
+}
+
+// LoadABI returns a builtin ABI by name, or else reads it from a file.
+func LoadABI(nameOrPath string) (*abi.ABI, error) {
+	if a, err := Builtin(nameOrPath); err == nil {
+		return a, nil
+	}
+	bs, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseABI(string(bs))
+}
+
+// Decoder decodes events using the events of a set of ABIs.
+type Decoder struct {
+	events map[common.Hash][]abi.Event
+}
+
+func NewDecoder(abis ...*abi.ABI) *Decoder {
+	d := &Decoder{
+		events: make(map[common.Hash][]abi.Event),
+	}
+	for _, a := range abis {
+		d.Add(a)
+	}
+	return d
+}
+
+// Add adds the events of an ABI to the decoder.
+func (d *Decoder) Add(a *abi.ABI) {
+	for _, ev := range a.Events {
+		if ev.Anonymous {
+			continue
+		}
+		d.events[ev.ID] = append(d.events[ev.ID], ev)
+	}
+}
+
+// Decode decodes an event. Events sharing a signature (e.g. the ERC-20 and
+// ERC-721 Transfer) are told apart by their number of indexed arguments.
+func (d *Decoder) Decode(e *events.Event) (*Event, error) {
+	if len(e.Topics) == 0 {
+		return nil, ErrUnknownEvent
+	}
+	for _, ev := range d.events[e.Topics[0]] {
+		var indexed abi.Arguments
+		for _, arg := range ev.Inputs {
+			if arg.Indexed {
+				indexed = append(indexed, arg)
+			}
+		}
+		if len(indexed) != len(e.Topics)-1 {
+			continue
+		}
+		args := make(map[string]interface{})
+		if len(e.Data) > 0 {
+			if err := ev.Inputs.NonIndexed().UnpackIntoMap(args, e.Data); err != nil {
+				return nil, fmt.Errorf("decoding %s data: %w", ev.Name, err)
+			}
+		}
+		if err := abi.ParseTopicsIntoMap(args, indexed, e.Topics[1:]); err != nil {
+			return nil, fmt.Errorf("decoding %s topics: %w", ev.Name, err)
+		}
+		return &Event{
+			Event: e,
+			Name:  ev.Name,
+			Args:  args,
+		}, nil
+	}
+	return nil, ErrUnknownEvent
+}
+
+// DecodeBlock decodes all events of a block, skipping unknown events.
+func (d *Decoder) DecodeBlock(b *events.Block) ([]*Event, error) {
+	decoded := make([]*Event, 0, len(b.Events))
+	for i := range b.Events {
+		de, err := d.Decode(&b.Events[i])
+		if errors.Is(err, ErrUnknownEvent) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, de)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,102 @@
+package decode
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// String renders a decoded event as Name(arg=value, ...), or its first topic
+// if it was not decoded. Addresses are EIP-55 checksummed.
+func (de *Event) String() string {
+	return de.Render(events.ChecksumAddress)
+}
+
+// Render is like String, writing address arguments in the given format. The
+// value of events with a Token is written in token units.
+func (de *Event) Render(af events.AddressFormat) string {
+	if de.Name == "" {
+		if len(de.Topics) == 0 {
+			return "(anonymous)"
+		}
+		return de.Topics[0].Hex()
+	}
+	keys := make([]string, 0, len(de.Args))
+	for k := range de.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := FormatArgs(de.Args, af)
+	if s, ok := de.Amount("value"); ok {
+		args["value"] = s
+	}
+	for i, k := range keys {
+		keys[i] = fmt.Sprintf("%s=%v", k, args[k])
+	}
+	return fmt.Sprintf("%s(%s)", de.Name, strings.Join(keys, ", "))
+}
+
+// FormatArgs returns a copy of decoded arguments with addresses replaced by
+// strings in the given format, e.g. for JSON output, where common.Address
+// would otherwise be lowercase.
+func FormatArgs(args map[string]interface{}, af events.AddressFormat) map[string]interface{} {
+	if args == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if a, ok := v.(common.Address); ok {
+			v = af.Format(a)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// FormatJSONArgs is like FormatArgs, also replacing byte string arguments
+// by strings in the given encoding and big integers by decimal strings, which
+// JSON readers parsing numbers as doubles would round.
+func FormatJSONArgs(args map[string]interface{}, af events.AddressFormat, enc events.BytesEncoding) map[string]interface{} {
+	out := FormatArgs(args, af)
+	for k, v := range out {
+		switch v := v.(type) {
+		case *big.Int:
+			out[k] = v.String()
+		case []byte:
+			out[k] = enc.Encode(v)
+		default:
+			// bytes1..bytes32 decode to byte arrays.
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+				bs := make([]byte, rv.Len())
+				reflect.Copy(reflect.ValueOf(bs), rv)
+				out[k] = enc.Encode(bs)
+			}
+		}
+	}
+	return out
+}
+
+// Describe returns a function rendering events the decoder knows by name
+// and arguments, for events.BlockFormat.
+func (d *Decoder) Describe(af events.AddressFormat) func(*events.Event) string {
+	return func(e *events.Event) string {
+		de, err := d.Decode(e)
+		if err != nil {
+			de = &Event{Event: e}
+		}
+		return de.Render(af)
+	}
+}
+
+// FormatBlock is like events.FormatBlock, but renders the events the decoder
+// knows by name and arguments.
+func (d *Decoder) FormatBlock(b *events.Block, verbose bool) string {
+	return events.FormatBlockWith(b, verbose, d.Describe(events.ChecksumAddress))
+}
@@ -0,0 +1,117 @@
+package decode
+
+import (
+	"runtime"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// DecodedMessage is a stream message together with the decoded events of
+// its block, if it is an Append.
+type DecodedMessage struct {
+	*events.Message
+	Decoded []*Event
+}
+
+// DecodedSubscription is like events.Subscription, for decoded messages.
+type DecodedSubscription struct {
+	C    chan *DecodedMessage
+	Err  chan error
+	Done chan struct{}
+}
+
+// Pool decodes the blocks of a stream on several goroutines, and emits them
+// in the order of the stream.
+type Pool struct {
+	Streamer events.Streamer
+	Decoder  *Decoder
+	Workers  int // defaults to runtime.NumCPU()
+}
+
+type decodeResult struct {
+	m       *events.Message
+	decoded []*Event
+	err     error
+}
+
+type decodeJob struct {
+	m *events.Message
+	r chan decodeResult
+}
+
+func (p *Pool) Stream(done chan struct{}, from uint64) (*DecodedSubscription, error) {
+	sub, err := p.Streamer.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	c := make(chan *DecodedMessage)
+	errc := make(chan error, 1)
+	jobs := make(chan decodeJob)
+	order := make(chan chan decodeResult, workers)
+	quit := make(chan struct{})
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				decoded, err := p.Decoder.DecodeBlock(j.m.Block)
+				j.r <- decodeResult{m: j.m, decoded: decoded, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		for m := range sub.C {
+			r := make(chan decodeResult, 1)
+			select {
+			case order <- r:
+			case <-quit:
+				return
+			}
+			if m.Action != events.Append {
+				r <- decodeResult{m: m}
+				continue
+			}
+			select {
+			case jobs <- decodeJob{m: m, r: r}:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		err := p.forward(c, done, order)
+		if err != nil {
+			close(quit)
+		} else {
+			err = <-sub.Err
+		}
+		close(c)
+		errc <- err
+	}()
+
+	return &DecodedSubscription{C: c, Err: errc, Done: done}, nil
+}
+
+// forward sends the decoded messages in stream order.
+func (p *Pool) forward(c chan *DecodedMessage, done chan struct{}, order chan chan decodeResult) error {
+	for r := range order {
+		res := <-r
+		if res.err != nil {
+			return res.err
+		}
+		select {
+		case c <- &DecodedMessage{Message: res.m, Decoded: res.decoded}:
+		case <-done:
+			return events.Canceled
+		}
+	}
+	return nil
+}
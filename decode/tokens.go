@@ -0,0 +1,170 @@
+package decode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// ErrNotToken is returned by TokenResolver.Resolve for contracts without
+// ERC-20 decimals.
+var ErrNotToken = errors.New("not an ERC-20 token")
+
+// Token is the ERC-20 metadata of a contract.
+type Token struct {
+	Address  common.Address `json:"address"`
+	Name     string         `json:"name,omitempty"`
+	Symbol   string         `json:"symbol,omitempty"`
+	Decimals uint8          `json:"decimals"`
+}
+
+var (
+	selectorName     = []byte{0x06, 0xfd, 0xde, 0x03} // name()
+	selectorSymbol   = []byte{0x95, 0xd8, 0x9b, 0x41} // symbol()
+	selectorDecimals = []byte{0x31, 0x3c, 0xe5, 0x67} // decimals()
+)
+
+// TokenResolver fetches the ERC-20 name, symbol and decimals of contracts,
+// and caches them, including the contracts whose decimals are malformed.
+// Failed calls are not cached, as they may be transient. The metadata is
+// read at the latest block, as it is not expected to change. It is safe
+// for concurrent use.
+type TokenResolver struct {
+	Ctx    context.Context
+	Client events.CallClient
+
+	mu     sync.Mutex
+	tokens map[common.Address]*Token // nil for contracts that are not tokens
+}
+
+func NewTokenResolver(ctx context.Context, client events.CallClient) *TokenResolver {
+	return &TokenResolver{
+		Ctx:    ctx,
+		Client: client,
+		tokens: make(map[common.Address]*Token),
+	}
+}
+
+// Resolve returns the metadata of a token. Contracts whose decimals() call
+// reverts or returns no uint8 get an ErrNotToken error; other call errors
+// are returned as is, and not cached. Name and symbol are optional.
+func (r *TokenResolver) Resolve(a common.Address) (*Token, error) {
+	r.mu.Lock()
+	t, ok := r.tokens[a]
+	r.mu.Unlock()
+	if ok {
+		if t == nil {
+			return nil, ErrNotToken
+		}
+		return t, nil
+	}
+
+	t, err := r.fetch(a)
+	if err != nil && !errors.Is(err, ErrNotToken) {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.tokens[a] = t
+	r.mu.Unlock()
+	return t, err
+}
+
+func (r *TokenResolver) fetch(a common.Address) (*Token, error) {
+	call := func(selector []byte) ([]byte, error) {
+		return r.Client.CallContract(r.Ctx, ethereum.CallMsg{To: &a, Data: selector}, nil)
+	}
+	bs, err := call(selectorDecimals)
+	if err != nil {
+		if r.Ctx.Err() != nil {
+			return nil, r.Ctx.Err()
+		}
+		if !isExecutionError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNotToken, err)
+	}
+	if len(bs) != 32 || !isZero(bs[:31]) {
+		return nil, ErrNotToken
+	}
+	t := &Token{Address: a, Decimals: bs[31]}
+	if bs, err := call(selectorName); err == nil {
+		t.Name = decodeTokenString(bs)
+	}
+	if bs, err := call(selectorSymbol); err == nil {
+		t.Symbol = decodeTokenString(bs)
+	}
+	return t, nil
+}
+
+// isExecutionError reports whether a call failed in the EVM, e.g. reverted
+// as the contract has no such function, rather than failing to reach the
+// node or being refused by it, which may be transient.
+func isExecutionError(err error) bool {
+	var rerr rpc.Error
+	if !errors.As(err, &rerr) {
+		return false
+	}
+	msg := strings.ToLower(rerr.Error())
+	return rerr.ErrorCode() == 3 || strings.Contains(msg, "revert") || strings.Contains(msg, "invalid opcode")
+}
+
+// decodeTokenString decodes a string return value, or the bytes32 some
+// early tokens return instead.
+func decodeTokenString(bs []byte) string {
+	if len(bs) == 32 {
+		n := 0
+		for n < 32 && bs[n] != 0 {
+			n++
+		}
+		return string(bs[:n])
+	}
+	typ, err := abi.NewType("string", "", nil)
+	if err != nil {
+		return ""
+	}
+	vs, err := abi.Arguments{{Type: typ}}.Unpack(bs)
+	if err != nil || len(vs) != 1 {
+		return ""
+	}
+	s, _ := vs[0].(string)
+	return s
+}
+
+func isZero(bs []byte) bool {
+	for _, b := range bs {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Annotate sets the Token of decoded ERC-20 Transfer and Approval events,
+// so their value can be shown in token units. Events of contracts that are
+// not tokens are left alone.
+func (r *TokenResolver) Annotate(de *Event) error {
+	if de.Name != "Transfer" && de.Name != "Approval" {
+		return nil
+	}
+	if _, ok := de.Args["value"]; !ok {
+		return nil
+	}
+	t, err := r.Resolve(de.Address)
+	if errors.Is(err, ErrNotToken) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("resolving token %s: %w", de.Address.Hex(), err)
+	}
+	de.Token = t
+	return nil
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Topic returns topic i of the event, and whether it exists. For events of
+// non-anonymous Solidity events, topic 0 is the event signature hash and the
+// following topics are the indexed arguments.
+func (e *Event) Topic(i int) (common.Hash, bool) {
+	if i < 0 || i >= len(e.Topics) {
+		return common.Hash{}, false
+	}
+	return e.Topics[i], true
+}
+
+// AddressFromTopic returns topic i as an address, e.g. the from and to of
+// an ERC20 Transfer (topics 1 and 2).
+func (e *Event) AddressFromTopic(i int) (common.Address, bool) {
+	t, ok := e.Topic(i)
+	if !ok {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(t.Bytes()), true
+}
+
+// U256FromTopic returns topic i as an unsigned integer.
+func (e *Event) U256FromTopic(i int) (*big.Int, bool) {
+	t, ok := e.Topic(i)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(t.Bytes()), true
+}
+
+// word returns the 32 byte word at a byte offset of the data.
+func (e *Event) word(offset int) ([]byte, bool) {
+	if offset < 0 || offset+32 > len(e.Data) {
+		return nil, false
+	}
+	return e.Data[offset : offset+32], true
+}
+
+// U256FromData returns the unsigned integer at a byte offset of the data,
+// e.g. the value of an ERC20 Transfer at offset 0. Non-indexed arguments of
+// static types take 32 bytes each.
+func (e *Event) U256FromData(offset int) (*big.Int, bool) {
+	w, ok := e.word(offset)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(w), true
+}
+
+// AddressFromData returns the address at a byte offset of the data.
+func (e *Event) AddressFromData(offset int) (common.Address, bool) {
+	w, ok := e.word(offset)
+	if !ok {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(w), true
+}
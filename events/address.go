@@ -0,0 +1,35 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressFormat selects how addresses are written as text.
+type AddressFormat int
+
+const (
+	ChecksumAddress  AddressFormat = iota // EIP-55 mixed case
+	LowercaseAddress                      // all lowercase
+)
+
+// Format renders an address as 0x-prefixed hex.
+func (f AddressFormat) Format(a common.Address) string {
+	if f == LowercaseAddress {
+		return strings.ToLower(a.Hex())
+	}
+	return a.Hex()
+}
+
+// ParseAddressFormat parses "checksum" or "lowercase"; "" is checksum.
+func ParseAddressFormat(s string) (AddressFormat, error) {
+	switch s {
+	case "", "checksum":
+		return ChecksumAddress, nil
+	case "lowercase":
+		return LowercaseAddress, nil
+	}
+	return 0, fmt.Errorf("unknown address format %q; want checksum or lowercase", s)
+}
@@ -0,0 +1,50 @@
+package events
+
+import "fmt"
+
+// AheadOfChainError is returned when streaming from a block more than one
+// beyond the chain head, e.g. for a restored eventlog on the wrong node or
+// after a devnet reset, where getLogs would fetch a nonsense range.
+type AheadOfChainError struct {
+	From uint64
+	Head uint64
+}
+
+func (e *AheadOfChainError) Error() string {
+	return fmt.Sprintf("got from=%d beyond the chain head %d; check the node is synced and on the right chain, or set WaitForFrom", e.From, e.Head)
+}
+
+// AheadPolicy is what a LiveEventLog does when its EventLog ends beyond
+// the chain head.
+type AheadPolicy int
+
+const (
+	AheadError    AheadPolicy = iota // fail with an AheadOfChainError
+	AheadWait                        // wait for the chain to catch up
+	AheadRollback                    // roll the eventlog back to the head
+)
+
+func (p AheadPolicy) String() string {
+	switch p {
+	case AheadError:
+		return "error"
+	case AheadWait:
+		return "wait"
+	case AheadRollback:
+		return "rollback"
+	}
+	return fmt.Sprintf("AheadPolicy(%d)", int(p))
+}
+
+// ParseAheadPolicy parses "error", "wait" or "rollback"; "" is error.
+func ParseAheadPolicy(s string) (AheadPolicy, error) {
+	switch s {
+	case "", "error":
+		return AheadError, nil
+	case "wait":
+		return AheadWait, nil
+	case "rollback":
+		return AheadRollback, nil
+	}
+	return 0, fmt.Errorf("unknown ahead policy %q; want error, wait or rollback", s)
+}
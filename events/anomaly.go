@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// InvalidLogRetries is the number of times a getLogs call returning invalid
+// logs is retried, waiting InvalidLogRetryDelay in between.
+const (
+	InvalidLogRetries    = 3
+	InvalidLogRetryDelay = 2 * time.Second
+)
+
+// LogAnomaly describes what was wrong with the logs of a getLogs call for
+// the blocks From..To (inclusive), before they were repaired.
+type LogAnomaly struct {
+	From, To   uint64
+	Unsorted   bool // not in order of block number and log index
+	Duplicates int  // logs returned more than once
+	Removed    int  // logs marked as removed by a reorg
+
+	// Quarantined holds the logs with a zero block hash or number, or
+	// outside the range, of the last call that was retried because of them.
+	Quarantined []types.Log
+	Retries     int
+}
+
+func (a LogAnomaly) any() bool {
+	return a.Unsorted || a.Duplicates > 0 || a.Removed > 0 || a.Retries > 0
+}
+
+func (a LogAnomaly) String() string {
+	return fmt.Sprintf("getLogs %d:%d returned unsorted=%v duplicates=%d removed=%d invalid=%d (retries %d)",
+		a.From, a.To, a.Unsorted, a.Duplicates, a.Removed, len(a.Quarantined), a.Retries)
+}
+
+// filterValidLogs calls FilterLogs until it returns no invalid logs, at most
+// InvalidLogRetries more times. It returns the valid logs, along with the
+// invalid logs of the last retried call and the number of retries. Block
+// timestamps reported by the client are added to times.
+func filterValidLogs(ctx context.Context, client Client, q ethereum.FilterQuery, from, to uint64, times map[common.Hash]uint64) ([]types.Log, []types.Log, int, error) {
+	var quarantined []types.Log
+	for retries := 0; ; retries++ {
+		logs, err := filterLogs(ctx, client, q, times)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		var invalid []types.Log
+		for _, l := range logs {
+			if l.BlockHash == (common.Hash{}) || l.BlockNumber == 0 || l.BlockNumber < from || l.BlockNumber > to {
+				invalid = append(invalid, l)
+			}
+		}
+		if len(invalid) == 0 {
+			return logs, quarantined, retries, nil
+		}
+		if retries == InvalidLogRetries {
+			return nil, nil, 0, fmt.Errorf("getLogs %d:%d: got %d logs with zero block hash or number or outside the range, %d times",
+				from, to, len(invalid), retries+1)
+		}
+		quarantined = invalid
+		logf(ctx, "warning: getLogs %d:%d: got %d invalid logs, retrying\n", from, to, len(invalid))
+		select {
+		case <-ctx.Done():
+			return nil, nil, 0, ctx.Err()
+		case <-time.After(InvalidLogRetryDelay):
+		}
+	}
+}
+
+// canonicalLogs sorts logs by block number and index, dropping duplicates
+// and removed logs. It fails if logs of one block number have different
+// block hashes, or a log index is used by two different logs, as the batch
+// then mixes versions of the chain.
+func canonicalLogs(logs []types.Log) ([]types.Log, LogAnomaly, error) {
+	var a LogAnomaly
+	less := func(i, j int) bool {
+		if logs[i].BlockNumber == logs[j].BlockNumber {
+			return logs[i].Index < logs[j].Index
+		}
+		return logs[i].BlockNumber < logs[j].BlockNumber
+	}
+	if !sort.SliceIsSorted(logs, less) {
+		a.Unsorted = true
+		sort.SliceStable(logs, less)
+	}
+	out := logs[:0]
+	for _, l := range logs {
+		if l.Removed {
+			a.Removed++
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].BlockNumber == l.BlockNumber {
+			prev := &out[n-1]
+			if prev.BlockHash != l.BlockHash {
+				return nil, a, fmt.Errorf("got logs of block %d with hashes %s and %s", l.BlockNumber, prev.BlockHash.Hex(), l.BlockHash.Hex())
+			}
+			if prev.Index == l.Index {
+				if prev.TxHash != l.TxHash || prev.Address != l.Address || !sameLogData(prev, &l) {
+					return nil, a, fmt.Errorf("got different logs with index %d in block %d", l.Index, l.BlockNumber)
+				}
+				a.Duplicates++
+				continue
+			}
+		}
+		out = append(out, l)
+	}
+	return out, a, nil
+}
+
+func sameLogData(a, b *types.Log) bool {
+	if len(a.Topics) != len(b.Topics) || string(a.Data) != string(b.Data) {
+		return false
+	}
+	for i := range a.Topics {
+		if a.Topics[i] != b.Topics[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,400 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BackfillProgress reports how far a Backfill has come.
+type BackfillProgress struct {
+	From uint64 // first block of the range
+	To   uint64 // last block of the range
+	Next uint64 // next block to fetch; From plus the blocks fetched
+
+	// Rate is the smoothed fetch rate in blocks per second, over this and
+	// earlier Fetch calls of the Backfill, and ETA the time to reach To at
+	// that rate. Both are zero until known.
+	Rate float64
+	ETA  time.Duration
+
+	// Priority is set during the pass over the Priority addresses.
+	Priority bool
+}
+
+// Backfill fetches a historical block range with a series of eth_getLogs
+// calls. Unlike ChainStreamer it does not follow the chain head, and does not
+// check for reorganizations, so the range should be well behind head.
+type Backfill struct {
+	Ctx            context.Context
+	Client         Client
+	Filter         ethereum.FilterQuery
+	FetchBatchSize uint64
+	FetchTxDetails bool
+	TrackHeaders   bool
+	EventsRoots    bool
+
+	// BlockTimestamps sets the Time of every block; see ChainStreamer.
+	BlockTimestamps bool
+
+	// Scheduler, if set, decides the order in which the range of a Fetch
+	// is fetched; by default it is fetched oldest first.
+	Scheduler Scheduler
+
+	// Interval is the minimum time between getLogs calls, to stay within
+	// the rate limits of a provider.
+	Interval time.Duration
+
+	// Progress, if set, is called after every batch.
+	Progress func(BackfillProgress)
+
+	// Priority, if set, holds addresses of the filter whose events Fetch
+	// gets first, over the whole range, before those of the other
+	// addresses. OnPriority, if set, is then called with the events of the
+	// priority addresses, so that features depending on them can come
+	// online before the long tail of history is fetched. An error from
+	// OnPriority ends the Fetch.
+	Priority   []common.Address
+	OnPriority func(*BlockSlice) error
+
+	// Interner, if set, deduplicates the TxData and Topics of the events.
+	Interner *Interner
+
+	// OnLogAnomaly, if set, is called instead of logging a warning when
+	// the node returns logs that had to be repaired; see GetLogs.
+	OnLogAnomaly func(LogAnomaly)
+
+	// SkipToDeployment makes Fetch skip the blocks before the deployment
+	// of the filter's addresses, which it finds with DeploymentBlock on
+	// its first call. This needs an archive node.
+	SkipToDeployment bool
+
+	mu            sync.Mutex // guards the fields below, as NewestFirst runs concurrently
+	lastCall      time.Time
+	rate          rateEstimator
+	deployed      uint64
+	deployedKnown bool
+}
+
+// Fetch returns the blocks from..to (inclusive) matching the filter. If the
+// node does not serve block from, it returns an EarliestBlockError.
+func (bf *Backfill) Fetch(from, to uint64) (*BlockSlice, error) {
+	if to < from {
+		return nil, fmt.Errorf("got to=%d; want to >= %d", to, from)
+	}
+	start := from
+	if bf.SkipToDeployment {
+		n, err := bf.deploymentBlock()
+		if err != nil {
+			return nil, fmt.Errorf("deployment block: %w", err)
+		}
+		if n > to {
+			slice := EmptyBlockSlice(from)
+			slice.End = to + 1
+			return slice, nil
+		}
+		if n > start {
+			start = n
+		}
+	}
+	slice, err := bf.fetchAll(start, to)
+	if err != nil {
+		return nil, err
+	}
+	slice.Start = from
+	return slice, nil
+}
+
+// deploymentBlock returns the earliest deployment block of the filter's
+// addresses, looking it up on the first call.
+func (bf *Backfill) deploymentBlock() (uint64, error) {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+	if !bf.deployedKnown {
+		n, err := ClampToDeployment(bf.Ctx, bf.Client, bf.Filter, 0)
+		if err != nil {
+			return 0, err
+		}
+		bf.deployed, bf.deployedKnown = n, true
+	}
+	return bf.deployed, nil
+}
+
+// fetchAll fetches from..to, the Priority addresses first.
+func (bf *Backfill) fetchAll(from, to uint64) (*BlockSlice, error) {
+	batchSize := bf.FetchBatchSize
+	if batchSize == 0 {
+		batchSize = DefaultFetchBatchSize
+	}
+
+	if err := bf.checkPriority(); err != nil {
+		return nil, err
+	}
+	if err := checkAvailable(bf.Ctx, bf.Client, from); err != nil {
+		return nil, err
+	}
+	if len(bf.Priority) == 0 {
+		return bf.fetch(from, to, batchSize, bf.Scheduler, bf.Filter, false, nil)
+	}
+
+	first := bf.Filter
+	first.Addresses = bf.Priority
+	prio, err := bf.fetch(from, to, batchSize, bf.Scheduler, first, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	if bf.OnPriority != nil {
+		if err := bf.OnPriority(prio); err != nil {
+			return nil, err
+		}
+	}
+	// Without addresses the filter matches every address, so the second
+	// pass fetches everything and replaces the first.
+	rest := bf.Filter
+	if len(rest.Addresses) == 0 {
+		return bf.fetch(from, to, batchSize, bf.Scheduler, rest, false, nil)
+	}
+	isPrio := make(map[common.Address]bool, len(bf.Priority))
+	for _, a := range bf.Priority {
+		isPrio[a] = true
+	}
+	rest.Addresses = nil
+	for _, a := range bf.Filter.Addresses {
+		if !isPrio[a] {
+			rest.Addresses = append(rest.Addresses, a)
+		}
+	}
+	if len(rest.Addresses) == 0 {
+		return prio, nil
+	}
+	other, err := bf.fetch(from, to, batchSize, bf.Scheduler, rest, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSlices(prio, other)
+}
+
+// checkPriority returns an error if a Priority address is not one of the
+// filter, as its events would be fetched although the filter excludes them.
+func (bf *Backfill) checkPriority() error {
+	if len(bf.Filter.Addresses) == 0 {
+		return nil
+	}
+	inFilter := make(map[common.Address]bool, len(bf.Filter.Addresses))
+	for _, a := range bf.Filter.Addresses {
+		inFilter[a] = true
+	}
+	for _, a := range bf.Priority {
+		if !inFilter[a] {
+			return fmt.Errorf("got Priority address %s; want one of the Filter addresses", a.Hex())
+		}
+	}
+	return nil
+}
+
+// pace waits until the next getLogs call is due, and reserves its slot.
+func (bf *Backfill) pace() error {
+	bf.mu.Lock()
+	at := time.Now()
+	if due := bf.lastCall.Add(bf.Interval); due.After(at) {
+		at = due
+	}
+	bf.lastCall = at
+	bf.mu.Unlock()
+	if wait := time.Until(at); wait > 0 {
+		select {
+		case <-bf.Ctx.Done():
+			return bf.Ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
+
+// observe records the position of a fetch for the rate, and returns it.
+func (bf *Backfill) observe(pos uint64) float64 {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+	bf.rate.observe(time.Now(), pos)
+	return bf.rate.rate
+}
+
+// fetch returns the blocks from..to matching filter, in the order of sched
+// (oldest first if nil). If emit is set, it passes every fetched range to
+// emit instead, and returns nil.
+func (bf *Backfill) fetch(from, to, batchSize uint64, sched Scheduler, filter ethereum.FilterQuery, priority bool, emit func(*BlockSlice) error) (*BlockSlice, error) {
+	if sched == nil {
+		sched = &SequentialScheduler{}
+	}
+	sched.Start(from, to, batchSize)
+	var fetched []*BlockSlice
+	done := uint64(0) // blocks fetched
+	bf.observe(from)
+	for {
+		next, end, ok := sched.Next()
+		if !ok {
+			break
+		}
+		if next < from || end > to || end < next {
+			return nil, fmt.Errorf("scheduler returned blocks %d..%d; want a range within %d..%d", next, end, from, to)
+		}
+		if err := bf.pace(); err != nil {
+			return nil, err
+		}
+
+		b, _, err := getLogs(bf.Ctx, bf.Client, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(next),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: filter.Addresses,
+			Topics:    filter.Topics,
+		}, bf.OnLogAnomaly)
+		if err != nil {
+			return nil, err
+		}
+		if b.End <= next {
+			return nil, fmt.Errorf("block %d is beyond the chain head", next)
+		}
+		if bf.FetchTxDetails {
+			if err := AddTransactionData(bf.Ctx, bf.Client, b); err != nil {
+				return nil, err
+			}
+			if n := countTxMissing(b); n > 0 {
+				logf(bf.Ctx, "warning: no transaction details for %d events in %d:%d; is the node pruned?\n", n, b.Start, b.End)
+			}
+		}
+		if bf.TrackHeaders {
+			if err := AddHeaderData(bf.Ctx, bf.Client, b); err != nil {
+				return nil, err
+			}
+		}
+		if bf.BlockTimestamps {
+			if err := AddBlockTimestamps(bf.Ctx, bf.Client, b); err != nil {
+				return nil, err
+			}
+		}
+		if bf.EventsRoots {
+			AddEventsRoots(b)
+		}
+		if bf.Interner != nil {
+			for _, blk := range b.Blocks {
+				bf.Interner.Block(blk)
+			}
+		}
+		sched.Fetched(b)
+		if emit != nil {
+			if err := emit(b); err != nil {
+				return nil, err
+			}
+		} else {
+			fetched = append(fetched, b)
+		}
+		done += b.End - b.Start
+		rate := bf.observe(from + done)
+		if bf.Progress != nil {
+			p := BackfillProgress{From: from, To: to, Next: from + done, Rate: rate, Priority: priority}
+			if from+done <= to {
+				p.ETA = ETA(to+1-from-done, p.Rate)
+			}
+			bf.Progress(p)
+		}
+	}
+
+	if emit != nil {
+		return nil, nil
+	}
+	sort.Slice(fetched, func(i, j int) bool { return fetched[i].Start < fetched[j].Start })
+	slice := EmptyBlockSlice(from)
+	for _, b := range fetched {
+		if b.Start != slice.End {
+			return nil, fmt.Errorf("scheduler fetched blocks %d:%d; want a range starting at %d, without gaps or overlaps", b.Start, b.End, slice.End)
+		}
+		if err := slice.Concat(b); err != nil {
+			return nil, err
+		}
+	}
+	if slice.End != to+1 {
+		return nil, fmt.Errorf("scheduler left blocks %d:%d unfetched", slice.End, to+1)
+	}
+	return slice, nil
+}
+
+// ReverseSubscription delivers the ranges of a newest-first backfill.
+type ReverseSubscription struct {
+	C   chan *BlockSlice // newest first
+	Err chan error
+}
+
+// NewestFirst fetches the blocks from..to (inclusive) from the newest
+// backwards, and sends each fetched range on the C of the subscription, so
+// that applications can show recent activity while deep history fills in.
+// The ranges do not overlap and come newest first, except that a range a
+// node returned only in part is followed by the rest of it. The
+// Scheduler and Priority of bf are ignored. Once done, C is closed and the
+// error, or nil, sent on Err. Fetches of bf running meanwhile share its
+// Interval.
+func (bf *Backfill) NewestFirst(done chan struct{}, from, to uint64) (*ReverseSubscription, error) {
+	if to < from {
+		return nil, fmt.Errorf("got to=%d; want to >= %d", to, from)
+	}
+	batchSize := bf.FetchBatchSize
+	if batchSize == 0 {
+		batchSize = DefaultFetchBatchSize
+	}
+	if err := checkAvailable(bf.Ctx, bf.Client, from); err != nil {
+		return nil, err
+	}
+	sub := &ReverseSubscription{C: make(chan *BlockSlice), Err: make(chan error, 1)}
+	go func() {
+		_, err := bf.fetch(from, to, batchSize, &NewestFirstScheduler{}, bf.Filter, false, func(s *BlockSlice) error {
+			select {
+			case <-done:
+				return Canceled
+			case sub.C <- s:
+				return nil
+			}
+		})
+		close(sub.C)
+		sub.Err <- err
+	}()
+	return sub, nil
+}
+
+// mergeSlices merges two slices of the same range fetched with different
+// filters, block by block.
+func mergeSlices(a, b *BlockSlice) (*BlockSlice, error) {
+	if a.Start != b.Start || a.End != b.End {
+		return nil, fmt.Errorf("got ranges %d:%d and %d:%d; want equal ranges", a.Start, a.End, b.Start, b.End)
+	}
+	out := EmptyBlockSlice(a.Start)
+	i, j := 0, 0
+	for i < len(a.Blocks) || j < len(b.Blocks) {
+		var blk *Block
+		switch {
+		case j == len(b.Blocks) || i < len(a.Blocks) && a.Blocks[i].Number < b.Blocks[j].Number:
+			blk = a.Blocks[i]
+			i++
+		case i == len(a.Blocks) || b.Blocks[j].Number < a.Blocks[i].Number:
+			blk = b.Blocks[j]
+			j++
+		default:
+			var err error
+			if blk, err = mergeBlocks([]*Block{a.Blocks[i], b.Blocks[j]}); err != nil {
+				return nil, err
+			}
+			i++
+			j++
+		}
+		if err := out.Append(blk); err != nil {
+			return nil, err
+		}
+	}
+	out.End = a.End
+	out.DistanceFromHead = a.DistanceFromHead
+	return out, nil
+}
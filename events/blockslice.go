@@ -1,12 +1,21 @@
 package events
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+)
 
 type BlockSlice struct {
 	Start            uint64
 	End              uint64
 	DistanceFromHead uint64
 	Blocks           []*Block
+
+	// Strict makes Append reject blocks that are inconsistent with
+	// themselves or with the stored chain; see Append.
+	Strict bool
 }
 
 func EmptyBlockSlice(from uint64) *BlockSlice {
@@ -17,10 +26,20 @@ func EmptyBlockSlice(from uint64) *BlockSlice {
 	}
 }
 
+// Append adds a block at or after End. In strict mode, the block's events
+// must also carry its number and hash, and if the block has a parent hash
+// and directly follows the last stored block, its parent must be that block.
+// A block after a gap is not checked, as its parent is not stored.
 func (b *BlockSlice) Append(blk *Block) error {
+	defer b.debugCheck("Append")
 	if blk.Number < b.End {
 		return fmt.Errorf("got blk.Number=%d; want blk.Number>=%d", blk.Number, b.End)
 	}
+	if b.Strict {
+		if err := b.checkAppend(blk); err != nil {
+			return err
+		}
+	}
 	b.Blocks = append(b.Blocks, blk)
 	b.End = blk.Number + 1
 	b.DistanceFromHead = 0
@@ -28,6 +47,7 @@ func (b *BlockSlice) Append(blk *Block) error {
 }
 
 func (b *BlockSlice) Concat(other *BlockSlice) error {
+	defer b.debugCheck("Concat")
 	if b.End != other.Start {
 		return fmt.Errorf("got other.Start=%d; want %d", other.Start, b.End)
 	}
@@ -37,18 +57,62 @@ func (b *BlockSlice) Concat(other *BlockSlice) error {
 	return nil
 }
 
-func (b *BlockSlice) Rollback(n uint64) error {
-	if n > b.End {
-		return fmt.Errorf("n=%d; want n <= %d", n, b.End)
+// RangeError reports a block number outside the range an operation accepts.
+type RangeError struct {
+	Op       string
+	N        uint64
+	Min, Max uint64 // inclusive; Max is math.MaxUint64 if unbounded
+}
+
+func (e *RangeError) Error() string {
+	if e.Max == math.MaxUint64 {
+		return fmt.Sprintf("%s: got n=%d; want n >= %d", e.Op, e.N, e.Min)
 	}
-	if n < b.Start {
-		return fmt.Errorf("n=%d; want n >= %d", n, b.Start)
+	return fmt.Sprintf("%s: got n=%d; want %d <= n <= %d", e.Op, e.N, e.Min, e.Max)
+}
+
+// Rollback drops the blocks from n on, so that n becomes the next block.
+// Rolling back to End does nothing. Rolling back to Start, or to any block
+// up to the first stored one, drops all blocks but keeps Start: the slice
+// is empty, with End == n. Rolling back before Start is an error, since the
+// slice does not know what came before it; use Reset to start over.
+func (b *BlockSlice) Rollback(n uint64) error {
+	defer b.debugCheck("Rollback")
+	if n < b.Start || n > b.End {
+		return &RangeError{Op: "Rollback", N: n, Min: b.Start, Max: b.End}
 	}
 	b.DeleteFromBlock(n)
 	return nil
 }
 
+// blocksFrom returns a copy of the list of blocks from n on.
+func (b *BlockSlice) blocksFrom(n uint64) []*Block {
+	i := len(b.Blocks)
+	for i > 0 && b.Blocks[i-1].Number >= n {
+		i--
+	}
+	return append([]*Block(nil), b.Blocks[i:]...)
+}
+
+// Reset drops all blocks and makes from both the first and next block.
+func (b *BlockSlice) Reset(from uint64) {
+	defer b.debugCheck("Reset")
+	b.Blocks = make([]*Block, 0)
+	b.Start = from
+	b.End = from
+	b.DistanceFromHead = 0
+}
+
+// DeleteBeforeBlock deletes the blocks before n, moving Start up to n. If n
+// is beyond End, End moves up too, leaving an empty slice.
 func (b *BlockSlice) DeleteBeforeBlock(n uint64) {
+	defer b.debugCheck("DeleteBeforeBlock")
+	if n <= b.Start {
+		return
+	}
+	if n > b.End {
+		b.End = n
+	}
 	var i int
 	for i = 0; i < len(b.Blocks); i++ {
 		if b.Blocks[i].Number >= n {
@@ -59,7 +123,16 @@ func (b *BlockSlice) DeleteBeforeBlock(n uint64) {
 	b.Start = n
 }
 
+// DeleteFromBlock deletes the blocks from n on, moving End down to n. If n
+// is before Start, End moves down to Start, leaving an empty slice.
 func (b *BlockSlice) DeleteFromBlock(n uint64) {
+	defer b.debugCheck("DeleteFromBlock")
+	if n >= b.End {
+		return
+	}
+	if n < b.Start {
+		n = b.Start
+	}
 	var i int
 	for i = len(b.Blocks) - 1; i >= 0; i-- {
 		if b.Blocks[i].Number < n {
@@ -67,14 +140,83 @@ func (b *BlockSlice) DeleteFromBlock(n uint64) {
 		}
 	}
 	b.Blocks = b.Blocks[:i+1]
-	b.DistanceFromHead -= b.End - n
+	b.DistanceFromHead += b.End - n
 	b.End = n
 }
 
 func (b *BlockSlice) Extend(n uint64) error {
+	defer b.debugCheck("Extend")
 	if n < b.End {
-		return fmt.Errorf("n=%d; want n >= %d", n, b.End)
+		return &RangeError{Op: "Extend", N: n, Min: b.End, Max: math.MaxUint64}
 	}
 	b.End = n
 	return nil
 }
+
+// check returns an error if the BlockSlice is inconsistent: Start after
+// End, blocks out of range or not strictly increasing, or events not in
+// their block or out of order.
+func (b *BlockSlice) check() error {
+	if b.Start > b.End {
+		return fmt.Errorf("got Start=%d, End=%d; want Start <= End", b.Start, b.End)
+	}
+	for i, blk := range b.Blocks {
+		if blk == nil {
+			return fmt.Errorf("block %d is nil", i)
+		}
+		if blk.Number < b.Start || blk.Number >= b.End {
+			return fmt.Errorf("got block %d; want block in %d:%d", blk.Number, b.Start, b.End)
+		}
+		if i > 0 && blk.Number <= b.Blocks[i-1].Number {
+			return fmt.Errorf("got block %d after %d; want increasing numbers", blk.Number, b.Blocks[i-1].Number)
+		}
+		for j, e := range blk.Events {
+			if e.BlockNumber != blk.Number {
+				return fmt.Errorf("got event %d/%d in block %d", e.BlockNumber, e.Index, blk.Number)
+			}
+			if j > 0 && e.Index < blk.Events[j-1].Index {
+				return fmt.Errorf("got event %d/%d after %d/%d; want increasing index", e.BlockNumber, e.Index, e.BlockNumber, blk.Events[j-1].Index)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAppend does the strict mode checks of Append.
+func (b *BlockSlice) checkAppend(blk *Block) error {
+	for _, e := range blk.Events {
+		if e.BlockNumber != blk.Number || e.BlockHash != blk.Hash {
+			return fmt.Errorf("got event of block %d (%s) in block %d (%s)", e.BlockNumber, e.BlockHash.Hex(), blk.Number, blk.Hash.Hex())
+		}
+	}
+	if len(b.Blocks) > 0 {
+		return checkParent(b.Blocks[len(b.Blocks)-1], blk)
+	}
+	return nil
+}
+
+// checkParent returns an error if blk directly follows prev, has a parent
+// hash, and its parent is not prev. Across a gap of blocks without events,
+// the parent is a block that is not stored, so nothing is checked; a reorg
+// there shows only in the block hashes the ChainStreamer compares.
+func checkParent(prev, blk *Block) error {
+	if blk.Number != prev.Number+1 || blk.ParentHash == (common.Hash{}) {
+		return nil
+	}
+	if blk.ParentHash != prev.Hash {
+		return fmt.Errorf("got block %d with parent %s; want parent %s", blk.Number, blk.ParentHash.Hex(), prev.Hash.Hex())
+	}
+	return nil
+}
+
+// VerifyParents checks, without any RPC calls, that the adjacent blocks
+// tracked with parent hashes form a single chain. Blocks after a gap are
+// not checked against the block before it; see checkParent.
+func (b *BlockSlice) VerifyParents() error {
+	for i := 1; i < len(b.Blocks); i++ {
+		if err := checkParent(b.Blocks[i-1], b.Blocks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const DefaultBlockTimeSample uint64 = 1000 // blocks
+
+// EstimateBlockTime returns the average interval between the last n blocks.
+func EstimateBlockTime(ctx context.Context, client Client, n uint64) (time.Duration, error) {
+	last, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	head := last.Number.Uint64()
+	if n > head {
+		n = head
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no blocks to estimate block time from")
+	}
+	first, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(head-n))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(last.Time-first.Time) * time.Second / time.Duration(n), nil
+}
+
+// overlapBlocks converts an overlap duration to a number of blocks, rounding
+// up, using the given block time.
+func overlapBlocks(d, blockTime time.Duration) uint64 {
+	if blockTime <= 0 {
+		return DefaultBatchOverlap
+	}
+	n := uint64((d + blockTime - 1) / blockTime)
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
@@ -0,0 +1,270 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultBudgetWindow is the period the limits of a Budget apply to.
+const DefaultBudgetWindow = time.Hour
+
+// DefaultComputeUnits estimates the provider compute units of the calls of
+// a Client, after the published pricing of common providers. Providers
+// differ, so set Budget.Units to match yours.
+var DefaultComputeUnits = map[string]uint64{
+	"BlockNumber":       10,
+	"FilterLogs":        75,
+	"HeaderByHash":      16,
+	"HeaderByNumber":    16,
+	"TransactionByHash": 17,
+	"TransactionSender": 17,
+	"CodeAt":            26,
+	"CallContract":      26,
+}
+
+// BudgetExceededError is returned by the Client of a Budget for a call that
+// would exceed a limit.
+type BudgetExceededError struct {
+	Call     string
+	Calls    uint64 // calls in the window, with this one
+	MaxCalls uint64
+	Units    uint64 // compute units in the window, with this call
+	MaxUnits uint64
+	Window   time.Duration
+	Retry    time.Time // when the window has room for the call again
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s: RPC budget exceeded: %d calls (max %d), %d compute units (max %d) per %v; room again at %s",
+		e.Call, e.Calls, e.MaxCalls, e.Units, e.MaxUnits, e.Window, e.Retry.Format(time.RFC3339))
+}
+
+// Budget limits the RPC calls and estimated compute units of one or more
+// Clients within a sliding window, to protect against surprise provider
+// bills. Set it as the Budget of a ChainStreamer, or wrap a Client with
+// Client. A call over a limit fails with a BudgetExceededError, or with
+// Wait, waits until the window has room for it.
+type Budget struct {
+	MaxCalls uint64            // per Window; 0 is unlimited
+	MaxUnits uint64            // compute units per Window; 0 is unlimited
+	Window   time.Duration     // DefaultBudgetWindow if zero
+	Units    map[string]uint64 // by Client method; DefaultComputeUnits if nil
+	Wait     bool
+
+	// OnExceeded, if set, is called when a call exceeds the budget, before
+	// it waits or fails, e.g. to alert an operator.
+	OnExceeded func(*BudgetExceededError)
+
+	mu    sync.Mutex
+	calls []budgetCall // in the window, oldest first
+}
+
+type budgetCall struct {
+	at    time.Time
+	units uint64
+}
+
+// Used returns the calls and compute units spent in the current window.
+func (b *Budget) Used() (calls, units uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prune(time.Now())
+	for _, c := range b.calls {
+		units += c.units
+	}
+	return uint64(len(b.calls)), units
+}
+
+func (b *Budget) window() time.Duration {
+	if b.Window > 0 {
+		return b.Window
+	}
+	return DefaultBudgetWindow
+}
+
+// prune drops the calls that left the window.
+func (b *Budget) prune(now time.Time) {
+	cut := 0
+	for cut < len(b.calls) && now.Sub(b.calls[cut].at) >= b.window() {
+		cut++
+	}
+	b.calls = b.calls[cut:]
+}
+
+// charge records a call, or returns the error of exceeding the budget.
+func (b *Budget) charge(call string) *BudgetExceededError {
+	units := DefaultComputeUnits[call]
+	if b.Units != nil {
+		units = b.Units[call]
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.prune(now)
+	total := units
+	for _, c := range b.calls {
+		total += c.units
+	}
+	n := uint64(len(b.calls)) + 1
+	if (b.MaxCalls == 0 || n <= b.MaxCalls) && (b.MaxUnits == 0 || total <= b.MaxUnits) {
+		b.calls = append(b.calls, budgetCall{at: now, units: units})
+		return nil
+	}
+	// The window has room once enough of the oldest calls left it.
+	retry := now
+	freed, i := uint64(0), 0
+	for ; i < len(b.calls) && (b.MaxCalls > 0 && n-uint64(i) > b.MaxCalls || b.MaxUnits > 0 && total-freed > b.MaxUnits); i++ {
+		freed += b.calls[i].units
+		retry = b.calls[i].at.Add(b.window())
+	}
+	if b.MaxUnits > 0 && units > b.MaxUnits {
+		retry = time.Time{} // never fits
+	}
+	return &BudgetExceededError{
+		Call:     call,
+		Calls:    n,
+		MaxCalls: b.MaxCalls,
+		Units:    total,
+		MaxUnits: b.MaxUnits,
+		Window:   b.window(),
+		Retry:    retry,
+	}
+}
+
+// spend charges a call to the budget, waiting for room if b.Wait is set.
+func (b *Budget) spend(ctx context.Context, call string) error {
+	for {
+		err := b.charge(call)
+		if err == nil {
+			return nil
+		}
+		if b.OnExceeded != nil {
+			b.OnExceeded(err)
+		}
+		if !b.Wait || err.Retry.IsZero() {
+			return err
+		}
+		logf(ctx, "%v; waiting\n", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(err.Retry)):
+		}
+	}
+}
+
+// Client returns a Client charging the calls of c to the budget. It
+// implements the optional LogTimestampClient, CallClient and
+// BatchCallClient, passing the calls on to c if it does.
+func (b *Budget) Client(c Client) Client {
+	return &budgetClient{c: c, b: b}
+}
+
+type budgetClient struct {
+	c Client
+	b *Budget
+}
+
+func (bc *budgetClient) BlockNumber(ctx context.Context) (uint64, error) {
+	if err := bc.b.spend(ctx, "BlockNumber"); err != nil {
+		return 0, err
+	}
+	return bc.c.BlockNumber(ctx)
+}
+
+func (bc *budgetClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if err := bc.b.spend(ctx, "FilterLogs"); err != nil {
+		return nil, err
+	}
+	return bc.c.FilterLogs(ctx, q)
+}
+
+func (bc *budgetClient) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if err := bc.b.spend(ctx, "HeaderByHash"); err != nil {
+		return nil, err
+	}
+	return bc.c.HeaderByHash(ctx, hash)
+}
+
+func (bc *budgetClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := bc.b.spend(ctx, "HeaderByNumber"); err != nil {
+		return nil, err
+	}
+	return bc.c.HeaderByNumber(ctx, number)
+}
+
+func (bc *budgetClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if err := bc.b.spend(ctx, "TransactionByHash"); err != nil {
+		return nil, false, err
+	}
+	return bc.c.TransactionByHash(ctx, hash)
+}
+
+func (bc *budgetClient) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	if err := bc.b.spend(ctx, "TransactionSender"); err != nil {
+		return common.Address{}, err
+	}
+	return bc.c.TransactionSender(ctx, tx, block, index)
+}
+
+func (bc *budgetClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := bc.b.spend(ctx, "CodeAt"); err != nil {
+		return nil, err
+	}
+	return bc.c.CodeAt(ctx, account, blockNumber)
+}
+
+func (bc *budgetClient) Close() {
+	bc.c.Close()
+}
+
+func (bc *budgetClient) FilterLogsTimestamps(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, map[common.Hash]uint64, error) {
+	if err := bc.b.spend(ctx, "FilterLogs"); err != nil {
+		return nil, nil, err
+	}
+	if tc, ok := bc.c.(LogTimestampClient); ok {
+		return tc.FilterLogsTimestamps(ctx, q)
+	}
+	logs, err := bc.c.FilterLogs(ctx, q)
+	return logs, nil, err
+}
+
+func (bc *budgetClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	cc, ok := bc.c.(CallClient)
+	if !ok {
+		return nil, fmt.Errorf("client cannot call contracts")
+	}
+	if err := bc.b.spend(ctx, "CallContract"); err != nil {
+		return nil, err
+	}
+	return cc.CallContract(ctx, msg, blockNumber)
+}
+
+// BatchCallContract charges every call of the batch.
+func (bc *budgetClient) BatchCallContract(ctx context.Context, msgs []ethereum.CallMsg, blockNumber *big.Int) ([][]byte, []error, error) {
+	for range msgs {
+		if err := bc.b.spend(ctx, "CallContract"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if bcc, ok := bc.c.(BatchCallClient); ok {
+		return bcc.BatchCallContract(ctx, msgs, blockNumber)
+	}
+	cc, ok := bc.c.(CallClient)
+	if !ok {
+		return nil, nil, fmt.Errorf("client cannot call contracts")
+	}
+	vs := make([][]byte, len(msgs))
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		vs[i], errs[i] = cc.CallContract(ctx, msg, blockNumber)
+	}
+	return vs, errs, nil
+}
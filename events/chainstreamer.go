@@ -2,12 +2,13 @@ package events
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 const DefaultBatchOverlap uint64 = 10     // overlap between polls
@@ -15,26 +16,154 @@ const DefaultFetchBatchSize uint64 = 2000 // size of call to getLogs
 const MaxEventlogSize uint64 = 1024       // blocks
 const DefaultPollInterval int = 15        // seconds
 
-// ChainStreamer implements a Streamer for the Ethereum blockchain.
+const backlogPollInterval = 100 * time.Millisecond
+
+// ChainStreamer implements a Streamer for the Ethereum blockchain. It
+// connects to Url with the registered Dialer, unless Client is set.
 type ChainStreamer struct {
 	Ctx            context.Context
 	Url            string
+	Client         Client
 	Filter         ethereum.FilterQuery
 	FetchBatchSize uint64
 	BatchOverlap   uint64
 	FetchTxDetails bool
+
+	// TrackHeaders fetches the header of every emitted block, to set its
+	// ParentHash.
+	TrackHeaders bool
+
+	// EventsRoots sets the EventsRoot of every emitted block.
+	EventsRoots bool
+
+	// BlockTimestamps sets the Time of every emitted block, from the logs
+	// if the provider includes blockTimestamp in them, else from a header
+	// fetch.
+	BlockTimestamps bool
+
+	// BatchOverlapDuration, if set, overrides BatchOverlap with the number
+	// of blocks produced in that time, based on the average block time
+	// observed when the stream starts.
+	BatchOverlapDuration time.Duration
+
+	// ClampToEarliest starts the stream at the earliest block the node
+	// serves, with a warning, if from is older. Otherwise Stream returns
+	// an EarliestBlockError.
+	ClampToEarliest bool
+
+	// WaitForFrom lets the stream start more than one block beyond the
+	// chain head, waiting for the chain to reach it. Otherwise Stream
+	// fails, as the node is likely behind or on another chain.
+	WaitForFrom bool
+
+	// AutoTuneOverlap raises the batch overlap to SuggestOverlap when a
+	// reorg reaches deeper than half of it, up to MaxBatchOverlap if set
+	// and never to FetchBatchSize or beyond.
+	AutoTuneOverlap bool
+	MaxBatchOverlap uint64
+
+	// Buffer is the number of messages the subscription channel holds, so
+	// fetching can run ahead of a slow consumer. With MaxBacklog set,
+	// fetching pauses while the buffered messages span more than that many
+	// blocks.
+	Buffer     int
+	MaxBacklog uint64
+
+	// Stats, if set, is updated as the stream progresses.
+	Stats *StreamStats
+
+	// BlockHook, if set, is called for every block before it is emitted,
+	// e.g. to add annotations to its Meta. An error ends the stream.
+	BlockHook func(ctx context.Context, client Client, b *Block) error
+
+	// OnHead, if set, is called with the chain head whenever the streamer
+	// sees it change while polling.
+	OnHead func(Head)
+
+	// OnRollback, if set, is called on a reorg before the Rollback message
+	// is sent, with the blocks from..to-1 it invalidates and those of them
+	// that were emitted. Emitted blocks older than MaxEventlogSize blocks
+	// are not kept, and not reported.
+	OnRollback func(from, to uint64, droppedBlocks []*Block)
+
+	// RollbackBlocks includes the emitted blocks a Rollback drops in its
+	// message, as reported to OnRollback. They can be many, so this is
+	// off by default.
+	RollbackBlocks bool
+
+	// OnLogAnomaly, if set, is called instead of logging a warning when
+	// the node returns logs that had to be repaired; see GetLogs.
+	OnLogAnomaly func(LogAnomaly)
+
+	// Interner, if set, deduplicates the TxData and Topics of the emitted
+	// events.
+	Interner *Interner
+
+	// Budget, if set, limits the RPC calls of the stream; see Budget.
+	Budget *Budget
+}
+
+// Validate checks the configuration for mistakes that would otherwise only
+// show once streaming, or never. Stream calls it.
+func (cr *ChainStreamer) Validate() error {
+	if cr.Ctx == nil {
+		return fmt.Errorf("ChainStreamer has no Ctx; set it to context.Background() if in doubt")
+	}
+	if cr.Url == "" && cr.Client == nil {
+		return fmt.Errorf("ChainStreamer has neither Url nor Client; set one to reach a node")
+	}
+	if cr.Client == nil && registeredDialer() == nil {
+		return fmt.Errorf("ChainStreamer has a Url: %w", ErrNoDialer)
+	}
+	if cr.Filter.BlockHash != nil {
+		return fmt.Errorf("ChainStreamer Filter has a BlockHash; the blocks are set by Stream, so leave it nil")
+	}
+	bs, bo := cr.FetchBatchSize, cr.BatchOverlap
+	if bs == 0 {
+		bs = DefaultFetchBatchSize
+	}
+	if bo == 0 {
+		bo = DefaultBatchOverlap
+	}
+	if bo >= bs {
+		return fmt.Errorf("got BatchOverlap=%d, FetchBatchSize=%d; want BatchOverlap < FetchBatchSize, or the stream never advances", bo, bs)
+	}
+	if cr.MaxBatchOverlap > 0 && cr.MaxBatchOverlap < bo {
+		return fmt.Errorf("got MaxBatchOverlap=%d, BatchOverlap=%d; want MaxBatchOverlap >= BatchOverlap", cr.MaxBatchOverlap, bo)
+	}
+	if cr.BatchOverlapDuration < 0 {
+		return fmt.Errorf("got BatchOverlapDuration=%v; want >= 0", cr.BatchOverlapDuration)
+	}
+	if cr.Buffer < 0 {
+		return fmt.Errorf("got Buffer=%d; want >= 0", cr.Buffer)
+	}
+	return nil
+}
+
+// Head is the chain head as seen by a ChainStreamer.
+type Head struct {
+	Number uint64
+	Hash   common.Hash
 }
 
 func (cr *ChainStreamer) Stream(done chan struct{}, from uint64) (*Subscription, error) {
-	cs, err := cr.makeChainStreamer(done, from)
+	return cr.stream(done, EmptyBlockSlice(from))
+}
+
+// stream streams from the end of history, the blocks the subscriber
+// already has. The first batches overlap them, so that a reorg of them is
+// a Rollback, as if they had been streamed.
+func (cr *ChainStreamer) stream(done chan struct{}, history *BlockSlice) (*Subscription, error) {
+	cs, err := cr.makeChainStreamer(done, history)
 	if err != nil {
 		return nil, err
 	}
 
+	cs.stats.start(cs.config)
 	go func() {
 		err := cs.run()
 		close(cs.c)
-		cs.err <- err
+		cs.err <- redactError(correlate(cs.ctx, err))
 	}()
 
 	return &Subscription{C: cs.c, Err: cs.err, Done: done}, nil
@@ -48,7 +177,7 @@ type chainStreamer struct {
 	err  chan error
 
 	ctx     context.Context
-	client  *ethclient.Client
+	client  Client
 	history *BlockSlice
 	next    uint64
 
@@ -56,9 +185,32 @@ type chainStreamer struct {
 	fetchBatchSize uint64
 	batchOverlap   uint64
 	fetchTxDetails bool
+	trackHeaders   bool
+	eventsRoots    bool
+	timestamps     bool
+	rollbackBlocks bool
+	autoTune       bool
+	maxOverlap     uint64 // bound of auto-tuning
+	stats          *StreamStats
+	config         EffectiveConfig
+
+	maxBacklog uint64
+	buffered   []uint64 // stream position after each buffered message
+	consumed   uint64   // stream position after the last consumed message
+
+	blockHook  func(context.Context, Client, *Block) error
+	onHead     func(Head)
+	onRollback func(from, to uint64, droppedBlocks []*Block)
+	onAnomaly  func(LogAnomaly)
+	head       Head
+	interner   *Interner
 }
 
-func (cr *ChainStreamer) makeChainStreamer(done chan struct{}, from uint64) (*chainStreamer, error) {
+func (cr *ChainStreamer) makeChainStreamer(done chan struct{}, history *BlockSlice) (*chainStreamer, error) {
+	if err := cr.Validate(); err != nil {
+		return nil, err
+	}
+	from := history.End
 
 	bo := cr.BatchOverlap
 	if bo == 0 {
@@ -69,44 +221,123 @@ func (cr *ChainStreamer) makeChainStreamer(done chan struct{}, from uint64) (*ch
 		fbs = DefaultFetchBatchSize
 	}
 
-	client, err := ethclient.DialContext(cr.Ctx, cr.Url)
-	if err != nil {
-		return nil, err
+	client := cr.Client
+	if client == nil {
+		var err error
+		if client, err = Dial(cr.Ctx, cr.Url); err != nil {
+			return nil, err
+		}
+	}
+	if cr.Budget != nil {
+		client = cr.Budget.Client(client)
+	}
+
+	if err := checkAvailable(cr.Ctx, client, from); err != nil {
+		var ee *EarliestBlockError
+		if !cr.ClampToEarliest || !errors.As(err, &ee) {
+			if cr.Client == nil {
+				client.Close()
+			}
+			return nil, err
+		}
+		logf(cr.Ctx, "warning: %v; starting at %d\n", err, ee.Earliest)
+		from = ee.Earliest
+		history = EmptyBlockSlice(from)
+	}
+
+	if !cr.WaitForFrom {
+		head, err := client.BlockNumber(cr.Ctx)
+		if err == nil && from > head+1 {
+			err = &AheadOfChainError{From: from, Head: head}
+		}
+		if err != nil {
+			if cr.Client == nil {
+				client.Close()
+			}
+			return nil, err
+		}
+	}
+
+	if cr.BatchOverlapDuration > 0 {
+		bt, err := EstimateBlockTime(cr.Ctx, client, DefaultBlockTimeSample)
+		if err != nil {
+			if cr.Client == nil {
+				client.Close()
+			}
+			return nil, err
+		}
+		bo = overlapBlocks(cr.BatchOverlapDuration, bt)
+		logf(cr.Ctx, "block time %v, batch overlap %d blocks\n", bt, bo)
+		if bo >= fbs {
+			if cr.Client == nil {
+				client.Close()
+			}
+			return nil, fmt.Errorf("BatchOverlapDuration=%v is %d blocks; want fewer than FetchBatchSize=%d", cr.BatchOverlapDuration, bo, fbs)
+		}
+	}
+
+	maxOverlap := fbs - 1
+	if cr.MaxBatchOverlap > 0 && cr.MaxBatchOverlap < maxOverlap {
+		maxOverlap = cr.MaxBatchOverlap
 	}
 
+	config := cr.EffectiveConfig()
+	config.BatchOverlap = bo
+	logf(cr.Ctx, "effective config: %v\n", config)
+
 	return &chainStreamer{
 		filter: cr.Filter,
 
-		c:    make(chan *Message),
+		c:    make(chan *Message, cr.Buffer),
 		done: done,
 		err:  make(chan error, 1),
 
 		ctx:     cr.Ctx,
 		client:  client,
-		history: EmptyBlockSlice(from),
+		history: history,
 
-		from:           from,
+		from:           history.Start,
 		next:           from,
 		fetchBatchSize: fbs,
 		batchOverlap:   bo,
 		fetchTxDetails: cr.FetchTxDetails,
+		trackHeaders:   cr.TrackHeaders,
+		eventsRoots:    cr.EventsRoots,
+		timestamps:     cr.BlockTimestamps,
+		rollbackBlocks: cr.RollbackBlocks,
+		autoTune:       cr.AutoTuneOverlap,
+		maxOverlap:     maxOverlap,
+		stats:          cr.Stats,
+		config:         config,
+		maxBacklog:     cr.MaxBacklog,
+		consumed:       from,
+		blockHook:      cr.BlockHook,
+		onHead:         cr.OnHead,
+		onRollback:     cr.OnRollback,
+		onAnomaly:      cr.OnLogAnomaly,
+		interner:       cr.Interner,
 	}, nil
 }
 
 func (cs *chainStreamer) run() error {
 	for {
 
-		// 1. Get a BlockSlice from chain.
+		// 1. Get a BlockSlice from chain, once the consumer has caught up.
+
+		if err := cs.waitForConsumer(); err != nil {
+			return err
+		}
 
 		from := cs.next - cs.batchOverlap
 		if cs.next < cs.from+cs.batchOverlap {
 			from = cs.from
 		}
 
-		b, err := cs.fetch(from)
+		b, head, err := cs.fetch(from)
 		if err != nil {
 			return err
 		}
+		cs.observeHead(head)
 
 		// 2. Process the blocks.
 
@@ -129,29 +360,40 @@ func (cs *chainStreamer) process(b *BlockSlice) error {
 	// overlap. If they don't, there has been a chain reorganization and we
 	// must roll back to the last agreed upon block.
 
-	log.Printf("processing batch %d:%d (%d non-empty blocks)\n", b.Start, b.End, len(b.Blocks))
+	logf(cs.ctx, "processing batch %d:%d (%d non-empty blocks)\n", b.Start, b.End, len(b.Blocks))
 
 	ok, lastGoodBlock, err := MatchBlocks(b, cs.history)
 	if err != nil {
 		return err
 	}
 	if !ok {
-		log.Printf("MatchHistory returned false, %d\n", lastGoodBlock)
+		logf(cs.ctx, "MatchHistory returned false, %d\n", lastGoodBlock)
 		if lastGoodBlock+1 < cs.from {
 			lastGoodBlock = cs.from - 1
 		}
+		depth := cs.next - (lastGoodBlock + 1)
+		prev := cs.next
 		cs.next = lastGoodBlock + 1
+		dropped := cs.history.blocksFrom(cs.next)
 		if err := cs.history.Rollback(cs.next); err != nil {
 			return err
 		}
+		if cs.onRollback != nil {
+			cs.onRollback(cs.next, prev, dropped)
+		}
+		cs.stats.rollback(depth)
+		cs.tuneOverlap(depth)
 		m := &Message{
 			Action: Rollback,
 			Number: cs.next,
 		}
-		if err := sendOrDone(cs.c, cs.done, m); err != nil {
+		if cs.rollbackBlocks {
+			m.Dropped = dropped
+		}
+		if err := cs.send(m); err != nil {
 			return err
 		}
-		log.Printf("  ..new cs.next=%d\n", cs.next)
+		logf(cs.ctx, "  ..new cs.next=%d\n", cs.next)
 
 		// We can't recover from no matching events, so emit nothing.
 		if cs.next < b.Start {
@@ -166,12 +408,37 @@ func (cs *chainStreamer) process(b *BlockSlice) error {
 	// 3. (Optionally) Fetch transaction data.
 
 	if cs.fetchTxDetails {
-		AddTransactionData(cs.ctx, cs.client, b)
+		if err := AddTransactionData(cs.ctx, cs.client, b); err != nil {
+			return err
+		}
+		if n := countTxMissing(b); n > 0 {
+			logf(cs.ctx, "warning: no transaction details for %d events in %d:%d; is the node pruned?\n", n, b.Start, b.End)
+			cs.stats.addTxMissing(uint64(n))
+		}
+	}
+	if cs.trackHeaders {
+		if err := AddHeaderData(cs.ctx, cs.client, b); err != nil {
+			return err
+		}
+	}
+	if cs.timestamps {
+		if err := AddBlockTimestamps(cs.ctx, cs.client, b); err != nil {
+			return err
+		}
+	}
+
+	if cs.eventsRoots {
+		AddEventsRoots(b)
+	}
+	if cs.interner != nil {
+		for _, blk := range b.Blocks {
+			cs.interner.Block(blk)
+		}
 	}
 
 	// 3. Emit events to internal eventlog and output channel.
 
-	log.Printf("emitting %d blocks from BlockSlice %d:%d\n", len(b.Blocks), b.Start, b.End)
+	logf(cs.ctx, "emitting %d blocks from BlockSlice %d:%d\n", len(b.Blocks), b.Start, b.End)
 	if err := cs.history.Concat(b); err != nil {
 		return err
 	}
@@ -179,11 +446,16 @@ func (cs *chainStreamer) process(b *BlockSlice) error {
 		cs.history.DeleteBeforeBlock(cs.history.End - MaxEventlogSize)
 	}
 	for _, blk := range b.Blocks {
+		if cs.blockHook != nil {
+			if err := cs.blockHook(cs.ctx, cs.client, blk); err != nil {
+				return err
+			}
+		}
 		m := &Message{
 			Action: Append,
 			Block:  blk,
 		}
-		if err := sendOrDone(cs.c, cs.done, m); err != nil {
+		if err := cs.send(m); err != nil {
 			return err
 		}
 	}
@@ -191,18 +463,19 @@ func (cs *chainStreamer) process(b *BlockSlice) error {
 	// 4. Update cs.next to end of this batch.
 
 	cs.next = b.End
-	if err := sendOrDone(cs.c, cs.done, &Message{
+	if err := cs.send(&Message{
 		Action: SetNext,
 		Number: cs.next,
 	}); err != nil {
 		return err
 	}
+	cs.stats.progress(cs.next, b.End-1+b.DistanceFromHead)
 	return nil
 }
 
 // fetch returns a batch of logs from a given block number. The events in the
 // block are guaranteed to be sorted by increasing (BlockNumber, Index).
-func (cs *chainStreamer) fetch(from uint64) (*BlockSlice, error) {
+func (cs *chainStreamer) fetch(from uint64) (*BlockSlice, uint64, error) {
 	batchSize := cs.fetchBatchSize
 	if batchSize == 0 {
 		batchSize = 2000
@@ -210,14 +483,85 @@ func (cs *chainStreamer) fetch(from uint64) (*BlockSlice, error) {
 
 	to := from + batchSize - 1
 
-	batch, err := GetLogs(cs.ctx, cs.client, &ethereum.FilterQuery{
+	return getLogs(cs.ctx, cs.client, ethereum.FilterQuery{
 		FromBlock: new(big.Int).SetUint64(from),
 		ToBlock:   new(big.Int).SetUint64(to),
 		Addresses: cs.filter.Addresses,
 		Topics:    cs.filter.Topics,
-	})
+	}, cs.onAnomaly)
+}
+
+// observeHead reports a new head to the OnHead callback. The hash costs an
+// extra call, so it is only looked up if there is a callback.
+func (cs *chainStreamer) observeHead(n uint64) {
+	if cs.onHead == nil || n == cs.head.Number {
+		return
+	}
+	h, err := cs.client.HeaderByNumber(cs.ctx, new(big.Int).SetUint64(n))
 	if err != nil {
-		return nil, err
+		logf(cs.ctx, "getting head %d: %v\n", n, err)
+		return
 	}
-	return batch, nil
+	cs.head = Head{Number: n, Hash: h.Hash()}
+	cs.onHead(cs.head)
+}
+
+// tuneOverlap raises the batch overlap if auto-tuning is on and a reorg of
+// the given depth came close to it.
+func (cs *chainStreamer) tuneOverlap(depth uint64) {
+	if !cs.autoTune || 2*depth <= cs.batchOverlap {
+		return
+	}
+	bo := SuggestOverlap(cs.batchOverlap, depth)
+	if bo > cs.maxOverlap {
+		bo = cs.maxOverlap
+	}
+	if bo <= cs.batchOverlap {
+		return
+	}
+	cs.batchOverlap = bo
+	cs.stats.setOverlap(cs.batchOverlap)
+	logf(cs.ctx, "reorg of depth %d, raising batch overlap to %d\n", depth, cs.batchOverlap)
+}
+
+// send sends a message to the subscriber, keeping track of the stream
+// position after it while it is buffered, if the backlog is limited.
+func (cs *chainStreamer) send(m *Message) error {
+	if err := sendOrDone(cs.c, cs.done, m); err != nil {
+		return err
+	}
+	if cs.maxBacklog == 0 || cap(cs.c) == 0 {
+		return nil
+	}
+	pos := m.Number
+	if m.Action == Append {
+		pos = m.Block.Number + 1
+	}
+	cs.buffered = append(cs.buffered, pos)
+	return nil
+}
+
+// backlog returns the number of blocks sent but not yet consumed.
+func (cs *chainStreamer) backlog() uint64 {
+	if n := len(cs.buffered) - len(cs.c); n > 0 {
+		cs.consumed = cs.buffered[n-1]
+		cs.buffered = cs.buffered[n:]
+	}
+	if cs.next <= cs.consumed {
+		return 0
+	}
+	return cs.next - cs.consumed
+}
+
+// waitForConsumer waits while the backlog exceeds maxBacklog.
+func (cs *chainStreamer) waitForConsumer() error {
+	if cs.maxBacklog == 0 || cap(cs.c) == 0 {
+		return nil
+	}
+	for cs.backlog() > cs.maxBacklog {
+		if err := waitOrDone(cs.done, backlogPollInterval); err != nil {
+			return err
+		}
+	}
+	return nil
 }
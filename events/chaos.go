@@ -0,0 +1,96 @@
+package events
+
+import (
+	"math/rand"
+	"time"
+)
+
+const DefaultChaosMaxDepth = 5 // blocks
+
+// ChaosStreamer wraps a Streamer to inject artificial reorganizations, to
+// check that consumers really handle them. After each Append, with
+// probability RollbackRate, it sends a Rollback of up to MaxDepth of the
+// recently appended blocks and then appends them again. With probability
+// DuplicateRate it sends an Append twice, as may happen with at-least-once
+// delivery; an InMemoryEventLog rejects such duplicates, so only set it for
+// consumers that are meant to be idempotent.
+type ChaosStreamer struct {
+	Streamer      Streamer
+	RollbackRate  float64
+	DuplicateRate float64
+	MaxDepth      int        // DefaultChaosMaxDepth if zero
+	Rand          *rand.Rand // seeded from the clock if nil
+}
+
+func (cs *ChaosStreamer) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	sub, err := cs.Streamer.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := cs.forward(sub, c, done)
+		close(c)
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- <-sub.Err
+	}()
+
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+func (cs *ChaosStreamer) forward(sub *Subscription, c chan *Message, done chan struct{}) error {
+	rnd := cs.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	maxDepth := cs.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultChaosMaxDepth
+	}
+
+	var recent []*Message
+	for m := range sub.C {
+		if err := sendOrDone(c, done, m); err != nil {
+			return err
+		}
+		switch m.Action {
+		case Rollback:
+			i := len(recent)
+			for i > 0 && recent[i-1].Block.Number >= m.Number {
+				i--
+			}
+			recent = recent[:i]
+			continue
+		case SetNext:
+			continue
+		}
+
+		recent = append(recent, m)
+		if len(recent) > maxDepth {
+			recent = recent[1:]
+		}
+		if rnd.Float64() < cs.DuplicateRate {
+			if err := sendOrDone(c, done, m); err != nil {
+				return err
+			}
+		}
+		if rnd.Float64() < cs.RollbackRate {
+			replay := recent[len(recent)-1-rnd.Intn(len(recent)):]
+			if err := sendOrDone(c, done, &Message{Action: Rollback, Number: replay[0].Block.Number}); err != nil {
+				return err
+			}
+			for _, r := range replay {
+				if err := sendOrDone(c, done, r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
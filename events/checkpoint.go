@@ -0,0 +1,154 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// SaveCheckpoint writes an eventlog to a proto file, compressed with gzip if
+// the path ends in ".gz". Paths ending in ".json" (before any ".gz") get
+// the encoding of MarshalJSON instead; for gob, see package gobcodec. An
+// existing file is replaced atomically, so a crash never leaves a partial
+// checkpoint.
+func SaveCheckpoint(l *InMemoryEventLog, path string) error {
+	return SaveCheckpointWith(l, path, CheckpointOptions{})
+}
+
+// CheckpointOptions select optional features of SaveCheckpointWith.
+type CheckpointOptions struct {
+	// DedupData stores repeated event data once in proto files; see
+	// DedupData. Versions without dictionary support read such files with
+	// the data missing, so set it only when every reader supports it.
+	DedupData bool
+
+	// SigningKey, if set, signs the file; see SaveSignedCheckpoint.
+	SigningKey ed25519.PrivateKey
+}
+
+// SaveCheckpointWith is like SaveCheckpoint, with options.
+func SaveCheckpointWith(l *InMemoryEventLog, path string, opts CheckpointOptions) error {
+	bs, err := encodeCheckpoint(l, path, opts.DedupData)
+	if err != nil {
+		return err
+	}
+	if opts.SigningKey != nil {
+		return writeSignedFile(path, bs, opts.SigningKey)
+	}
+	return WriteFileAtomic(path, bs)
+}
+
+// encodeCheckpoint returns the contents of the checkpoint file of l at
+// path.
+func encodeCheckpoint(l *InMemoryEventLog, path string, dedup bool) ([]byte, error) {
+	var bs []byte
+	var err error
+	switch filepath.Ext(strings.TrimSuffix(path, ".gz")) {
+	case ".gob":
+		return nil, fmt.Errorf("%s: gob checkpoints are written by package events/gobcodec", path)
+	case ".json":
+		bs, err = l.MarshalJSON()
+	default:
+		pb := l.ToProto()
+		if dedup {
+			DedupData(pb.BlockSlice)
+		}
+		bs, err = proto.Marshal(pb)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(bs); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		bs = buf.Bytes()
+	}
+	return bs, nil
+}
+
+// LoadCheckpoint reads an eventlog written by SaveCheckpoint. Compressed
+// and JSON files are recognized by their content.
+func LoadCheckpoint(path string) (*InMemoryEventLog, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCheckpoint(bs)
+}
+
+// decodeCheckpoint decodes the contents of a checkpoint file.
+func decodeCheckpoint(bs []byte) (*InMemoryEventLog, error) {
+	if len(bs) >= 2 && bs[0] == 0x1f && bs[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(bs))
+		if err != nil {
+			return nil, err
+		}
+		if bs, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasPrefix(bs, []byte("eventlog/gob/")) {
+		return nil, fmt.Errorf("gob encoded eventlog; load it with package events/gobcodec")
+	}
+	if isJSON(bs) {
+		l := &InMemoryEventLog{}
+		if err := l.UnmarshalJSON(bs); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	pb := &epb.EventLogFile{}
+	if err := proto.Unmarshal(bs, pb); err != nil {
+		return nil, err
+	}
+	return InMemoryEventLogFromProto(pb)
+}
+
+// WriteFileAtomic writes a file by renaming a synced temporary file in the
+// same directory over it, and syncs the directory, so that after a crash it
+// has either the old or the new data.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client is the part of the go-ethereum ethclient.Client API used to fetch
+// events. Keeping the RPC implementation out of this package means programs
+// that only read stored eventlogs do not link it.
+type Client interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	Close()
+}
+
+// Dialer connects to a node by URL.
+type Dialer func(ctx context.Context, url string) (Client, error)
+
+var (
+	dialerMu sync.Mutex
+	dialer   Dialer
+)
+
+// ErrNoDialer is returned when connecting to a URL without a registered
+// Dialer.
+var ErrNoDialer = errors.New("no dialer registered; import github.com/jcjlcodes/eth-eventlog/events/ethrpc")
+
+// RegisterDialer sets the Dialer used to connect to ChainStreamer.Url. The
+// ethrpc package registers one when imported:
+//
+//	import _ "github.com/jcjlcodes/eth-eventlog/events/ethrpc"
+func RegisterDialer(d Dialer) {
+	dialerMu.Lock()
+	defer dialerMu.Unlock()
+	dialer = d
+}
+
+func registeredDialer() Dialer {
+	dialerMu.Lock()
+	defer dialerMu.Unlock()
+	return dialer
+}
+
+// Dial connects to a node with the registered Dialer, after expanding the
+// secrets referenced by the URL; see ExpandURL.
+func Dial(ctx context.Context, url string) (Client, error) {
+	d := registeredDialer()
+	if d == nil {
+		return nil, ErrNoDialer
+	}
+	url, err := ExpandURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	c, err := d(ctx, url)
+	if err != nil {
+		return nil, redactError(err)
+	}
+	return c, nil
+}
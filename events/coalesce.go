@@ -0,0 +1,76 @@
+package events
+
+// CoalescingStreamer wraps a Streamer to thin out the SetNext messages sent
+// for long ranges without events. A SetNext is held back until the next
+// block has advanced by at least Every blocks since the last one sent, or
+// until another message follows. With Quiet set, SetNext messages are not
+// sent at all, for consumers that only care about events.
+type CoalescingStreamer struct {
+	Streamer Streamer
+	Every    uint64
+	Quiet    bool
+}
+
+func (cs *CoalescingStreamer) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	sub, err := cs.Streamer.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := cs.forward(sub, c, done, from)
+		close(c)
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- <-sub.Err
+	}()
+
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+func (cs *CoalescingStreamer) forward(sub *Subscription, c chan *Message, done chan struct{}, from uint64) error {
+	sent := from
+	var pending *Message
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		m := pending
+		pending = nil
+		sent = m.Number
+		return sendOrDone(c, done, m)
+	}
+
+	for m := range sub.C {
+		if m.Action == SetNext {
+			if cs.Quiet {
+				continue
+			}
+			pending = m
+			if m.Number >= sent+cs.Every {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := sendOrDone(c, done, m); err != nil {
+			return err
+		}
+		switch m.Action {
+		case Append:
+			sent = m.Block.Number + 1
+		case Rollback:
+			sent = m.Number
+		}
+	}
+	return flush()
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+)
+
+// ContextStreamer is a streamer stopped by a context instead of a done
+// channel, which calls a function with typed messages instead of sending
+// them on a channel. The function runs in the caller's goroutine, and its
+// error ends the stream. This is the streaming API new code should
+// implement; package compat adapts it to Streamer and back.
+type ContextStreamer interface {
+	StreamContext(ctx context.Context, from uint64, fn func(TypedMessage) error) error
+}
+
+// StreamContext streams the messages of s from block from on to fn until ctx
+// is canceled, returning ctx.Err() then. Streamers without a StreamContext
+// method are run with a done channel closed on cancellation.
+func StreamContext(ctx context.Context, s Streamer, from uint64, fn func(TypedMessage) error) error {
+	if cs, ok := s.(ContextStreamer); ok {
+		return cs.StreamContext(ctx, from, fn)
+	}
+	done := make(chan struct{})
+	sub, err := s.Stream(done, from)
+	if err != nil {
+		return err
+	}
+	stop := func() {
+		close(done)
+		for range sub.C {
+		}
+		<-sub.Err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			return ctx.Err()
+		case m, ok := <-sub.C:
+			if !ok {
+				err := <-sub.Err
+				if err == Canceled && ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				return err
+			}
+			t, err := m.Typed()
+			if err == nil {
+				err = fn(t)
+			}
+			if err != nil {
+				stop()
+				return err
+			}
+		}
+	}
+}
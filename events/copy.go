@@ -0,0 +1,114 @@
+package events
+
+import (
+	"fmt"
+)
+
+// DefaultCopyProgressEvery is the default number of blocks between progress
+// reports of a Copier.
+const DefaultCopyProgressEvery = 10000
+
+// CopyProgress reports how far a copy has come.
+type CopyProgress struct {
+	From   uint64 // first block of the range
+	To     uint64 // end of the range (exclusive)
+	Next   uint64 // next block to copy
+	Blocks int    // blocks with events copied so far
+}
+
+// Copier copies the blocks of an eventlog to another one, e.g. to migrate
+// a checkpoint to a persistent backend.
+type Copier struct {
+	// Progress, if set, is called every ProgressEvery blocks and when the
+	// copy is complete.
+	Progress      func(CopyProgress)
+	ProgressEvery uint64
+}
+
+// Copy copies the blocks [from, to) of src to dst, which must continue at
+// from.
+func Copy(dst, src EventLog, from, to uint64) error {
+	return (&Copier{}).Copy(dst, src, from, to)
+}
+
+// Copy copies the blocks [from, to) of src to dst, which must continue at
+// from.
+func (cp *Copier) Copy(dst, src EventLog, from, to uint64) error {
+	if to < from {
+		return fmt.Errorf("got to=%d; want to >= %d", to, from)
+	}
+	if to > src.NextBlock() {
+		return fmt.Errorf("got to=%d; want to <= %d", to, src.NextBlock())
+	}
+	if dst.NextBlock() != from {
+		return fmt.Errorf("got from=%d; want from = %d (next block of dst)", from, dst.NextBlock())
+	}
+	every := cp.ProgressEvery
+	if every == 0 {
+		every = DefaultCopyProgressEvery
+	}
+
+	done := make(chan struct{})
+	sub, err := src.Stream(done, from)
+	if err != nil {
+		close(done)
+		return err
+	}
+	// Stop the stream and drain it on every return, so that its producer
+	// does not block sending messages after to.
+	stopped := false
+	stop := func() {
+		if !stopped {
+			stopped = true
+			close(done)
+			for range sub.C {
+			}
+		}
+	}
+	defer stop()
+	p := CopyProgress{From: from, To: to, Next: from}
+	report := from + every
+loop:
+	for m := range sub.C {
+		switch m.Action {
+		case Append:
+			if m.Block.Number >= to {
+				break loop
+			}
+			if err := dst.Append(m.Block); err != nil {
+				return err
+			}
+			p.Next = m.Block.Number + 1
+			p.Blocks++
+		case SetNext:
+			if m.Number > to {
+				break loop
+			}
+			if err := dst.SetNext(m.Number); err != nil {
+				return err
+			}
+			p.Next = m.Number
+		default:
+			return fmt.Errorf("got unexpected %v from eventlog", m)
+		}
+		if p.Next >= to {
+			break loop
+		}
+		if cp.Progress != nil && p.Next >= report {
+			cp.Progress(p)
+			report = p.Next + every
+		}
+	}
+	stop()
+	if err := <-sub.Err; err != nil && err != Canceled {
+		return err
+	}
+	if err := dst.SetNext(to); err != nil {
+		return err
+	}
+	p.Next = to
+	if cp.Progress != nil {
+		cp.Progress(p)
+	}
+	return nil
+}
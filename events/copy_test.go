@@ -0,0 +1,40 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCopySubRange(t *testing.T) {
+	src := NewInMemoryEventLog(0, ethereum.FilterQuery{})
+	for _, n := range []uint64{2, 5, 9, 14} {
+		if err := src.Append(&Block{Number: n, Hash: common.BigToHash(common.Big1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := NewInMemoryEventLog(0, ethereum.FilterQuery{})
+
+	errc := make(chan error, 1)
+	go func() { errc <- Copy(dst, src, 0, 7) }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Copy of a sub-range did not return")
+	}
+	if got := dst.NextBlock(); got != 7 {
+		t.Errorf("got NextBlock()=%d; want 7", got)
+	}
+	var got []uint64
+	for _, b := range dst.BlockSlice().Blocks {
+		got = append(got, b.Number)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 5 {
+		t.Errorf("got blocks %v; want [2 5]", got)
+	}
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// CorrelationHeader is the HTTP header carrying the correlation ID of RPC
+// calls made by clients from ethrpc.Dial.
+const CorrelationHeader = "X-Correlation-Id"
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying a correlation ID. Streams
+// using the context tag their RPC calls, errors and log messages with it, so
+// that calls seen by the node provider can be attributed to a stream.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of a context, or "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// logf logs a message, prefixed with the correlation ID of ctx if any, with
+// secrets redacted.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if id := CorrelationID(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	log.Print(Redact(fmt.Sprintf(format, args...)))
+}
+
+// correlate adds the correlation ID of ctx to an error.
+func correlate(ctx context.Context, err error) error {
+	id := CorrelationID(ctx)
+	if err == nil || id == "" || err == Canceled {
+		return err
+	}
+	return fmt.Errorf("[%s] %w", id, err)
+}
@@ -0,0 +1,17 @@
+//go:build eventsdebug
+// +build eventsdebug
+
+package events
+
+import "fmt"
+
+// debugCheck panics if a BlockSlice is inconsistent after op. The checks are
+// only compiled in with the eventsdebug build tag, to catch corruption where
+// it happens:
+//
+//	go test -tags eventsdebug ./...
+func (b *BlockSlice) debugCheck(op string) {
+	if err := b.check(); err != nil {
+		panic(fmt.Sprintf("BlockSlice.%s: %v", op, err))
+	}
+}
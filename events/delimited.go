@@ -0,0 +1,99 @@
+package events
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// MaxDelimitedMessageSize is the largest message a delimited proto stream
+// may hold, so that a corrupt length does not make a DelimitedReader
+// allocate gigabytes.
+const MaxDelimitedMessageSize = 256 << 20 // bytes
+
+// DelimitedWriter is a Sink writing messages as a delimited proto stream:
+// every message is a Message proto prefixed by its length as a uvarint. The
+// stream can be read back message by message with a DelimitedReader, e.g.
+// to import a multi-GB capture without loading it into memory.
+type DelimitedWriter struct {
+	w *bufio.Writer
+}
+
+func NewDelimitedWriter(w io.Writer) *DelimitedWriter {
+	return &DelimitedWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes one message.
+func (dw *DelimitedWriter) Write(m *Message) error {
+	bs, err := proto.Marshal(MessageToProto(m))
+	if err != nil {
+		return err
+	}
+	if len(bs) > MaxDelimitedMessageSize {
+		return fmt.Errorf("got message of %d bytes; want at most MaxDelimitedMessageSize=%d", len(bs), MaxDelimitedMessageSize)
+	}
+	var hdr [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(bs)))
+	if _, err := dw.w.Write(hdr[:n]); err != nil {
+		return err
+	}
+	_, err = dw.w.Write(bs)
+	return err
+}
+
+// Flush writes buffered messages to the underlying writer.
+func (dw *DelimitedWriter) Flush() error {
+	return dw.w.Flush()
+}
+
+func (dw *DelimitedWriter) Append(b *Block) error {
+	return dw.Write(&Message{Action: Append, Block: b})
+}
+
+func (dw *DelimitedWriter) Rollback(n uint64) error {
+	return dw.Write(&Message{Action: Rollback, Number: n})
+}
+
+func (dw *DelimitedWriter) SetNext(n uint64) error {
+	return dw.Write(&Message{Action: SetNext, Number: n})
+}
+
+// DelimitedReader reads a delimited proto stream written by a
+// DelimitedWriter.
+type DelimitedReader struct {
+	r *bufio.Reader
+}
+
+func NewDelimitedReader(r io.Reader) *DelimitedReader {
+	return &DelimitedReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next message, or io.EOF at the end of the stream. A
+// stream ending within a message is io.ErrUnexpectedEOF, and a message
+// longer than MaxDelimitedMessageSize an error.
+func (dr *DelimitedReader) Next() (*Message, error) {
+	n, err := binary.ReadUvarint(dr.r)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDelimitedMessageSize {
+		return nil, fmt.Errorf("got message length %d; want at most MaxDelimitedMessageSize=%d", n, MaxDelimitedMessageSize)
+	}
+	bs := make([]byte, n)
+	if _, err := io.ReadFull(dr.r, bs); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	pb := &epb.Message{}
+	if err := proto.Unmarshal(bs, pb); err != nil {
+		return nil, err
+	}
+	return MessageFromProto(pb)
+}
@@ -0,0 +1,252 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+const DefaultSnapshotEvery = 100 // deltas between full snapshots
+
+var (
+	checkpointFileRE = regexp.MustCompile(`^(snapshot|delta)-(\d+)\.pb$`)
+	checkpointTempRE = regexp.MustCompile(`^(snapshot|delta)-\d+\.pb\.tmp`) // see WriteFileAtomic
+)
+
+// DeltaCheckpoints stores an eventlog in a directory as a full snapshot
+// followed by deltas, each holding only the messages since the previous
+// checkpoint. Files are numbered in sequence:
+//
+//	snapshot-000000000.pb
+//	delta-000000001.pb
+//	delta-000000002.pb
+//	...
+//
+// Every SnapshotEvery deltas a new snapshot is written and the files before
+// it are removed. Load folds the latest snapshot and its deltas together.
+//
+// DeltaCheckpoints is a Sink: it must receive exactly the messages applied to
+// the eventlog passed to Save, with no Save in between. Blocks pruned from the
+// eventlog must be reported with Prune.
+type DeltaCheckpoints struct {
+	SnapshotEvery int
+
+	dir      string
+	seq      uint64 // sequence number of the last written file
+	snapshot bool   // whether a snapshot has been written or loaded
+	deltas   int    // deltas since the last snapshot
+	base     uint64 // NextBlock at the last checkpoint
+	pending  []*Message
+	prune    uint64 // blocks before it were pruned since the last checkpoint
+}
+
+type checkpointFile struct {
+	seq      uint64
+	snapshot bool
+	name     string
+}
+
+// OpenDeltaCheckpoints opens a checkpoint directory, creating it if needed.
+// Temporary files left by an interrupted write are removed.
+func OpenDeltaCheckpoints(dir string) (*DeltaCheckpoints, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if checkpointTempRE.MatchString(e.Name()) {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	c := &DeltaCheckpoints{
+		SnapshotEvery: DefaultSnapshotEvery,
+		dir:           dir,
+	}
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		c.seq = files[len(files)-1].seq
+	}
+	return c, nil
+}
+
+// files lists the checkpoint files in sequence order.
+func (c *DeltaCheckpoints) files() ([]checkpointFile, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []checkpointFile
+	for _, e := range entries {
+		m := checkpointFileRE.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		seq, err := strconv.ParseUint(m[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		files = append(files, checkpointFile{
+			seq:      seq,
+			snapshot: m[1] == "snapshot",
+			name:     filepath.Join(c.dir, e.Name()),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].seq < files[j].seq
+	})
+	return files, nil
+}
+
+// Load returns the eventlog stored in the directory. It returns an error
+// wrapping os.ErrNotExist if there is no snapshot.
+func (c *DeltaCheckpoints) Load() (*InMemoryEventLog, error) {
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	first := -1
+	for i, f := range files {
+		if f.snapshot {
+			first = i
+		}
+	}
+	if first < 0 {
+		return nil, fmt.Errorf("no snapshot in %s: %w", c.dir, os.ErrNotExist)
+	}
+	l, err := LoadCheckpoint(files[first].name)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files[first+1:] {
+		if err := applyDelta(l, f.name); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	c.snapshot = true
+	c.deltas = len(files) - first - 1
+	c.base = l.NextBlock()
+	c.pending = nil
+	c.prune = 0
+	return l, nil
+}
+
+func applyDelta(l *InMemoryEventLog, fn string) error {
+	bs, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	pb := &epb.EventLogDelta{}
+	if err := proto.Unmarshal(bs, pb); err != nil {
+		return err
+	}
+	if pb.From != l.NextBlock() {
+		return fmt.Errorf("got delta from block %d; want %d", pb.From, l.NextBlock())
+	}
+	for _, pbm := range pb.Messages {
+		m, err := MessageFromProto(pbm)
+		if err != nil {
+			return err
+		}
+		if err := Apply(l, m); err != nil {
+			return err
+		}
+	}
+	return l.Prune(pb.PruneBefore)
+}
+
+func (c *DeltaCheckpoints) Append(b *Block) error {
+	c.pending = append(c.pending, &Message{Action: Append, Block: b})
+	return nil
+}
+
+func (c *DeltaCheckpoints) Rollback(n uint64) error {
+	c.pending = append(c.pending, &Message{Action: Rollback, Number: n})
+	return nil
+}
+
+func (c *DeltaCheckpoints) SetNext(n uint64) error {
+	c.pending = append(c.pending, &Message{Action: SetNext, Number: n})
+	return nil
+}
+
+// Prune records that the blocks before a block number were dropped from the
+// eventlog, as by InMemoryEventLog.Prune, so that Load drops them too.
+func (c *DeltaCheckpoints) Prune(before uint64) {
+	if before > c.prune {
+		c.prune = before
+	}
+}
+
+// Save writes a checkpoint of l: a snapshot if none has been written yet or
+// SnapshotEvery deltas have been written since, and else a delta.
+func (c *DeltaCheckpoints) Save(l *InMemoryEventLog) error {
+	if !c.snapshot || (c.SnapshotEvery > 0 && c.deltas >= c.SnapshotEvery) {
+		return c.saveSnapshot(l)
+	}
+	if len(c.pending) == 0 && c.prune == 0 {
+		return nil
+	}
+	pb := &epb.EventLogDelta{
+		From:        c.base,
+		Messages:    make([]*epb.Message, len(c.pending)),
+		PruneBefore: c.prune,
+	}
+	for i, m := range c.pending {
+		pb.Messages[i] = MessageToProto(m)
+	}
+	bs, err := proto.Marshal(pb)
+	if err != nil {
+		return err
+	}
+	seq := c.seq + 1
+	if err := WriteFileAtomic(filepath.Join(c.dir, fmt.Sprintf("delta-%09d.pb", seq)), bs); err != nil {
+		return err
+	}
+	c.seq = seq
+	c.deltas++
+	c.base = l.NextBlock()
+	c.pending = nil
+	c.prune = 0
+	return nil
+}
+
+func (c *DeltaCheckpoints) saveSnapshot(l *InMemoryEventLog) error {
+	seq := c.seq + 1
+	if err := SaveCheckpoint(l, filepath.Join(c.dir, fmt.Sprintf("snapshot-%09d.pb", seq))); err != nil {
+		return err
+	}
+	c.seq = seq
+	c.snapshot = true
+	c.deltas = 0
+	c.base = l.NextBlock()
+	c.pending = nil
+	c.prune = 0
+
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.seq < seq {
+			if err := os.Remove(f.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
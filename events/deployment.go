@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DeploymentBlock returns the block in which a contract was deployed, found
+// by binary search for the first block at which it has code. This needs a
+// node serving historical state (an archive node). A contract that was
+// self-destructed and redeployed may give a wrong result.
+func DeploymentBlock(ctx context.Context, client Client, addr common.Address) (uint64, error) {
+	hasCode := func(n uint64) (bool, error) {
+		code, err := client.CodeAt(ctx, addr, new(big.Int).SetUint64(n))
+		return len(code) > 0, err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := hasCode(head)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no code at %s", addr.Hex())
+	}
+	lo, hi := uint64(0), head
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := hasCode(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return hi, nil
+}
+
+// ClampToDeployment returns from, or the earliest deployment block of the
+// filter's addresses if that is later. A filter without addresses matches
+// any contract, and from is returned unchanged.
+func ClampToDeployment(ctx context.Context, client Client, q ethereum.FilterQuery, from uint64) (uint64, error) {
+	if len(q.Addresses) == 0 {
+		return from, nil
+	}
+	var first uint64
+	for i, addr := range q.Addresses {
+		n, err := DeploymentBlock(ctx, client, addr)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || n < first {
+			first = n
+		}
+	}
+	if first > from {
+		return first, nil
+	}
+	return from, nil
+}
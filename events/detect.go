@@ -0,0 +1,190 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// AnomalyKind is the kind of an Anomaly.
+type AnomalyKind int
+
+const (
+	// AnomalyGap is the stream position, as set by SetNext messages,
+	// moving more than MaxGap blocks ahead at once, e.g. skipping a range.
+	// Blocks without events between Appends are not a gap.
+	AnomalyGap AnomalyKind = iota
+	// AnomalyBurst is more than BurstEvents events in BurstBlocks
+	// blocks.
+	AnomalyBurst
+	// AnomalyDrought is DroughtBlocks blocks without events.
+	AnomalyDrought
+)
+
+func (k AnomalyKind) String() string {
+	switch k {
+	case AnomalyGap:
+		return "gap"
+	case AnomalyBurst:
+		return "burst"
+	case AnomalyDrought:
+		return "drought"
+	}
+	return fmt.Sprintf("AnomalyKind(%d)", int(k))
+}
+
+// Anomaly is an unusual pattern of the blocks From..To (inclusive) seen by
+// an AnomalyDetector.
+type Anomaly struct {
+	Kind     AnomalyKind
+	From, To uint64
+	Events   int // events of the blocks, for AnomalyBurst
+}
+
+func (a Anomaly) String() string {
+	if a.Kind == AnomalyBurst {
+		return fmt.Sprintf("%v of %d events in blocks %d:%d", a.Kind, a.Events, a.From, a.To)
+	}
+	return fmt.Sprintf("%v in blocks %d:%d", a.Kind, a.From, a.To)
+}
+
+// AnomalyDetector wraps a Sink to detect gaps, bursts and droughts in the
+// messages passed to it, both to monitor the pipeline and to alert on
+// on-chain activity. Zero thresholds disable a detector. Sink may be nil to
+// only detect.
+//
+// A burst or drought is reported once, when it crosses its threshold; a
+// drought again only after the next event. Streams send a SetNext after
+// every batch, so MaxGap must exceed the fetch batch size not to report
+// every batch of a backfill.
+type AnomalyDetector struct {
+	Sink Sink
+
+	MaxGap        uint64
+	BurstBlocks   uint64 // window of BurstEvents; 1 if zero
+	BurstEvents   int
+	DroughtBlocks uint64
+
+	// OnAnomaly, if set, is called instead of logging a warning.
+	OnAnomaly func(Anomaly)
+
+	started   bool
+	next      uint64
+	progress  uint64       // position of the last SetNext, for gaps
+	lastEvent uint64       // block after the last block with events
+	recent    []blockCount // blocks with events of the burst window
+	bursting  bool
+	drought   bool
+}
+
+// blockCount is the number of events of a block.
+type blockCount struct {
+	number uint64
+	events int
+}
+
+func (d *AnomalyDetector) report(a Anomaly) {
+	if d.OnAnomaly != nil {
+		d.OnAnomaly(a)
+		return
+	}
+	logf(context.Background(), "warning: %v\n", a)
+}
+
+// advance moves the detector to block n, checking for a drought.
+func (d *AnomalyDetector) advance(n uint64) {
+	if !d.started {
+		d.started = true
+		d.next, d.lastEvent, d.progress = n, n, n
+		return
+	}
+	if n > d.next {
+		d.next = n
+	}
+	if d.DroughtBlocks > 0 && !d.drought && d.next >= d.lastEvent+d.DroughtBlocks {
+		d.drought = true
+		d.report(Anomaly{Kind: AnomalyDrought, From: d.lastEvent, To: d.next - 1})
+	}
+}
+
+func (d *AnomalyDetector) Append(b *Block) error {
+	d.advance(b.Number)
+	if len(b.Events) > 0 {
+		d.lastEvent = b.Number + 1
+		d.drought = false
+		d.burst(b)
+	}
+	d.advance(b.Number + 1)
+	if d.Sink == nil {
+		return nil
+	}
+	return d.Sink.Append(b)
+}
+
+// burst adds the events of b to the burst window.
+func (d *AnomalyDetector) burst(b *Block) {
+	if d.BurstEvents <= 0 {
+		return
+	}
+	window := d.BurstBlocks
+	if window == 0 {
+		window = 1
+	}
+	d.recent = append(d.recent, blockCount{number: b.Number, events: len(b.Events)})
+	i := 0
+	for i < len(d.recent) && d.recent[i].number+window <= b.Number {
+		i++
+	}
+	d.recent = d.recent[i:]
+	total := 0
+	for _, c := range d.recent {
+		total += c.events
+	}
+	if total <= d.BurstEvents {
+		d.bursting = false
+		return
+	}
+	if !d.bursting {
+		d.bursting = true
+		d.report(Anomaly{Kind: AnomalyBurst, From: d.recent[0].number, To: b.Number, Events: total})
+	}
+}
+
+// Rollback drops the events of the dropped blocks from the burst window.
+// If the last block with events is dropped, the drought is measured from
+// the rollback, as older blocks are not kept.
+func (d *AnomalyDetector) Rollback(n uint64) error {
+	i := len(d.recent)
+	for i > 0 && d.recent[i-1].number >= n {
+		i--
+	}
+	d.recent = d.recent[:i]
+	if d.lastEvent > n {
+		d.lastEvent = n
+		d.drought = false
+	}
+	if d.next > n {
+		d.next = n
+	}
+	if d.progress > n {
+		d.progress = n
+	}
+	d.bursting = false
+	if d.Sink == nil {
+		return nil
+	}
+	return d.Sink.Rollback(n)
+}
+
+func (d *AnomalyDetector) SetNext(n uint64) error {
+	if d.started && d.MaxGap > 0 && n > d.progress+d.MaxGap {
+		d.report(Anomaly{Kind: AnomalyGap, From: d.progress, To: n - 1})
+	}
+	d.advance(n)
+	if n > d.progress {
+		d.progress = n
+	}
+	if d.Sink == nil {
+		return nil
+	}
+	return d.Sink.SetNext(n)
+}
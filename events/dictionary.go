@@ -0,0 +1,60 @@
+package events
+
+import (
+	"fmt"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// minDictionaryData is the length from which a repeated data payload is
+// moved to the dictionary; shorter ones cost about as much as a reference.
+const minDictionaryData = 8
+
+// DedupData moves the event data payloads occurring more than once in a
+// BlockSlice, e.g. of airdrops, to its data dictionary, replacing them by
+// references. SaveCheckpointWith uses it with CheckpointOptions.DedupData.
+// Versions without dictionary support read such a BlockSlice with the
+// referenced data missing.
+func DedupData(pb *epb.BlockSlice) {
+	counts := make(map[string]int)
+	for _, b := range pb.Blocks {
+		for _, e := range b.Events {
+			if len(e.Data) >= minDictionaryData {
+				counts[string(e.Data)]++
+			}
+		}
+	}
+	refs := make(map[string]uint32)
+	for _, b := range pb.Blocks {
+		for _, e := range b.Events {
+			if len(e.Data) < minDictionaryData || counts[string(e.Data)] < 2 {
+				continue
+			}
+			ref, ok := refs[string(e.Data)]
+			if !ok {
+				pb.DataDictionary = append(pb.DataDictionary, e.Data)
+				ref = uint32(len(pb.DataDictionary))
+				refs[string(e.Data)] = ref
+			}
+			e.Data, e.DataRef = nil, ref
+		}
+	}
+}
+
+// resolveData replaces the data references of a BlockSlice by the data of
+// its dictionary, in place.
+func resolveData(pb *epb.BlockSlice) error {
+	for _, b := range pb.Blocks {
+		for _, e := range b.Events {
+			if e.DataRef == 0 {
+				continue
+			}
+			if int(e.DataRef) > len(pb.DataDictionary) {
+				return fmt.Errorf("block %d: got data_ref %d; dictionary has %d entries", b.Number, e.DataRef, len(pb.DataDictionary))
+			}
+			e.Data, e.DataRef = pb.DataDictionary[e.DataRef-1], 0
+		}
+	}
+	pb.DataDictionary = nil
+	return nil
+}
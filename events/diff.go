@@ -0,0 +1,131 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DiffKind is the kind of a Difference between two BlockSlices.
+type DiffKind int
+
+const (
+	BlockOnlyInA DiffKind = iota
+	BlockOnlyInB
+	HashMismatch
+	EventOnlyInA
+	EventOnlyInB
+	EventMismatch
+)
+
+// Difference is a difference between the blocks a and b of two BlockSlices.
+// Index is the log index for the Event kinds.
+type Difference struct {
+	Kind  DiffKind
+	Block uint64
+	Index uint64
+	A, B  *Block
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case BlockOnlyInA:
+		return fmt.Sprintf("block %d: only in a (%d events)", d.Block, len(d.A.Events))
+	case BlockOnlyInB:
+		return fmt.Sprintf("block %d: only in b (%d events)", d.Block, len(d.B.Events))
+	case HashMismatch:
+		return fmt.Sprintf("block %d: hash a=%s b=%s", d.Block, d.A.Hash.Hex(), d.B.Hash.Hex())
+	case EventOnlyInA:
+		return fmt.Sprintf("block %d: event %d only in a", d.Block, d.Index)
+	case EventOnlyInB:
+		return fmt.Sprintf("block %d: event %d only in b", d.Block, d.Index)
+	case EventMismatch:
+		return fmt.Sprintf("block %d: event %d differs", d.Block, d.Index)
+	}
+	return fmt.Sprintf("block %d: DiffKind(%d)", d.Block, d.Kind)
+}
+
+// Diff compares two BlockSlices in the block range they both cover. It
+// reports the blocks present in only one of them, blocks with different
+// hashes, and for blocks with the same hash, the events present in only one
+// of them or with a different address, topics, data or transaction.
+func Diff(a, b *BlockSlice) []Difference {
+	start, end := a.Start, a.End
+	if b.Start > start {
+		start = b.Start
+	}
+	if b.End < end {
+		end = b.End
+	}
+	var diffs []Difference
+	i, j := 0, 0
+	for {
+		for i < len(a.Blocks) && a.Blocks[i].Number < start {
+			i++
+		}
+		for j < len(b.Blocks) && b.Blocks[j].Number < start {
+			j++
+		}
+		var ab, bb *Block
+		if i < len(a.Blocks) && a.Blocks[i].Number < end {
+			ab = a.Blocks[i]
+		}
+		if j < len(b.Blocks) && b.Blocks[j].Number < end {
+			bb = b.Blocks[j]
+		}
+		switch {
+		case ab == nil && bb == nil:
+			return diffs
+		case bb == nil || ab != nil && ab.Number < bb.Number:
+			diffs = append(diffs, Difference{Kind: BlockOnlyInA, Block: ab.Number, A: ab})
+			i++
+		case ab == nil || bb.Number < ab.Number:
+			diffs = append(diffs, Difference{Kind: BlockOnlyInB, Block: bb.Number, B: bb})
+			j++
+		case ab.Hash != bb.Hash:
+			diffs = append(diffs, Difference{Kind: HashMismatch, Block: ab.Number, A: ab, B: bb})
+			i++
+			j++
+		default:
+			diffs = diffEvents(diffs, ab, bb)
+			i++
+			j++
+		}
+	}
+}
+
+func diffEvents(diffs []Difference, a, b *Block) []Difference {
+	aevents := make(map[uint64]*Event)
+	for i := range a.Events {
+		aevents[a.Events[i].Index] = &a.Events[i]
+	}
+	for i := range b.Events {
+		be := &b.Events[i]
+		ae, ok := aevents[be.Index]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: EventOnlyInB, Block: b.Number, Index: be.Index, A: a, B: b})
+			continue
+		}
+		delete(aevents, be.Index)
+		if !sameEvent(ae, be) {
+			diffs = append(diffs, Difference{Kind: EventMismatch, Block: b.Number, Index: be.Index, A: a, B: b})
+		}
+	}
+	for i := range a.Events {
+		if _, ok := aevents[a.Events[i].Index]; ok {
+			diffs = append(diffs, Difference{Kind: EventOnlyInA, Block: a.Number, Index: a.Events[i].Index, A: a, B: b})
+		}
+	}
+	return diffs
+}
+
+func sameEvent(a, b *Event) bool {
+	if a.Address != b.Address || a.TxHash != b.TxHash || len(a.Topics) != len(b.Topics) {
+		return false
+	}
+	for i := range a.Topics {
+		if a.Topics[i] != b.Topics[i] {
+			return false
+		}
+	}
+	return bytes.Equal(a.Data, b.Data)
+}
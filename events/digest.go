@@ -0,0 +1,200 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DigestVersion is the version of the canonical block encoding. It is part
+// of the encoding, so that replicas running incompatible versions disagree
+// instead of comparing different things.
+const DigestVersion = 1
+
+// canonicalBlock is the canonical encoding of a block: the fields every
+// indexer of the same chain and filter has, in a fixed order. Transaction
+// details, headers, Time and Meta are left out, as they depend on options.
+type canonicalBlock struct {
+	Version uint
+	Number  uint64
+	Hash    common.Hash
+	Events  []eventLeaf
+}
+
+// CanonicalBytes returns the canonical serialization of a block: the RLP
+// encoding of DigestVersion, the number, the hash and the address, topics,
+// data, log index and transaction hash of every event, in order.
+func CanonicalBytes(b *Block) []byte {
+	cb := &canonicalBlock{
+		Version: DigestVersion,
+		Number:  b.Number,
+		Hash:    b.Hash,
+		Events:  make([]eventLeaf, len(b.Events)),
+	}
+	for i := range b.Events {
+		e := &b.Events[i]
+		cb.Events[i] = eventLeaf{
+			Address: e.Address,
+			Topics:  e.Topics,
+			Data:    e.Data,
+			Index:   e.Index,
+			TxHash:  e.TxHash,
+		}
+	}
+	bs, err := rlp.EncodeToBytes(cb)
+	if err != nil {
+		panic(err) // the block has no types RLP can't encode
+	}
+	return bs
+}
+
+// BlockDigest returns the keccak256 of CanonicalBytes.
+func BlockDigest(b *Block) common.Hash {
+	return crypto.Keccak256Hash(CanonicalBytes(b))
+}
+
+// blockDigest is the chained digest after a block.
+type blockDigest struct {
+	number uint64
+	digest common.Hash
+}
+
+// Digester is a Sink chaining the BlockDigest of every appended block into
+// one running digest, keccak256(previous, BlockDigest(b)), starting from
+// the zero hash. Two replicas streaming the same filter from the same block
+// can exchange DigestAt of a block both have passed to verify that they
+// indexed identical data, without exchanging the data. Positions set by
+// SetNext are not part of the digest, as they depend on batching.
+//
+// The digests of the last Retain blocks are kept, to roll back and to
+// answer DigestAt. A Digester is safe for concurrent use.
+type Digester struct {
+	Retain uint64 // DefaultDigestRetain if zero
+
+	mu      sync.Mutex
+	digest  common.Hash
+	history []blockDigest // by increasing block number
+	start   uint64        // block of the first message
+	started bool
+	pruned  bool // digests of old blocks were dropped
+	next    uint64
+}
+
+// DefaultDigestRetain is the default number of blocks of digests a
+// Digester keeps.
+const DefaultDigestRetain = 1024
+
+func (d *Digester) retain() uint64 {
+	if d.Retain == 0 {
+		return DefaultDigestRetain
+	}
+	return d.Retain
+}
+
+func (d *Digester) begin(n uint64) {
+	if !d.started {
+		d.started = true
+		d.start, d.next = n, n
+	}
+}
+
+func (d *Digester) Append(b *Block) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.begin(b.Number)
+	if b.Number < d.next {
+		return fmt.Errorf("got block %d; want block >= %d", b.Number, d.next)
+	}
+	h := BlockDigest(b)
+	d.digest = crypto.Keccak256Hash(d.digest[:], h[:])
+	d.history = append(d.history, blockDigest{number: b.Number, digest: d.digest})
+	d.setNext(b.Number + 1)
+	return nil
+}
+
+func (d *Digester) setNext(n uint64) {
+	d.next = n
+	if n <= d.retain() {
+		return
+	}
+	keep := n - d.retain()
+	// Keep the last digest before the window, as the digest as of the
+	// blocks up to it.
+	i := 0
+	for i+1 < len(d.history) && d.history[i+1].number < keep {
+		i++
+	}
+	if i > 0 {
+		d.history = d.history[i:]
+		d.pruned = true
+	}
+}
+
+func (d *Digester) Rollback(n uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n > d.next {
+		return fmt.Errorf("got rollback to %d; want <= %d", n, d.next)
+	}
+	digest, err := d.at(n)
+	if err != nil {
+		return err
+	}
+	i := len(d.history)
+	for i > 0 && d.history[i-1].number >= n {
+		i--
+	}
+	d.history = d.history[:i]
+	d.digest = digest
+	d.next = n
+	return nil
+}
+
+func (d *Digester) SetNext(n uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.begin(n)
+	if n < d.next {
+		return fmt.Errorf("got SetNext(%d); want >= %d", n, d.next)
+	}
+	d.setNext(n)
+	return nil
+}
+
+// Digest returns the next block and the digest of all blocks before it.
+func (d *Digester) Digest() (uint64, common.Hash) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.next, d.digest
+}
+
+// DigestAt returns the digest of the blocks before block n. It fails for
+// blocks not yet passed, and for blocks older than the kept digests.
+func (d *Digester) DigestAt(n uint64) (common.Hash, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n > d.next {
+		return common.Hash{}, fmt.Errorf("got block %d; the digester is at block %d", n, d.next)
+	}
+	return d.at(n)
+}
+
+func (d *Digester) at(n uint64) (common.Hash, error) {
+	if n < d.start {
+		return common.Hash{}, fmt.Errorf("got block %d; the digester started at block %d", n, d.start)
+	}
+	i := len(d.history)
+	for i > 0 && d.history[i-1].number >= n {
+		i--
+	}
+	if i > 0 {
+		return d.history[i-1].digest, nil
+	}
+	if !d.pruned {
+		return common.Hash{}, nil
+	}
+	return common.Hash{}, fmt.Errorf("got block %d; digests are only kept for the last %d blocks", n, d.retain())
+}
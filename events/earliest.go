@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// EarliestBlockError is returned when streaming from a block older than the
+// earliest block a (pruned) node serves, where getLogs would return nothing
+// rather than fail.
+type EarliestBlockError struct {
+	From     uint64
+	Earliest uint64
+}
+
+func (e *EarliestBlockError) Error() string {
+	return fmt.Sprintf("got from=%d; the node serves blocks from %d", e.From, e.Earliest)
+}
+
+// EarliestBlock returns the earliest block whose header the node serves,
+// found by binary search. It is 0 unless the node pruned its history.
+func EarliestBlock(ctx context.Context, client Client) (uint64, error) {
+	available := func(n uint64) (bool, error) {
+		_, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if errors.Is(err, ethereum.NotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	ok, err := available(0)
+	if err != nil || ok {
+		return 0, err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	lo, hi := uint64(1), head
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := available(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return hi, nil
+}
+
+// checkAvailable returns an EarliestBlockError if the node does not serve
+// block from. It costs one call if it does.
+func checkAvailable(ctx context.Context, client Client, from uint64) error {
+	_, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(from))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if from > head {
+		return nil // not mined yet
+	}
+	earliest, err := EarliestBlock(ctx, client)
+	if err != nil {
+		return err
+	}
+	if earliest <= from {
+		return nil
+	}
+	return &EarliestBlockError{From: from, Earliest: earliest}
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"fmt"
+	"time"
+)
+
+// EffectiveConfig is the configuration a ChainStreamer streams with, after
+// defaults are applied. Defaults may change between versions, so it is
+// worth logging; Stream logs it, and StreamStats snapshots include it.
+type EffectiveConfig struct {
+	FetchBatchSize uint64        `json:"fetchBatchSize"` // blocks per getLogs call
+	BatchOverlap   uint64        `json:"batchOverlap"`   // blocks refetched per poll
+	PollInterval   time.Duration `json:"pollInterval"`   // wait between polls at head
+	Buffer         int           `json:"buffer"`         // messages
+	MaxBacklog     uint64        `json:"maxBacklog"`     // blocks; 0 is unlimited
+
+	FetchTxDetails  bool   `json:"fetchTxDetails"`
+	TrackHeaders    bool   `json:"trackHeaders"`
+	EventsRoots     bool   `json:"eventsRoots"`
+	BlockTimestamps bool   `json:"blockTimestamps"`
+	AutoTuneOverlap bool   `json:"autoTuneOverlap"`
+	MaxBatchOverlap uint64 `json:"maxBatchOverlap"` // auto-tuning bound; 0 is FetchBatchSize-1
+	ClampToEarliest bool   `json:"clampToEarliest"`
+	WaitForFrom     bool   `json:"waitForFrom"`
+	RollbackBlocks  bool   `json:"rollbackBlocks"`
+
+	InvalidLogRetries    int           `json:"invalidLogRetries"`
+	InvalidLogRetryDelay time.Duration `json:"invalidLogRetryDelay"`
+}
+
+// EffectiveConfig returns the configuration the ChainStreamer streams with.
+// With BatchOverlapDuration set, the overlap depends on the block time that
+// Stream observes, so it is only resolved in the config Stream logs and
+// records in Stats.
+func (cr *ChainStreamer) EffectiveConfig() EffectiveConfig {
+	c := EffectiveConfig{
+		FetchBatchSize: cr.FetchBatchSize,
+		BatchOverlap:   cr.BatchOverlap,
+		PollInterval:   time.Duration(DefaultPollInterval) * time.Second,
+		Buffer:         cr.Buffer,
+		MaxBacklog:     cr.MaxBacklog,
+
+		FetchTxDetails:  cr.FetchTxDetails,
+		TrackHeaders:    cr.TrackHeaders,
+		EventsRoots:     cr.EventsRoots,
+		BlockTimestamps: cr.BlockTimestamps,
+		AutoTuneOverlap: cr.AutoTuneOverlap,
+		MaxBatchOverlap: cr.MaxBatchOverlap,
+		ClampToEarliest: cr.ClampToEarliest,
+		WaitForFrom:     cr.WaitForFrom,
+		RollbackBlocks:  cr.RollbackBlocks,
+
+		InvalidLogRetries:    InvalidLogRetries,
+		InvalidLogRetryDelay: InvalidLogRetryDelay,
+	}
+	if c.FetchBatchSize == 0 {
+		c.FetchBatchSize = DefaultFetchBatchSize
+	}
+	if c.BatchOverlap == 0 {
+		c.BatchOverlap = DefaultBatchOverlap
+	}
+	return c
+}
+
+func (c EffectiveConfig) String() string {
+	return fmt.Sprintf("fetch_batch_size=%d batch_overlap=%d poll_interval=%v buffer=%d max_backlog=%d "+
+		"fetch_tx_details=%v track_headers=%v events_roots=%v block_timestamps=%v auto_tune_overlap=%v max_batch_overlap=%d clamp_to_earliest=%v wait_for_from=%v rollback_blocks=%v "+
+		"invalid_log_retries=%d invalid_log_retry_delay=%v",
+		c.FetchBatchSize, c.BatchOverlap, c.PollInterval, c.Buffer, c.MaxBacklog,
+		c.FetchTxDetails, c.TrackHeaders, c.EventsRoots, c.BlockTimestamps, c.AutoTuneOverlap, c.MaxBatchOverlap, c.ClampToEarliest, c.WaitForFrom, c.RollbackBlocks,
+		c.InvalidLogRetries, c.InvalidLogRetryDelay)
+}
@@ -0,0 +1,41 @@
+package events
+
+import "time"
+
+// rateWeight is the weight of the newest sample in a smoothed rate of
+// progress, so that the rate follows changes in fetch speed within a few
+// batches without jumping with every one.
+const rateWeight = 0.2
+
+// rateEstimator smooths the rate at which a block number advances.
+type rateEstimator struct {
+	last time.Time
+	pos  uint64
+	rate float64 // blocks per second; 0 until known
+}
+
+// observe records that the position was pos at time now. A position going
+// back, e.g. after a rollback, restarts the sample without changing the
+// rate.
+func (r *rateEstimator) observe(now time.Time, pos uint64) {
+	if !r.last.IsZero() && pos >= r.pos {
+		if dt := now.Sub(r.last).Seconds(); dt > 0 {
+			sample := float64(pos-r.pos) / dt
+			if r.rate == 0 {
+				r.rate = sample
+			} else {
+				r.rate += rateWeight * (sample - r.rate)
+			}
+		}
+	}
+	r.last, r.pos = now, pos
+}
+
+// ETA returns the time to advance remaining blocks at rate blocks per
+// second, or zero if the rate is not positive.
+func ETA(remaining uint64, rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / rate * float64(time.Second))
+}
@@ -0,0 +1,167 @@
+// Package ethrpc connects the events package to Ethereum nodes with the
+// go-ethereum RPC client. Importing it registers Dial as the events Dialer.
+package ethrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+func init() {
+	events.RegisterDialer(func(ctx context.Context, url string) (events.Client, error) {
+		return Dial(ctx, url)
+	})
+}
+
+// Client is an ethclient.Client that also implements
+// events.LogTimestampClient and events.BatchCallClient.
+type Client struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// Dial connects to a node like ethclient.DialContext, after expanding the
+// secrets referenced by the URL (see events.ExpandURL). Over HTTP, every
+// call carries the correlation ID of its context (see
+// events.WithCorrelationID) in the events.CorrelationHeader; other
+// transports have no per-call headers.
+func Dial(ctx context.Context, url string) (*Client, error) {
+	url, err := events.ExpandURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	var c *rpc.Client
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		c, err = rpc.DialContext(ctx, url)
+	} else {
+		c, err = rpc.DialHTTPWithClient(url, &http.Client{
+			Transport: &correlationTransport{base: http.DefaultTransport},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: ethclient.NewClient(c), rpc: c}, nil
+}
+
+// FilterLogsTimestamps is FilterLogs, also returning the blockTimestamp
+// of the logs of providers that include it, by block hash.
+func (c *Client) FilterLogsTimestamps(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, map[common.Hash]uint64, error) {
+	arg, err := toFilterArg(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raw []json.RawMessage
+	if err := c.rpc.CallContext(ctx, &raw, "eth_getLogs", arg); err != nil {
+		return nil, nil, err
+	}
+	logs := make([]types.Log, len(raw))
+	times := make(map[common.Hash]uint64)
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &logs[i]); err != nil {
+			return nil, nil, err
+		}
+		var t struct {
+			BlockTimestamp *hexutil.Uint64 `json:"blockTimestamp"`
+		}
+		if err := json.Unmarshal(r, &t); err != nil {
+			return nil, nil, err
+		}
+		if t.BlockTimestamp != nil {
+			times[logs[i].BlockHash] = uint64(*t.BlockTimestamp)
+		}
+	}
+	return logs, times, nil
+}
+
+// BatchCallContract makes the calls at blockNumber in one batch request.
+func (c *Client) BatchCallContract(ctx context.Context, msgs []ethereum.CallMsg, blockNumber *big.Int) ([][]byte, []error, error) {
+	results := make([]hexutil.Bytes, len(msgs))
+	batch := make([]rpc.BatchElem, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []interface{}{toCallArg(msg), hexutil.EncodeBig(blockNumber)},
+			Result: &results[i],
+		}
+	}
+	if err := c.rpc.BatchCallContext(ctx, batch); err != nil {
+		return nil, nil, err
+	}
+	values := make([][]byte, len(msgs))
+	errs := make([]error, len(msgs))
+	for i := range batch {
+		values[i], errs[i] = results[i], batch[i].Error
+	}
+	return values, errs, nil
+}
+
+// toCallArg is the eth_call argument of ethclient.CallContract.
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	return arg
+}
+
+// toFilterArg is the eth_getLogs argument of ethclient.FilterLogs.
+func toFilterArg(q ethereum.FilterQuery) (interface{}, error) {
+	arg := map[string]interface{}{
+		"address": q.Addresses,
+		"topics":  q.Topics,
+	}
+	if q.BlockHash != nil {
+		if q.FromBlock != nil || q.ToBlock != nil {
+			return nil, fmt.Errorf("cannot specify both BlockHash and FromBlock/ToBlock")
+		}
+		arg["blockHash"] = *q.BlockHash
+		return arg, nil
+	}
+	arg["fromBlock"] = "0x0"
+	if q.FromBlock != nil {
+		arg["fromBlock"] = hexutil.EncodeBig(q.FromBlock)
+	}
+	arg["toBlock"] = "latest"
+	if q.ToBlock != nil {
+		arg["toBlock"] = hexutil.EncodeBig(q.ToBlock)
+	}
+	return arg, nil
+}
+
+type correlationTransport struct {
+	base http.RoundTripper
+}
+
+func (t *correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := events.CorrelationID(req.Context()); id != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(events.CorrelationHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -1,18 +1,61 @@
 package events
 
 import (
+	"errors"
+
 	"github.com/ethereum/go-ethereum"
 )
 
+// Sink receives the messages of a stream.
+type Sink interface {
+	Append(*Block) error
+	Rollback(uint64) error
+	SetNext(uint64) error
+}
+
+// UndoSink is a Sink that can compensate for exactly the blocks a Rollback
+// drops, e.g. delete their rows, without keeping a copy of them. Apply
+// calls Undo instead of Rollback for Rollback messages carrying the
+// dropped blocks.
+type UndoSink interface {
+	Sink
+	Undo(n uint64, dropped []*Block) error
+}
+
 // EventLog represents a sequence of events matching a filter.
 type EventLog interface {
 	Streamer
+	Sink
 
-	Append(*Block) error
-	Rollback(uint64) error
-	SetNext(uint64) error
 	FirstBlock() uint64
 	NextBlock() uint64
 	Filter() ethereum.FilterQuery
+
+	// Prune drops the blocks before a block number, e.g. to apply a
+	// retention policy. Eventlogs that can't prune return
+	// ErrPruneUnsupported, even when there is nothing to prune, and
+	// implement PruneChecker; see CanPrune.
+	Prune(before uint64) error
+
 	Close() error
 }
+
+// ErrPruneUnsupported is returned by the Prune method of eventlogs that
+// can't drop their oldest blocks.
+var ErrPruneUnsupported = errors.New("eventlog does not support pruning")
+
+// PruneChecker is implemented by eventlogs that support Prune only in some
+// configurations, like those wrapping another eventlog, to tell whether
+// they do without calling Prune.
+type PruneChecker interface {
+	CanPrune() bool
+}
+
+// CanPrune reports whether l supports Prune. An eventlog that does not
+// implement PruneChecker is assumed to.
+func CanPrune(l EventLog) bool {
+	if pc, ok := l.(PruneChecker); ok {
+		return pc.CanPrune()
+	}
+	return true
+}
@@ -17,19 +17,22 @@
 // sending Rollback messages when a chain reorganization is detected. An
 // EventLog implements both the receiving methods of a stream (Append,
 // Rollback, SetNext), and the Streamer interface to emit the stored events.
+//
+// The RPC client lives in the ethrpc subpackage, so that programs only
+// reading stored eventlogs do not link it. Programs streaming from a node
+// URL import it to register a Dialer.
 package events
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
-	"sort"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 type Event struct {
@@ -47,6 +50,14 @@ type Event struct {
 	TxValue *big.Int
 	TxFrom  common.Address
 	TxGas   uint64
+
+	// TxDataHash is the keccak256 of TxData, set when TxData was stripped
+	// by StripTxData.
+	TxDataHash common.Hash
+
+	// TxMissing is set when the transaction details could not be fetched,
+	// e.g. because the node pruned the transaction.
+	TxMissing bool
 }
 
 func (e *Event) Log() *types.Log {
@@ -63,9 +74,18 @@ func (e *Event) Log() *types.Log {
 }
 
 type Block struct {
-	Number uint64
-	Hash   common.Hash
-	Events []Event
+	Number     uint64
+	Hash       common.Hash
+	ParentHash common.Hash // zero unless headers are tracked
+	Time       uint64      // unix timestamp; zero unless known
+	Events     []Event
+
+	// Meta holds annotations added by a ChainStreamer BlockHook.
+	Meta map[string]string
+
+	// EventsRoot is the merkle root of the events; zero unless set with
+	// SetEventsRoot.
+	EventsRoot common.Hash
 }
 
 // MatchHistory compares the new blocks with the old where they overlap. It
@@ -84,8 +104,12 @@ func MatchBlocks(new, old *BlockSlice) (bool, uint64, error) {
 	ok := true
 	for i := 0; i < len(o.Blocks); i++ {
 		ob := o.Blocks[i]
+		if i >= len(new.Blocks) {
+			ok = false
+			break
+		}
 		nb := new.Blocks[i]
-		if !bytes.Equal(ob.Hash.Bytes(), nb.Hash.Bytes()) {
+		if ob.Number != nb.Number || !bytes.Equal(ob.Hash.Bytes(), nb.Hash.Bytes()) {
 			ok = false
 			break
 		}
@@ -97,35 +121,71 @@ func MatchBlocks(new, old *BlockSlice) (bool, uint64, error) {
 // GetLogs returns a batch of logs matching a query. The blocks in the
 // block are guaranteed to be sorted by increasing Number, and the events
 // therein by Index.
-func GetLogs(ctx context.Context, client *ethclient.Client, q *ethereum.FilterQuery) (*BlockSlice, error) {
+//
+// The range is clamped to the chain head, read once before the logs are
+// fetched, and the head is returned along with the logs. A nil ToBlock means
+// the head. The query itself is not modified. If FromBlock is beyond the
+// head, the returned BlockSlice is empty with End == Start.
+//
+// Logs returned out of order, duplicated or marked removed by the provider
+// are sorted, deduplicated and dropped, and logged as a LogAnomaly. Logs of
+// two versions of a block are an error. If the provider includes the
+// blockTimestamp in logs, it sets the Time of the blocks. Logs with a zero
+// block hash or number, or outside the range, are artifacts of some
+// providers during reorgs: the call is retried, and if they persist, it is
+// an error.
+func GetLogs(ctx context.Context, client Client, q ethereum.FilterQuery) (*BlockSlice, uint64, error) {
+	return getLogs(ctx, client, q, nil)
+}
+
+// getLogs is GetLogs, reporting anomalies to onAnomaly if set.
+func getLogs(ctx context.Context, client Client, q ethereum.FilterQuery, onAnomaly func(LogAnomaly)) (*BlockSlice, uint64, error) {
 	head, err := client.BlockNumber(ctx)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if q.ToBlock.Uint64() >= head {
-		q.ToBlock.SetUint64(head)
+	from := uint64(0)
+	if q.FromBlock != nil {
+		from = q.FromBlock.Uint64()
+	}
+	to := head
+	if q.ToBlock != nil && q.ToBlock.Uint64() < head {
+		to = q.ToBlock.Uint64()
+	}
+	if from > to {
+		return EmptyBlockSlice(from), head, nil
 	}
+	q.FromBlock = new(big.Int).SetUint64(from)
+	q.ToBlock = new(big.Int).SetUint64(to)
 
-	logs, err := client.FilterLogs(ctx, *q)
+	times := make(map[common.Hash]uint64)
+	logs, quarantined, retries, err := filterValidLogs(ctx, client, q, from, to, times)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	sort.Slice(logs, func(i, j int) bool {
-		if logs[i].BlockNumber == logs[j].BlockNumber {
-			return logs[i].Index < logs[j].Index
+	logs, anomaly, err := canonicalLogs(logs)
+	if err != nil {
+		return nil, 0, err
+	}
+	anomaly.Quarantined, anomaly.Retries = quarantined, retries
+	if anomaly.any() {
+		anomaly.From, anomaly.To = from, to
+		if onAnomaly != nil {
+			onAnomaly(anomaly)
+		} else {
+			logf(ctx, "warning: %v\n", anomaly)
 		}
-		return logs[i].BlockNumber < logs[j].BlockNumber
-	})
+	}
 	slice := &BlockSlice{
-		Start:            q.FromBlock.Uint64(),
-		End:              q.ToBlock.Uint64() + 1,
-		DistanceFromHead: head - q.ToBlock.Uint64(),
+		Start:            from,
+		End:              to + 1,
+		DistanceFromHead: head - to,
 		Blocks:           make([]*Block, 0),
 	}
 
 	if len(logs) == 0 {
-		return slice, nil
+		return slice, head, nil
 	}
 
 	var block *Block = nil
@@ -137,6 +197,7 @@ func GetLogs(ctx context.Context, client *ethclient.Client, q *ethereum.FilterQu
 			block = &Block{
 				Number: l.BlockNumber,
 				Hash:   l.BlockHash,
+				Time:   times[l.BlockHash],
 				Events: make([]Event, 0),
 			}
 		}
@@ -158,19 +219,29 @@ func GetLogs(ctx context.Context, client *ethclient.Client, q *ethereum.FilterQu
 		slice.Blocks = append(slice.Blocks, block)
 	}
 
-	return slice, nil
+	return slice, head, nil
 }
 
-func AddTransactionData(ctx context.Context, client *ethclient.Client, bs *BlockSlice) error {
+// AddTransactionData fetches the transactions of the events in a BlockSlice
+// and sets their details. Events whose transaction the node does not have,
+// e.g. a node without the full transaction index, get TxMissing instead.
+func AddTransactionData(ctx context.Context, client Client, bs *BlockSlice) error {
 	transactions := make(map[string]*types.Transaction)
+	transactionData := make(map[string][]byte)
 	transactionSenders := make(map[string]common.Address)
 	getTransaction := func(e *Event) (*types.Transaction, common.Address, error) {
 		h := e.TxHash
 		key := h.Hex()
 		if tx, ok := transactions[key]; ok {
+			if tx == nil {
+				return nil, common.Address{}, ethereum.NotFound
+			}
 			return tx, transactionSenders[key], nil
 		}
 		tx, _, err := client.TransactionByHash(ctx, h)
+		if errors.Is(err, ethereum.NotFound) {
+			transactions[key] = nil
+		}
 		if err != nil {
 			return nil, common.Address{}, err
 		}
@@ -179,6 +250,7 @@ func AddTransactionData(ctx context.Context, client *ethclient.Client, bs *Block
 			sender = common.Address{}
 		}
 		transactions[key] = tx
+		transactionData[key] = tx.Data()
 		transactionSenders[key] = sender
 		return tx, sender, nil
 	}
@@ -187,10 +259,14 @@ func AddTransactionData(ctx context.Context, client *ethclient.Client, bs *Block
 		for i := range b.Events {
 			e := &b.Events[i]
 			tx, sender, err := getTransaction(e)
+			if errors.Is(err, ethereum.NotFound) {
+				e.TxMissing = true
+				continue
+			}
 			if err != nil {
 				return err
 			}
-			e.TxData = tx.Data()
+			e.TxData = transactionData[e.TxHash.Hex()] // shared by the events of a tx
 			e.TxValue = tx.Value()
 			e.TxFrom = sender
 			e.TxGas = tx.Gas()
@@ -198,3 +274,17 @@ func AddTransactionData(ctx context.Context, client *ethclient.Client, bs *Block
 	}
 	return nil
 }
+
+// countTxMissing returns the number of events in a BlockSlice marked
+// TxMissing.
+func countTxMissing(bs *BlockSlice) int {
+	n := 0
+	for _, b := range bs.Blocks {
+		for i := range b.Events {
+			if b.Events[i].TxMissing {
+				n++
+			}
+		}
+	}
+	return n
+}
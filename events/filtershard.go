@@ -0,0 +1,388 @@
+package events
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// FilterSharder is a Streamer watching a large set of addresses with
+// several ChainStreamer instances, possibly on different nodes, when a
+// single getLogs filter would be too heavy. Each address is assigned to one
+// instance by rendezvous hashing, so that an instance joining or leaving
+// moves only the addresses it gains or loses.
+//
+// The streams of the instances are merged by block number: a block is
+// emitted once every instance has passed it, with the events of all
+// instances in log index order. A Rollback of any instance below the
+// emitted position, an instance disagreeing about a block hash, and Join or
+// Leave restart all instances from the emitted position. Before a restart,
+// the emitted blocks within the largest BatchOverlap of the instances are
+// checked against the chain, as LiveEventLog does, and a Rollback is sent
+// to the first of them that was reorganized away; a reorg of older blocks,
+// or of blocks without events, goes undetected.
+type FilterSharder struct {
+	// Filter holds the Addresses to split and the Topics of every
+	// instance; its other fields are ignored.
+	Filter ethereum.FilterQuery
+
+	mu        sync.Mutex
+	instances map[string]*ChainStreamer
+	changed   chan struct{} // closed when the instances change
+}
+
+// NewFilterSharder returns a FilterSharder for the addresses and topics of
+// filter. Add instances with Join before streaming.
+func NewFilterSharder(filter ethereum.FilterQuery) *FilterSharder {
+	return &FilterSharder{
+		Filter:    filter,
+		instances: make(map[string]*ChainStreamer),
+		changed:   make(chan struct{}),
+	}
+}
+
+// Join adds an instance, or replaces one of the same name. The instance's
+// own Filter is overridden. Running streams rebalance.
+func (fs *FilterSharder) Join(name string, cs *ChainStreamer) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.instances[name] = cs
+	close(fs.changed)
+	fs.changed = make(chan struct{})
+}
+
+// Leave removes an instance. Running streams rebalance.
+func (fs *FilterSharder) Leave(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, ok := fs.instances[name]; !ok {
+		return
+	}
+	delete(fs.instances, name)
+	close(fs.changed)
+	fs.changed = make(chan struct{})
+}
+
+// Assignment returns the addresses of each instance. Instances without
+// addresses are left out.
+func (fs *FilterSharder) Assignment() map[string][]common.Address {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.assign()
+}
+
+func (fs *FilterSharder) assign() map[string][]common.Address {
+	shares := make(map[string][]common.Address)
+	if len(fs.instances) == 0 {
+		return shares
+	}
+	for _, a := range fs.Filter.Addresses {
+		var best string
+		var bestWeight uint64
+		for name := range fs.instances {
+			if w := rendezvousWeight(name, a); best == "" || w > bestWeight || w == bestWeight && name < best {
+				best, bestWeight = name, w
+			}
+		}
+		shares[best] = append(shares[best], a)
+	}
+	return shares
+}
+
+func rendezvousWeight(name string, a common.Address) uint64 {
+	return binary.BigEndian.Uint64(crypto.Keccak256([]byte(name), a.Bytes()))
+}
+
+func (fs *FilterSharder) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	if len(fs.Filter.Addresses) == 0 {
+		return nil, fmt.Errorf("FilterSharder has no addresses; use a single ChainStreamer to watch all contracts")
+	}
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+	go func() {
+		err := fs.stream(c, done, from)
+		close(c)
+		errc <- err
+	}()
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+// shardState is the position of a FilterSharder stream.
+type shardState struct {
+	next    uint64
+	recent  []*Block // emitted blocks within overlap of next
+	overlap uint64
+}
+
+// emitted records an emitted block.
+func (st *shardState) emitted(b *Block) {
+	st.recent = append(st.recent, b)
+	st.next = b.Number + 1
+	st.trim()
+}
+
+// rollback moves the position back to n.
+func (st *shardState) rollback(n uint64) {
+	i := sort.Search(len(st.recent), func(i int) bool { return st.recent[i].Number >= n })
+	st.recent = st.recent[:i]
+	st.next = n
+}
+
+// trim drops the recent blocks beyond the overlap.
+func (st *shardState) trim() {
+	i := 0
+	for i < len(st.recent) && st.recent[i].Number+st.overlap < st.next {
+		i++
+	}
+	st.recent = st.recent[i:]
+}
+
+func (fs *FilterSharder) stream(c chan *Message, done chan struct{}, from uint64) error {
+	st := &shardState{next: from}
+	for restart := false; ; restart = true {
+		fs.mu.Lock()
+		shares := fs.assign()
+		instances := make(map[string]*ChainStreamer, len(shares))
+		for name := range shares {
+			instances[name] = fs.instances[name]
+		}
+		changed := fs.changed
+		fs.mu.Unlock()
+		if len(instances) == 0 {
+			return fmt.Errorf("FilterSharder has no instances")
+		}
+		for _, cs := range instances {
+			if bo := cs.EffectiveConfig().BatchOverlap; bo > st.overlap {
+				st.overlap = bo
+			}
+		}
+		if restart {
+			if err := fs.rewind(c, done, instances, st); err != nil {
+				return err
+			}
+		}
+		if err := fs.epoch(c, done, changed, instances, shares, st); err != nil {
+			return err
+		}
+	}
+}
+
+// rewind sends a Rollback to the first recently emitted block that is no
+// longer on the chain, if any, so that the instances restart before it.
+func (fs *FilterSharder) rewind(c chan *Message, done chan struct{}, instances map[string]*ChainStreamer, st *shardState) error {
+	st.trim()
+	if len(st.recent) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cs := instances[names[0]]
+	ctx := fs.context(instances)
+	client := cs.Client
+	if client == nil {
+		var err error
+		if client, err = Dial(ctx, cs.Url); err != nil {
+			return fmt.Errorf("instance %s: %w", names[0], err)
+		}
+		defer client.Close()
+	}
+	n := st.next
+	for i := len(st.recent) - 1; i >= 0; i-- {
+		b := st.recent[i]
+		h, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(b.Number))
+		if err != nil {
+			return fmt.Errorf("instance %s: %w", names[0], err)
+		}
+		if h.Hash() == b.Hash {
+			break
+		}
+		n = b.Number
+	}
+	if n == st.next {
+		return nil
+	}
+	logf(ctx, "block %d was reorganized, rolling back\n", n)
+	st.rollback(n)
+	return sendOrDone(c, done, &Message{Action: Rollback, Number: n})
+}
+
+// shardMessage is a message of instance i, or its end.
+type shardMessage struct {
+	i   int
+	m   *Message
+	err error
+}
+
+// epoch streams from st.next with one assignment of addresses, advancing
+// st. It returns nil when the instances have to restart.
+func (fs *FilterSharder) epoch(c chan *Message, done, changed chan struct{}, instances map[string]*ChainStreamer, shares map[string][]common.Address, st *shardState) error {
+	epochDone := make(chan struct{})
+	defer close(epochDone)
+
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	in := make(chan shardMessage)
+	for i, name := range names {
+		cs := *instances[name]
+		cs.Filter.Addresses = shares[name]
+		cs.Filter.Topics = fs.Filter.Topics
+		sub, err := cs.Stream(epochDone, st.next)
+		if err != nil {
+			return fmt.Errorf("instance %s: %w", name, err)
+		}
+		go func(i int, sub *Subscription) {
+			for m := range sub.C {
+				select {
+				case in <- shardMessage{i: i, m: m}:
+				case <-epochDone:
+					return
+				}
+			}
+			err := <-sub.Err
+			if err == nil {
+				err = fmt.Errorf("stream ended")
+			}
+			select {
+			case in <- shardMessage{i: i, err: err}:
+			case <-epochDone:
+			}
+		}(i, sub)
+	}
+
+	pos := make([]uint64, len(names))
+	pending := make([][]*Block, len(names))
+	for i := range pos {
+		pos[i] = st.next
+	}
+	for {
+		var sm shardMessage
+		select {
+		case <-done:
+			return Canceled
+		case <-changed:
+			logf(fs.context(instances), "instances changed, rebalancing at block %d\n", st.next)
+			return nil
+		case sm = <-in:
+		}
+		if sm.err != nil {
+			return fmt.Errorf("instance %s: %w", names[sm.i], sm.err)
+		}
+		switch m := sm.m; m.Action {
+		case Append:
+			pending[sm.i] = append(pending[sm.i], m.Block)
+			pos[sm.i] = m.Block.Number + 1
+		case SetNext:
+			pos[sm.i] = m.Number
+		case Rollback:
+			if m.Number < st.next {
+				st.rollback(m.Number)
+				return sendOrDone(c, done, &Message{Action: Rollback, Number: m.Number})
+			}
+			p := pending[sm.i]
+			for len(p) > 0 && p[len(p)-1].Number >= m.Number {
+				p = p[:len(p)-1]
+			}
+			pending[sm.i] = p
+			pos[sm.i] = m.Number
+		}
+		restart, err := fs.flush(c, done, pos, pending, st)
+		if err != nil || restart {
+			return err
+		}
+	}
+}
+
+// context returns the context of an instance, for logging.
+func (fs *FilterSharder) context(instances map[string]*ChainStreamer) context.Context {
+	for _, cs := range instances {
+		if cs.Ctx != nil {
+			return cs.Ctx
+		}
+	}
+	return context.Background()
+}
+
+// flush emits the blocks every instance has passed, merged, and the
+// position all instances reached. It returns true if the instances
+// disagree about a block, and have to restart from it.
+func (fs *FilterSharder) flush(c chan *Message, done chan struct{}, pos []uint64, pending [][]*Block, st *shardState) (bool, error) {
+	w := pos[0]
+	for _, p := range pos[1:] {
+		if p < w {
+			w = p
+		}
+	}
+	byNumber := make(map[uint64][]*Block)
+	for i, p := range pending {
+		j := 0
+		for j < len(p) && p[j].Number < w {
+			byNumber[p[j].Number] = append(byNumber[p[j].Number], p[j])
+			j++
+		}
+		pending[i] = p[j:]
+	}
+	numbers := make([]uint64, 0, len(byNumber))
+	for n := range byNumber {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for _, n := range numbers {
+		b, err := mergeBlocks(byNumber[n])
+		if err != nil {
+			logf(context.Background(), "warning: %v; restarting instances at block %d\n", err, n)
+			return true, nil
+		}
+		if err := sendOrDone(c, done, &Message{Action: Append, Block: b}); err != nil {
+			return false, err
+		}
+		st.emitted(b)
+	}
+	if w > st.next {
+		if err := sendOrDone(c, done, &Message{Action: SetNext, Number: w}); err != nil {
+			return false, err
+		}
+		st.next = w
+		st.trim()
+	}
+	return false, nil
+}
+
+// mergeBlocks merges the blocks of one number from several instances.
+func mergeBlocks(bs []*Block) (*Block, error) {
+	if len(bs) == 1 {
+		return bs[0], nil
+	}
+	b := *bs[0]
+	b.Events = nil
+	b.Meta = nil
+	for _, o := range bs {
+		if o.Hash != bs[0].Hash {
+			return nil, fmt.Errorf("instances disagree about block %d: got hashes %s and %s", b.Number, bs[0].Hash.Hex(), o.Hash.Hex())
+		}
+		b.Events = append(b.Events, o.Events...)
+		for k, v := range o.Meta {
+			if b.Meta == nil {
+				b.Meta = make(map[string]string)
+			}
+			b.Meta[k] = v
+		}
+	}
+	sort.Slice(b.Events, func(i, j int) bool { return b.Events[i].Index < b.Events[j].Index })
+	if b.EventsRoot != (common.Hash{}) {
+		b.SetEventsRoot()
+	}
+	return &b, nil
+}
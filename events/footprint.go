@@ -0,0 +1,63 @@
+package events
+
+import (
+	"math/big"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MemoryFootprint is an estimate of the memory held by an eventlog, in
+// bytes. It counts the capacity of slices, not allocator overhead. Slices
+// shared by an Interner are counted once.
+type MemoryFootprint struct {
+	Blocks int
+	Events int
+
+	Data     int64 // event data
+	TxData   int64 // transaction input data
+	Topics   int64
+	Overhead int64 // structs, slice headers, Meta and TxValue
+}
+
+// Total returns the estimated number of bytes.
+func (f MemoryFootprint) Total() int64 {
+	return f.Data + f.TxData + f.Topics + f.Overhead
+}
+
+// MemoryFootprint estimates the memory held by the blocks of the eventlog.
+func (l *InMemoryEventLog) MemoryFootprint() MemoryFootprint {
+	var f MemoryFootprint
+	f.Overhead += int64(unsafe.Sizeof(BlockSlice{}))
+	f.Overhead += int64(cap(l.blockSlice.Blocks)) * int64(unsafe.Sizeof(&Block{}))
+	shared := make(map[interface{}]bool)
+	for _, b := range l.blockSlice.Blocks {
+		b.addFootprint(&f, shared)
+	}
+	return f
+}
+
+func (b *Block) addFootprint(f *MemoryFootprint, shared map[interface{}]bool) {
+	f.Blocks++
+	f.Events += len(b.Events)
+	f.Overhead += int64(unsafe.Sizeof(*b))
+	f.Overhead += int64(cap(b.Events)) * int64(unsafe.Sizeof(Event{}))
+	for k, v := range b.Meta {
+		f.Overhead += int64(len(k) + len(v))
+	}
+	for i := range b.Events {
+		e := &b.Events[i]
+		f.Data += int64(cap(e.Data))
+		if cap(e.TxData) > 0 && !shared[&e.TxData[:1][0]] {
+			shared[&e.TxData[:1][0]] = true
+			f.TxData += int64(cap(e.TxData))
+		}
+		if cap(e.Topics) > 0 && !shared[&e.Topics[:1][0]] {
+			shared[&e.Topics[:1][0]] = true
+			f.Topics += int64(cap(e.Topics)) * common.HashLength
+		}
+		if e.TxValue != nil {
+			f.Overhead += int64(unsafe.Sizeof(big.Int{})) + int64(cap(e.TxValue.Bits()))*int64(unsafe.Sizeof(big.Word(0)))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (a Action) String() string {
+	switch a {
+	case Append:
+		return "Append"
+	case Rollback:
+		return "Rollback"
+	case SetNext:
+		return "SetNext"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+func (m *Message) String() string {
+	if m.Action == Append {
+		if m.Block == nil {
+			return "Append <nil>"
+		}
+		return fmt.Sprintf("Append block %d (%d events)", m.Block.Number, len(m.Block.Events))
+	}
+	return fmt.Sprintf("%s %d", m.Action, m.Number)
+}
+
+// FormatBlock renders a block and its events as text, one line per event.
+// With verbose set, transaction details and event data are included.
+func FormatBlock(b *Block, verbose bool) string {
+	return FormatBlockWith(b, verbose, nil)
+}
+
+// FormatBlockWith is like FormatBlock, but describes each event with the
+// given function instead of its first topic, e.g. to render decoded events.
+func FormatBlockWith(b *Block, verbose bool, describe func(*Event) string) string {
+	return (&BlockFormat{Verbose: verbose, Describe: describe}).Format(b)
+}
+
+// BlockFormat holds the options of FormatBlock.
+type BlockFormat struct {
+	Verbose   bool                // include transaction details and data
+	Addresses AddressFormat       // how to write addresses
+	Describe  func(*Event) string // if set, replaces the first topic
+}
+
+// Format renders a block and its events as text, one line per event.
+func (f *BlockFormat) Format(b *Block) string {
+	verbose, describe := f.Verbose, f.Describe
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Block %d %s\n", b.Number, b.Hash.Hex())
+	for i := range b.Events {
+		e := &b.Events[i]
+		var desc string
+		switch {
+		case describe != nil:
+			desc = describe(e)
+		case len(e.Topics) > 0:
+			desc = e.Topics[0].Hex()
+		default:
+			desc = "(anonymous)"
+		}
+		fmt.Fprintf(&sb, "  %d/%d %s %s\n", e.BlockNumber, e.Index, f.Addresses.Format(e.Address), desc)
+		if !verbose {
+			continue
+		}
+		fmt.Fprintf(&sb, "    tx %s (index %d)\n", e.TxHash.Hex(), e.TxIndex)
+		if e.TxMissing {
+			fmt.Fprintf(&sb, "    (transaction details not available)\n")
+		}
+		if e.TxValue != nil {
+			fmt.Fprintf(&sb, "    from %s value %s gas %d (%d bytes)\n", f.Addresses.Format(e.TxFrom), e.TxValue, e.TxGas, len(e.TxData))
+		}
+		if len(e.Data) > 0 {
+			fmt.Fprintf(&sb, "    data 0x%x\n", e.Data)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+//go:build gofuzz
+// +build gofuzz
+
+package events
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// Fuzz targets for go-fuzz, e.g.
+//
+//	go-fuzz-build -tags gofuzz -func FuzzBlockSliceOps
+//	go-fuzz -bin events-fuzz.zip -workdir fuzz/ops
+
+// FuzzEventFromProto checks that decoding an Event does not panic, and that
+// a decoded Event survives a round trip.
+func FuzzEventFromProto(data []byte) int {
+	pb := &epb.Event{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return -1
+	}
+	e, err := EventFromProto(pb)
+	if err != nil {
+		return 0
+	}
+	e2, err := EventFromProto(EventToProto(e))
+	if err != nil {
+		panic(err)
+	}
+	if !proto.Equal(EventToProto(e), EventToProto(e2)) {
+		panic("event changed in round trip")
+	}
+	return 1
+}
+
+// FuzzBlockSliceFromProto checks that decoding a BlockSlice does not panic,
+// and that a decoded BlockSlice is valid and survives a round trip.
+func FuzzBlockSliceFromProto(data []byte) int {
+	pb := &epb.BlockSlice{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return -1
+	}
+	bs, err := BlockSliceFromProto(pb)
+	if err != nil {
+		return 0
+	}
+	if err := bs.check(); err != nil {
+		panic(err)
+	}
+	bs2, err := BlockSliceFromProto(BlockSliceToProto(bs))
+	if err != nil {
+		panic(err)
+	}
+	if !proto.Equal(BlockSliceToProto(bs), BlockSliceToProto(bs2)) {
+		panic("block slice changed in round trip")
+	}
+	return 1
+}
+
+// FuzzBlockSliceOps applies a sequence of operations, two bytes each, to a
+// BlockSlice, and checks that it stays valid.
+func FuzzBlockSliceOps(data []byte) int {
+	b := EmptyBlockSlice(100)
+	for i := 0; i+1 < len(data); i += 2 {
+		n := b.End + uint64(data[i+1]) - 128
+		if uint64(data[i+1]) < 128 && b.End < 128-uint64(data[i+1]) {
+			n = 0
+		}
+		switch data[i] % 5 {
+		case 0:
+			b.Append(&Block{Number: n})
+		case 1:
+			b.Rollback(n)
+		case 2:
+			b.Extend(n)
+		case 3:
+			b.DeleteBeforeBlock(n)
+		case 4:
+			b.DeleteFromBlock(n)
+		}
+		if err := b.check(); err != nil {
+			panic(fmt.Sprintf("after op %d(%d): %v", data[i]%5, n, err))
+		}
+	}
+	return 1
+}
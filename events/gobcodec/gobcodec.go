@@ -0,0 +1,112 @@
+// Package gobcodec encodes eventlogs with encoding/gob, as an alternative
+// to the proto checkpoints of the events package for programs that do not
+// otherwise use protobuf. The package itself does not import protobuf;
+// Save and Load mirror events.SaveCheckpoint and events.LoadCheckpoint.
+package gobcodec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// magic starts the encoding of an eventlog, to tell it from proto.
+const magic = "eventlog/gob/1\n"
+
+type gobEventLog struct {
+	Filter ethereum.FilterQuery
+	Slice  gobSlice
+}
+
+type gobSlice struct {
+	Start, End uint64
+	Blocks     []*events.Block
+}
+
+// Marshal encodes an eventlog.
+func Marshal(l *events.InMemoryEventLog) ([]byte, error) {
+	bs := l.BlockSlice()
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	g := &gobEventLog{
+		Filter: l.Filter(),
+		Slice:  gobSlice{Start: bs.Start, End: bs.End, Blocks: bs.Blocks},
+	}
+	if err := gob.NewEncoder(&buf).Encode(g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes an eventlog encoded by Marshal.
+func Unmarshal(data []byte) (*events.InMemoryEventLog, error) {
+	if !IsGob(data) {
+		return nil, fmt.Errorf("not a gob encoded eventlog")
+	}
+	var g gobEventLog
+	if err := gob.NewDecoder(bytes.NewReader(data[len(magic):])).Decode(&g); err != nil {
+		return nil, err
+	}
+	l := events.NewInMemoryEventLog(g.Slice.Start, g.Filter)
+	for _, b := range g.Slice.Blocks {
+		if err := l.Append(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.SetNext(g.Slice.End); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// IsGob reports whether data is an eventlog encoded by Marshal.
+func IsGob(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Save writes an eventlog to a file, compressed with gzip if the path ends
+// in ".gz". An existing file is replaced atomically.
+func Save(l *events.InMemoryEventLog, path string) error {
+	bs, err := Marshal(l)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(bs); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		bs = buf.Bytes()
+	}
+	return events.WriteFileAtomic(path, bs)
+}
+
+// Load reads an eventlog written by Save, compressed or not.
+func Load(path string) (*events.InMemoryEventLog, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) >= 2 && bs[0] == 0x1f && bs[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(bs))
+		if err != nil {
+			return nil, err
+		}
+		if bs, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	return Unmarshal(bs)
+}
@@ -0,0 +1,19 @@
+package events
+
+import (
+	"context"
+)
+
+// AddHeaderData fetches the header of every block in a BlockSlice and sets
+// the block's ParentHash and Time from it.
+func AddHeaderData(ctx context.Context, client Client, bs *BlockSlice) error {
+	for _, b := range bs.Blocks {
+		h, err := client.HeaderByHash(ctx, b.Hash)
+		if err != nil {
+			return err
+		}
+		b.ParentHash = h.ParentHash
+		b.Time = h.Time
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package events
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EventIndex maps event addresses and first topics to the numbers of the
+// blocks containing them. It is a Sink, so it can be kept up to date with
+// the writes to an eventlog.
+type EventIndex struct {
+	byAddress map[common.Address][]uint64
+	byTopic   map[common.Hash][]uint64
+}
+
+func NewEventIndex() *EventIndex {
+	return &EventIndex{
+		byAddress: make(map[common.Address][]uint64),
+		byTopic:   make(map[common.Hash][]uint64),
+	}
+}
+
+func (ix *EventIndex) Append(b *Block) error {
+	for _, e := range b.Events {
+		ix.byAddress[e.Address] = addBlock(ix.byAddress[e.Address], b.Number)
+		if len(e.Topics) > 0 {
+			ix.byTopic[e.Topics[0]] = addBlock(ix.byTopic[e.Topics[0]], b.Number)
+		}
+	}
+	return nil
+}
+
+func (ix *EventIndex) Rollback(n uint64) error {
+	for a, ns := range ix.byAddress {
+		if ns = ns[:searchBlock(ns, n)]; len(ns) == 0 {
+			delete(ix.byAddress, a)
+		} else {
+			ix.byAddress[a] = ns
+		}
+	}
+	for t, ns := range ix.byTopic {
+		if ns = ns[:searchBlock(ns, n)]; len(ns) == 0 {
+			delete(ix.byTopic, t)
+		} else {
+			ix.byTopic[t] = ns
+		}
+	}
+	return nil
+}
+
+func (ix *EventIndex) SetNext(n uint64) error {
+	return nil
+}
+
+// Prune drops the entries for blocks before the given block number.
+func (ix *EventIndex) Prune(before uint64) {
+	for a, ns := range ix.byAddress {
+		if ns = ns[searchBlock(ns, before):]; len(ns) == 0 {
+			delete(ix.byAddress, a)
+		} else {
+			ix.byAddress[a] = ns
+		}
+	}
+	for t, ns := range ix.byTopic {
+		if ns = ns[searchBlock(ns, before):]; len(ns) == 0 {
+			delete(ix.byTopic, t)
+		} else {
+			ix.byTopic[t] = ns
+		}
+	}
+}
+
+// BlocksByAddress returns the numbers of the blocks in [from, to) with
+// events emitted by addr.
+func (ix *EventIndex) BlocksByAddress(addr common.Address, from, to uint64) []uint64 {
+	return blockRange(ix.byAddress[addr], from, to)
+}
+
+// BlocksByTopic returns the numbers of the blocks in [from, to) with events
+// whose first topic is topic0.
+func (ix *EventIndex) BlocksByTopic(topic0 common.Hash, from, to uint64) []uint64 {
+	return blockRange(ix.byTopic[topic0], from, to)
+}
+
+// Rebuild replaces the index with one of the events in l.
+func (ix *EventIndex) Rebuild(l EventLog) error {
+	ix.byAddress = make(map[common.Address][]uint64)
+	ix.byTopic = make(map[common.Hash][]uint64)
+	done := make(chan struct{})
+	defer close(done)
+	sub, err := l.Stream(done, l.FirstBlock())
+	if err != nil {
+		return err
+	}
+	return Drain(sub, ix)
+}
+
+func addBlock(ns []uint64, n uint64) []uint64 {
+	if len(ns) > 0 && ns[len(ns)-1] == n {
+		return ns
+	}
+	return append(ns, n)
+}
+
+func searchBlock(ns []uint64, n uint64) int {
+	return sort.Search(len(ns), func(i int) bool { return ns[i] >= n })
+}
+
+func blockRange(ns []uint64, from, to uint64) []uint64 {
+	ns = ns[searchBlock(ns, from):]
+	ns = ns[:searchBlock(ns, to)]
+	return append([]uint64(nil), ns...)
+}
+
+// EventQuerier looks up the events of an eventlog by address or first topic
+// without scanning it. SQLiteEventLog implements it with indexes stored in
+// the database; IndexedEventLog adds it to any eventlog.
+type EventQuerier interface {
+	EventsByAddress(addr common.Address, from, to uint64) ([]Event, error)
+	EventsByTopic(topic0 common.Hash, from, to uint64) ([]Event, error)
+}
+
+// IndexedEventLog is an eventlog maintaining an EventIndex of its events,
+// to look them up by address or topic without scanning the whole log. It
+// is an add-on for eventlogs without indexes of their own, like
+// InMemoryEventLog; a persistent eventlog should use SQLiteEventLog.
+//
+// The index is kept in memory only, and is not part of checkpoints: it is
+// built by NewIndexedEventLog with a scan of the whole eventlog, on every
+// start, and can be rebuilt with RebuildIndex. It holds a block number per
+// block and address or topic, so it grows with the eventlog.
+type IndexedEventLog struct {
+	EventLog
+	Index *EventIndex
+}
+
+// NewIndexedEventLog indexes the events of l.
+func NewIndexedEventLog(l EventLog) (*IndexedEventLog, error) {
+	il := &IndexedEventLog{EventLog: l, Index: NewEventIndex()}
+	if err := il.RebuildIndex(); err != nil {
+		return nil, err
+	}
+	return il, nil
+}
+
+// RebuildIndex rebuilds the index from the events in the eventlog, e.g.
+// after the eventlog was modified without going through il.
+func (il *IndexedEventLog) RebuildIndex() error {
+	return il.Index.Rebuild(il.EventLog)
+}
+
+func (il *IndexedEventLog) Append(b *Block) error {
+	if err := il.EventLog.Append(b); err != nil {
+		return err
+	}
+	return il.Index.Append(b)
+}
+
+func (il *IndexedEventLog) Rollback(n uint64) error {
+	if err := il.EventLog.Rollback(n); err != nil {
+		return err
+	}
+	return il.Index.Rollback(n)
+}
+
+// CanPrune reports whether the indexed eventlog supports Prune.
+func (il *IndexedEventLog) CanPrune() bool {
+	return CanPrune(il.EventLog)
+}
+
+func (il *IndexedEventLog) Prune(before uint64) error {
+	if !il.CanPrune() {
+		return ErrPruneUnsupported
+	}
+	if err := il.EventLog.Prune(before); err != nil {
+		return err
+	}
+	il.Index.Prune(before)
+	return nil
+}
+
+// EventsByAddress returns the events in blocks [from, to) emitted by addr.
+func (il *IndexedEventLog) EventsByAddress(addr common.Address, from, to uint64) ([]Event, error) {
+	return il.events(il.Index.BlocksByAddress(addr, from, to), func(e *Event) bool {
+		return e.Address == addr
+	})
+}
+
+// EventsByTopic returns the events in blocks [from, to) whose first topic
+// is topic0.
+func (il *IndexedEventLog) EventsByTopic(topic0 common.Hash, from, to uint64) ([]Event, error) {
+	return il.events(il.Index.BlocksByTopic(topic0, from, to), func(e *Event) bool {
+		return len(e.Topics) > 0 && e.Topics[0] == topic0
+	})
+}
+
+// events reads the given blocks, in increasing order, with one stream of
+// the eventlog, and returns their events matching keep.
+func (il *IndexedEventLog) events(blocks []uint64, keep func(*Event) bool) ([]Event, error) {
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	done := make(chan struct{})
+	sub, err := il.EventLog.Stream(done, blocks[0])
+	if err != nil {
+		return nil, err
+	}
+	var es []Event
+	for m := range sub.C {
+		if m.Action != Append {
+			continue
+		}
+		for len(blocks) > 0 && blocks[0] < m.Block.Number {
+			blocks = blocks[1:]
+		}
+		if len(blocks) == 0 {
+			break
+		}
+		if blocks[0] != m.Block.Number {
+			continue
+		}
+		for i := range m.Block.Events {
+			if keep(&m.Block.Events[i]) {
+				es = append(es, m.Block.Events[i])
+			}
+		}
+		if blocks = blocks[1:]; len(blocks) == 0 {
+			break
+		}
+	}
+	close(done)
+	for range sub.C {
+	}
+	if err := <-sub.Err; err != nil && err != Canceled {
+		return nil, err
+	}
+	return es, nil
+}
@@ -1,15 +1,20 @@
 package events
 
 import (
+	"sort"
+
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/crypto"
 
-	epb "github.com/jcjlcodes/eth-eventlog/proto/events"
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
 )
 
 // InMemoryEventLog is an in-memory implementation of the EventLog interface.
 type InMemoryEventLog struct {
 	filter     ethereum.FilterQuery
 	blockSlice *BlockSlice
+
+	txDataStripped uint64 // blocks before have no TxData
 }
 
 func NewInMemoryEventLog(from uint64, filter ethereum.FilterQuery) *InMemoryEventLog {
@@ -19,6 +24,20 @@ func NewInMemoryEventLog(from uint64, filter ethereum.FilterQuery) *InMemoryEven
 	}
 }
 
+// InMemoryEventLogFromBlockSlice creates an eventlog holding the blocks of a
+// BlockSlice.
+func InMemoryEventLogFromBlockSlice(bs *BlockSlice, filter ethereum.FilterQuery) *InMemoryEventLog {
+	return &InMemoryEventLog{
+		filter: filter,
+		blockSlice: &BlockSlice{
+			Start:            bs.Start,
+			End:              bs.End,
+			DistanceFromHead: bs.DistanceFromHead,
+			Blocks:           bs.Blocks,
+		},
+	}
+}
+
 func (l *InMemoryEventLog) FirstBlock() uint64 {
 	return l.blockSlice.Start
 }
@@ -42,6 +61,9 @@ func (l *InMemoryEventLog) Rollback(n uint64) error {
 	if err := l.blockSlice.Rollback(n); err != nil {
 		return err
 	}
+	if n < l.txDataStripped {
+		l.txDataStripped = n
+	}
 	return nil
 }
 
@@ -52,6 +74,88 @@ func (l *InMemoryEventLog) SetNext(n uint64) error {
 	return nil
 }
 
+// BlockSlice returns a copy of the BlockSlice holding the blocks of the
+// eventlog. The blocks themselves are shared.
+func (l *InMemoryEventLog) BlockSlice() *BlockSlice {
+	bs := *l.blockSlice
+	return &bs
+}
+
+// SetStrict turns strict checking of appended blocks on or off; see
+// BlockSlice.Append.
+func (l *InMemoryEventLog) SetStrict(strict bool) {
+	l.blockSlice.Strict = strict
+}
+
+// Reset drops all blocks and restarts the eventlog at from.
+func (l *InMemoryEventLog) Reset(from uint64) {
+	l.blockSlice.Reset(from)
+	l.txDataStripped = 0
+}
+
+// Prune drops the blocks before a block number, e.g. to apply a retention
+// policy. Pruning beyond NextBlock empties the eventlog.
+func (l *InMemoryEventLog) Prune(before uint64) error {
+	if before <= l.blockSlice.Start {
+		return nil
+	}
+	if before > l.blockSlice.End {
+		before = l.blockSlice.End
+	}
+	l.blockSlice.DeleteBeforeBlock(before)
+	return nil
+}
+
+// StripTxData drops the transaction input data of the events in the blocks
+// before a block number, to save memory. If keepHash is set, the keccak256
+// of the data is kept in TxDataHash. Stripped blocks are copied, so blocks
+// already handed out by Stream are not modified.
+func (l *InMemoryEventLog) StripTxData(before uint64, keepHash bool) {
+	blocks := l.blockSlice.Blocks
+	start := sort.Search(len(blocks), func(i int) bool { return blocks[i].Number >= l.txDataStripped })
+	for i := start; i < len(blocks) && blocks[i].Number < before; i++ {
+		if b := stripTxData(blocks[i], keepHash); b != nil {
+			blocks[i] = b
+		}
+	}
+	if before > l.txDataStripped {
+		l.txDataStripped = before
+	}
+}
+
+// stripTxData returns a copy of b without TxData, or nil if it has none.
+func stripTxData(b *Block, keepHash bool) *Block {
+	var events []Event
+	for i := range b.Events {
+		if b.Events[i].TxData == nil {
+			continue
+		}
+		if events == nil {
+			events = append([]Event(nil), b.Events...)
+		}
+		e := &events[i]
+		if keepHash {
+			e.TxDataHash = crypto.Keccak256Hash(e.TxData)
+		}
+		e.TxData = nil
+	}
+	if events == nil {
+		return nil
+	}
+	stripped := *b
+	stripped.Events = events
+	return &stripped
+}
+
+// Intern deduplicates the TxData and Topics of the stored events, e.g. after
+// loading a checkpoint. The blocks are modified in place, so it must not be
+// called while they are being streamed.
+func (l *InMemoryEventLog) Intern(in *Interner) {
+	for _, b := range l.blockSlice.Blocks {
+		in.Block(b)
+	}
+}
+
 func (l *InMemoryEventLog) Close() error {
 	return nil
 }
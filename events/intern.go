@@ -0,0 +1,92 @@
+package events
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultInternMaxEntries is the default size of an Interner table.
+const DefaultInternMaxEntries = 1 << 16
+
+// Interner deduplicates the TxData and Topics of events, so that equal
+// values share memory. Events of the same transaction carry the same TxData,
+// and events of popular contracts often repeat their topics.
+//
+// Interned slices are shared, so they must not be modified. The table is
+// dropped when it reaches MaxEntries, so an Interner does not keep pruned
+// data alive for long. An Interner is not safe for concurrent use.
+type Interner struct {
+	MaxEntries int
+
+	txData map[string][]byte
+	topics map[string][]common.Hash
+	key    []byte
+}
+
+func NewInterner() *Interner {
+	return &Interner{MaxEntries: DefaultInternMaxEntries}
+}
+
+// Len returns the number of values in the table.
+func (in *Interner) Len() int {
+	return len(in.txData) + len(in.topics)
+}
+
+// Reset drops the table.
+func (in *Interner) Reset() {
+	in.txData = nil
+	in.topics = nil
+}
+
+func (in *Interner) makeRoom() {
+	max := in.MaxEntries
+	if max <= 0 {
+		max = DefaultInternMaxEntries
+	}
+	if in.Len() >= max {
+		in.Reset()
+	}
+	if in.txData == nil {
+		in.txData = make(map[string][]byte)
+		in.topics = make(map[string][]common.Hash)
+	}
+}
+
+// TxData returns a slice equal to data, shared with earlier equal slices.
+func (in *Interner) TxData(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	if shared, ok := in.txData[string(data)]; ok {
+		return shared
+	}
+	in.makeRoom()
+	in.txData[string(data)] = data
+	return data
+}
+
+// Topics returns a slice equal to topics, shared with earlier equal slices.
+func (in *Interner) Topics(topics []common.Hash) []common.Hash {
+	if len(topics) == 0 {
+		return topics
+	}
+	in.key = in.key[:0]
+	for _, t := range topics {
+		in.key = append(in.key, t[:]...)
+	}
+	if shared, ok := in.topics[string(in.key)]; ok {
+		return shared
+	}
+	in.makeRoom()
+	in.topics[string(in.key)] = topics
+	return topics
+}
+
+// Block interns the TxData and Topics of the events of b, in place. It must
+// be called before b is shared, e.g. sent to a subscriber.
+func (in *Interner) Block(b *Block) {
+	for i := range b.Events {
+		e := &b.Events[i]
+		e.TxData = in.TxData(e.TxData)
+		e.Topics = in.Topics(e.Topics)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build go1.23
+// +build go1.23
+
+package events
+
+import (
+	"fmt"
+	"iter"
+)
+
+// All returns an iterator over the stored blocks from block from on. Unlike
+// Stream, it runs in the caller's goroutine and needs no done channel; the
+// blocks are those stored when the iteration starts.
+func (l *InMemoryEventLog) All(from uint64) iter.Seq2[*Block, error] {
+	return func(yield func(*Block, error) bool) {
+		b := *l.blockSlice
+		b.DeleteBeforeBlock(from)
+		for _, blk := range b.Blocks {
+			if !yield(blk, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Messages returns an iterator over the messages a Streamer emits from block
+// from on. The stream is stopped when the loop ends early. An error of the
+// stream is yielded last, with a nil message.
+func Messages(s Streamer, from uint64) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		done := make(chan struct{})
+		sub, err := s.Stream(done, from)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for m := range sub.C {
+			if !yield(m, nil) {
+				close(done)
+				for range sub.C {
+				}
+				<-sub.Err
+				return
+			}
+		}
+		close(done)
+		if err := <-sub.Err; err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Blocks returns an iterator over the blocks of an EventLog from block from
+// on, e.g. for eventlogs without an All method. A Rollback in the stream is
+// yielded as an error.
+func Blocks(l EventLog, from uint64) iter.Seq2[*Block, error] {
+	return func(yield func(*Block, error) bool) {
+		for m, err := range Messages(l, from) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			switch m.Action {
+			case Append:
+				if !yield(m.Block, nil) {
+					return
+				}
+			case Rollback:
+				yield(nil, fmt.Errorf("got Rollback to %d from a stored eventlog", m.Number))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// BytesEncoding is how JSON output encodes byte strings.
+type BytesEncoding int
+
+const (
+	// HexBytes is 0x-prefixed hex, the convention of web3 tooling.
+	HexBytes BytesEncoding = iota
+	// Base64Bytes is standard base64, the convention of protobuf JSON.
+	Base64Bytes
+)
+
+// ParseBytesEncoding parses "hex" or "base64".
+func ParseBytesEncoding(s string) (BytesEncoding, error) {
+	switch s {
+	case "hex":
+		return HexBytes, nil
+	case "base64":
+		return Base64Bytes, nil
+	}
+	return 0, fmt.Errorf("unknown bytes encoding %q; want hex or base64", s)
+}
+
+func (enc BytesEncoding) String() string {
+	if enc == Base64Bytes {
+		return "base64"
+	}
+	return "hex"
+}
+
+// Encode encodes a byte string.
+func (enc BytesEncoding) Encode(bs []byte) string {
+	if enc == Base64Bytes {
+		return base64.StdEncoding.EncodeToString(bs)
+	}
+	return hexutil.Encode(bs)
+}
+
+// MarshalProtoJSON encodes a proto message, e.g. MessageToProto of a
+// message, as one line of JSON with the field names of the .proto file.
+// With Base64Bytes it is protojson, which protobuf JSON consumers read
+// back. With HexBytes, byte fields are hex, as web3 tooling expects. Either
+// way 64-bit integers are decimal strings, which JSON numbers cannot hold
+// exactly, and amounts beyond 64 bits, like tx_value, are hex strings.
+func MarshalProtoJSON(m proto.Message, enc BytesEncoding) ([]byte, error) {
+	if enc == Base64Bytes {
+		return protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	}
+	var buf bytes.Buffer
+	if err := writeHexJSON(&buf, m.ProtoReflect()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeHexJSON writes the populated fields of m in field number order.
+func writeHexJSON(buf *bytes.Buffer, m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	buf.WriteByte('{')
+	first := true
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJSONString(buf, string(fd.Name()))
+		buf.WriteByte(':')
+		v := m.Get(fd)
+		var err error
+		switch {
+		case fd.IsList():
+			l := v.List()
+			buf.WriteByte('[')
+			for j := 0; j < l.Len(); j++ {
+				if j > 0 {
+					buf.WriteByte(',')
+				}
+				if err = writeHexJSONValue(buf, fd, l.Get(j)); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		case fd.IsMap():
+			err = writeHexJSONMap(buf, fd, v.Map())
+		default:
+			err = writeHexJSONValue(buf, fd, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeHexJSONMap(buf *bytes.Buffer, fd protoreflect.FieldDescriptor, mv protoreflect.Map) error {
+	keys := make([]protoreflect.MapKey, 0, mv.Len())
+	mv.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, k.String())
+		buf.WriteByte(':')
+		if err := writeHexJSONValue(buf, fd.MapValue(), mv.Get(k)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeHexJSONValue(buf *bytes.Buffer, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return writeHexJSON(buf, v.Message())
+	case protoreflect.BytesKind:
+		writeJSONString(buf, HexBytes.Encode(v.Bytes()))
+		return nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// As in protojson: JSON numbers lose precision beyond 2^53.
+		writeJSONString(buf, v.String())
+		return nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			writeJSONString(buf, string(ev.Name()))
+		} else {
+			fmt.Fprintf(buf, "%d", v.Enum())
+		}
+		return nil
+	}
+	bs, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(bs)
+	return nil
+}
+
+// writeJSONString writes s as a JSON string.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	bs, _ := json.Marshal(s) // never fails for a string
+	buf.Write(bs)
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitter returns d plus a random duration in [0, j).
+func jitter(rnd *rand.Rand, d, j time.Duration) time.Duration {
+	if j > 0 {
+		d += time.Duration(rnd.Int63n(int64(j)))
+	}
+	return d
+}
+
+// DelayStreamer wraps a Streamer to delay every message by Delay plus a
+// random Jitter, simulating a slow node or network.
+type DelayStreamer struct {
+	Streamer Streamer
+	Delay    time.Duration
+	Jitter   time.Duration
+	Rand     *rand.Rand // seeded from the clock if nil
+}
+
+func (ds *DelayStreamer) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	sub, err := ds.Streamer.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+	rnd := ds.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := func() error {
+			for m := range sub.C {
+				if err := waitOrDone(done, jitter(rnd, ds.Delay, ds.Jitter)); err != nil {
+					return err
+				}
+				if err := sendOrDone(c, done, m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		close(c)
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- <-sub.Err
+	}()
+
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+// SlowSink wraps a Sink to sleep for Delay plus a random Jitter before every
+// call, simulating a slow consumer, e.g. with Drain.
+type SlowSink struct {
+	Sink   Sink
+	Delay  time.Duration
+	Jitter time.Duration
+	Rand   *rand.Rand // seeded from the clock if nil
+
+	once sync.Once
+}
+
+func (s *SlowSink) sleep() {
+	s.once.Do(func() {
+		if s.Rand == nil {
+			s.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+	})
+	time.Sleep(jitter(s.Rand, s.Delay, s.Jitter))
+}
+
+func (s *SlowSink) Append(b *Block) error {
+	s.sleep()
+	return s.Sink.Append(b)
+}
+
+func (s *SlowSink) Rollback(n uint64) error {
+	s.sleep()
+	return s.Sink.Rollback(n)
+}
+
+func (s *SlowSink) SetNext(n uint64) error {
+	s.sleep()
+	return s.Sink.SetNext(n)
+}
@@ -1,14 +1,40 @@
 package events
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"time"
 )
 
 // LiveEventLog combines an EventLog and a ChainStreamer to make a new Streamer
 // that streams first from the EventLog, and then from the ChainStreamer. When
 // streaming from the ChainStreamer the messages are both sent to the EventLog
 // and the subscriber.
+//
+// The chain stream starts at the end of the EventLog, and checks the stored
+// blocks of the last batch overlap against the chain, so that a reorg while
+// it was not running is a Rollback. If the chain stream fails, LiveEventLog
+// restarts it the same way up to MaxRestarts times in a row, waiting
+// RestartDelay in between; the count starts over once a stream made
+// progress.
 type LiveEventLog struct {
+	MaxRestarts  int
+	RestartDelay time.Duration
+
+	// MarkReplayComplete makes Stream send a SetNext message with
+	// ReplayComplete set after the stored blocks, before the chain blocks,
+	// e.g. to enable alerts only for live events. The message does not
+	// change the next block. OnReplayComplete, if set, is called at the
+	// same point with the first block streamed from chain.
+	MarkReplayComplete bool
+	OnReplayComplete   func(next uint64)
+
+	// OnAhead is what Stream does if the EventLog ends more than one block
+	// beyond the chain head: fail with an AheadOfChainError, wait for the
+	// chain, or roll the EventLog and the subscriber back to the head.
+	OnAhead AheadPolicy
+
 	eventlog EventLog
 	streamer ChainStreamer
 }
@@ -70,35 +96,197 @@ func (l *LiveEventLog) stream(c chan *Message, done chan struct{}, from uint64)
 		return err
 	}
 
-	// 2. Start streaming from chain.
+	if l.MarkReplayComplete {
+		m := &Message{Action: SetNext, Number: nextBlock, ReplayComplete: true}
+		if err := sendOrDone(c, done, m); err != nil {
+			return err
+		}
+	}
+	if l.OnReplayComplete != nil {
+		l.OnReplayComplete(nextBlock)
+	}
+
+	// 2. Start streaming from chain, restarting after errors.
 
 	l.streamer.Filter = l.eventlog.Filter()
-	chSub, err := l.streamer.Stream(done, nextBlock)
-	if err != nil {
+	if l.OnAhead == AheadWait {
+		l.streamer.WaitForFrom = true
+	}
+	if err := l.streamer.Validate(); err != nil {
 		return err
 	}
-	for m := range chSub.C {
-		switch m.Action {
-		case Append:
-			if err := l.eventlog.Append(m.Block); err != nil {
-				return err
-			}
-		case Rollback:
-			if err := l.eventlog.Rollback(m.Number); err != nil {
-				return err
-			}
-		case SetNext:
-			if err := l.eventlog.SetNext(m.Number); err != nil {
+	for restarts := 0; ; restarts++ {
+		start := nextBlock
+		chainErr, err := l.streamChain(c, done, &nextBlock)
+		if nextBlock > start {
+			restarts = 0
+		}
+		var ae *AheadOfChainError
+		if l.OnAhead == AheadRollback && errors.As(err, &ae) {
+			if err := l.rollbackToHead(c, done, ae, &nextBlock); err != nil {
 				return err
 			}
+			restarts--
+			continue
 		}
-		if err := sendOrDone(c, done, m); err != nil {
+		var ee *EarliestBlockError
+		if !chainErr || err == nil || err == Canceled || errors.As(err, &ee) || restarts >= l.MaxRestarts {
+			return err
+		}
+		logf(l.streamer.Ctx, "warning: chain stream failed, restarting at block %d: %v\n", nextBlock, err)
+		if err := waitOrDone(done, l.RestartDelay); err != nil {
 			return err
 		}
 	}
-	if err := <-chSub.Err; err != nil {
+}
+
+// rollbackToHead rolls the eventlog and the subscriber back to the block
+// after the chain head, or further back to after the last stored block the
+// chain has with the same hash.
+func (l *LiveEventLog) rollbackToHead(c chan *Message, done chan struct{}, ae *AheadOfChainError, nextBlock *uint64) error {
+	n := ae.Head + 1
+	if n < l.eventlog.FirstBlock() {
+		return fmt.Errorf("eventlog starts at block %d, beyond the chain head %d; not rolling back: %w", l.eventlog.FirstBlock(), ae.Head, ae)
+	}
+	n, err := l.commonEnd(n)
+	if err != nil {
 		return err
 	}
-
+	logf(l.streamer.Ctx, "warning: eventlog ends at block %d, beyond the chain head %d; rolling back to %d\n", ae.From, ae.Head, n)
+	m := &Message{Action: Rollback, Number: n}
+	if err := Apply(l.eventlog, m); err != nil {
+		return err
+	}
+	if err := sendOrDone(c, done, m); err != nil {
+		return err
+	}
+	*nextBlock = n
 	return nil
 }
+
+// commonEnd returns the block after the last stored block before n that the
+// chain has with the same hash, or after n-1 if there are no stored blocks
+// in between. It walks back one batch overlap at a time, down to the
+// FirstBlock of the eventlog.
+func (l *LiveEventLog) commonEnd(n uint64) (uint64, error) {
+	client := l.streamer.Client
+	if client == nil {
+		var err error
+		if client, err = Dial(l.streamer.Ctx, l.streamer.Url); err != nil {
+			return 0, err
+		}
+		defer client.Close()
+	}
+	first := l.eventlog.FirstBlock()
+	bo := l.streamer.EffectiveConfig().BatchOverlap
+	end := n
+	for end > first {
+		start := first
+		if end > first+bo {
+			start = end - bo
+		}
+		blocks, err := l.storedBlocks(start, end)
+		if err != nil {
+			return 0, err
+		}
+		for i := len(blocks) - 1; i >= 0; i-- {
+			h, err := client.HeaderByNumber(l.streamer.Ctx, new(big.Int).SetUint64(blocks[i].Number))
+			if err != nil {
+				return 0, err
+			}
+			if h.Hash() == blocks[i].Hash {
+				return n, nil
+			}
+			n = blocks[i].Number
+		}
+		end = start
+	}
+	return n, nil
+}
+
+// storedBlocks returns the blocks of the eventlog in from..to-1.
+func (l *LiveEventLog) storedBlocks(from, to uint64) ([]*Block, error) {
+	done := make(chan struct{})
+	sub, err := l.eventlog.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*Block
+	for m := range sub.C {
+		if m.Action != Append || m.Block.Number >= to {
+			break
+		}
+		blocks = append(blocks, m.Block)
+	}
+	close(done)
+	for range sub.C {
+	}
+	if err := <-sub.Err; err != nil && err != Canceled {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// streamChain streams from chain at *nextBlock, advancing it past the
+// messages sent. chainErr reports whether err came from the ChainStreamer
+// rather than the EventLog or subscriber.
+func (l *LiveEventLog) streamChain(c chan *Message, done chan struct{}, nextBlock *uint64) (chainErr bool, err error) {
+	history, err := l.tail(done, *nextBlock)
+	if err != nil {
+		return false, err
+	}
+	chSub, err := l.streamer.stream(done, history)
+	if err != nil {
+		return true, err
+	}
+	for m := range chSub.C {
+		if err := Apply(l.eventlog, m); err != nil {
+			return false, err
+		}
+		if err := sendOrDone(c, done, m); err != nil {
+			return false, err
+		}
+		switch m.Action {
+		case Append:
+			*nextBlock = m.Block.Number + 1
+		case Rollback, SetNext:
+			*nextBlock = m.Number
+		}
+	}
+	return true, <-chSub.Err
+}
+
+// tail returns the stored blocks of the batch overlap before next, which
+// is the end of the EventLog.
+func (l *LiveEventLog) tail(done chan struct{}, next uint64) (*BlockSlice, error) {
+	start := l.eventlog.FirstBlock()
+	if bo := l.streamer.EffectiveConfig().BatchOverlap; next > start+bo {
+		start = next - bo
+	}
+	b := EmptyBlockSlice(start)
+	sub, err := l.eventlog.Stream(done, start)
+	if err != nil {
+		return nil, err
+	}
+	for m := range sub.C {
+		if err != nil {
+			continue
+		}
+		switch m.Action {
+		case Append:
+			err = b.Append(m.Block)
+		case SetNext:
+			err = b.Extend(m.Number)
+		}
+	}
+	if serr := <-sub.Err; err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.End != next {
+		return nil, fmt.Errorf("eventlog ends at block %d; want %d", b.End, next)
+	}
+	return b, nil
+}
@@ -0,0 +1,202 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// BlockRange is the blocks Start..End-1.
+type BlockRange struct {
+	Start, End uint64
+}
+
+func (r BlockRange) String() string {
+	return fmt.Sprintf("%d:%d", r.Start, r.End)
+}
+
+// RangeManifest describes which blocks of Start..End-1 one or more eventlog
+// files cover for a filter, so that partial logs can be reasoned about and
+// combined. The blocks not in Ranges are gaps.
+type RangeManifest struct {
+	Filter     ethereum.FilterQuery
+	Start, End uint64
+	Ranges     []BlockRange // sorted, not overlapping or adjacent
+	Files      []string     // the files described, if known
+}
+
+// ManifestOf returns the manifest of an eventlog stored in file, which
+// covers its blocks without gaps.
+func ManifestOf(l *InMemoryEventLog, file string) *RangeManifest {
+	m := &RangeManifest{Filter: l.Filter()}
+	if file != "" {
+		m.Files = []string{file}
+	}
+	m.Add(BlockRange{Start: l.FirstBlock(), End: l.NextBlock()})
+	return m
+}
+
+// Add adds a covered range, extending Start..End if needed.
+func (m *RangeManifest) Add(r BlockRange) {
+	if r.Start >= r.End {
+		return
+	}
+	if len(m.Ranges) == 0 && m.Start == m.End {
+		m.Start, m.End = r.Start, r.End
+	}
+	if r.Start < m.Start {
+		m.Start = r.Start
+	}
+	if r.End > m.End {
+		m.End = r.End
+	}
+	ranges := append(m.Ranges, r)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	m.Ranges = merged
+}
+
+// Gaps returns the ranges of Start..End-1 not covered.
+func (m *RangeManifest) Gaps() []BlockRange {
+	return gapsBetween(m.Ranges, m.Start, m.End)
+}
+
+// Covers reports whether the blocks from..to-1 are covered.
+func (m *RangeManifest) Covers(from, to uint64) bool {
+	return len(gapsBetween(m.Ranges, from, to)) == 0
+}
+
+// gapsBetween returns the parts of from..to-1 not in the sorted ranges.
+func gapsBetween(ranges []BlockRange, from, to uint64) []BlockRange {
+	var gaps []BlockRange
+	pos := from
+	for _, r := range ranges {
+		if pos >= to {
+			break
+		}
+		if r.End <= pos {
+			continue
+		}
+		if r.Start > pos {
+			end := r.Start
+			if end > to {
+				end = to
+			}
+			gaps = append(gaps, BlockRange{Start: pos, End: end})
+		}
+		pos = r.End
+	}
+	if pos < to {
+		gaps = append(gaps, BlockRange{Start: pos, End: to})
+	}
+	return gaps
+}
+
+func sameFilter(a, b ethereum.FilterQuery) bool {
+	return proto.Equal(FilterQueryToProto(&a), FilterQueryToProto(&b))
+}
+
+// MergeManifests returns the coverage of several manifests of the same
+// filter together.
+func MergeManifests(ms ...*RangeManifest) (*RangeManifest, error) {
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("got 0 manifests; want at least 1")
+	}
+	merged := &RangeManifest{Filter: ms[0].Filter}
+	for _, m := range ms {
+		if !sameFilter(m.Filter, merged.Filter) {
+			return nil, fmt.Errorf("manifest %d:%d of %v has a different filter", m.Start, m.End, m.Files)
+		}
+		for _, r := range m.Ranges {
+			merged.Add(r)
+		}
+		merged.Files = append(merged.Files, m.Files...)
+	}
+	return merged, nil
+}
+
+// AssembleCoverage picks manifests to cover the blocks from..to-1, greedily
+// preferring the ones reaching furthest, e.g. to choose the files to merge.
+// It returns the picked manifests in block order, and the gaps none of them
+// covers. The manifests are assumed to have the same filter.
+func AssembleCoverage(ms []*RangeManifest, from, to uint64) ([]*RangeManifest, []BlockRange) {
+	var picked []*RangeManifest
+	var gaps []BlockRange
+	seen := make(map[*RangeManifest]bool)
+	pos := from
+	for pos < to {
+		var best *RangeManifest
+		bestEnd := pos
+		nextStart := to
+		for _, m := range ms {
+			for _, r := range m.Ranges {
+				if r.Start <= pos && r.End > bestEnd {
+					best, bestEnd = m, r.End
+				}
+				if r.Start > pos && r.Start < nextStart {
+					nextStart = r.Start
+				}
+			}
+		}
+		if best == nil {
+			gaps = append(gaps, BlockRange{Start: pos, End: nextStart})
+			pos = nextStart
+			continue
+		}
+		if !seen[best] {
+			seen[best] = true
+			picked = append(picked, best)
+		}
+		pos = bestEnd
+	}
+	return picked, gaps
+}
+
+func RangeManifestToProto(m *RangeManifest) *epb.RangeManifest {
+	pb := &epb.RangeManifest{
+		Filter: FilterQueryToProto(&m.Filter),
+		Start:  m.Start,
+		End:    m.End,
+		Files:  m.Files,
+	}
+	for _, r := range m.Ranges {
+		pb.Ranges = append(pb.Ranges, &epb.BlockRange{Start: r.Start, End: r.End})
+	}
+	return pb
+}
+
+func RangeManifestFromProto(pb *epb.RangeManifest) (*RangeManifest, error) {
+	filter, err := FilterQueryFromProto(pb.Filter)
+	if err != nil {
+		return nil, err
+	}
+	m := &RangeManifest{
+		Filter: filter,
+		Start:  pb.Start,
+		End:    pb.End,
+		Files:  pb.Files,
+	}
+	prev := pb.Start
+	for i, r := range pb.Ranges {
+		if r.Start >= r.End || r.Start < prev || i > 0 && r.Start == prev || r.End > pb.End {
+			return nil, fmt.Errorf("manifest %d:%d: range %d:%d is empty, out of order or outside", pb.Start, pb.End, r.Start, r.End)
+		}
+		m.Ranges = append(m.Ranges, BlockRange{Start: r.Start, End: r.End})
+		prev = r.End
+	}
+	return m, nil
+}
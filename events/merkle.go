@@ -0,0 +1,124 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// eventLeaf holds the fields of an event committed to by EventsRoot. The
+// transaction details are left out, as they are only present if fetched.
+type eventLeaf struct {
+	Address common.Address
+	Topics  []common.Hash
+	Data    []byte
+	Index   uint64
+	TxHash  common.Hash
+}
+
+// EventHash returns the keccak256 of the RLP encoding of the address,
+// topics, data, log index and transaction hash of an event.
+func EventHash(e *Event) common.Hash {
+	bs, err := rlp.EncodeToBytes(&eventLeaf{
+		Address: e.Address,
+		Topics:  e.Topics,
+		Data:    e.Data,
+		Index:   e.Index,
+		TxHash:  e.TxHash,
+	})
+	if err != nil {
+		panic(err) // the leaf has no types RLP can't encode
+	}
+	return crypto.Keccak256Hash(bs)
+}
+
+// MerkleRoot returns the root of a binary merkle tree over the given leaves,
+// hashing pairs with keccak256. An odd node at the end of a level moves up
+// unchanged. The root of no leaves is the zero hash.
+func MerkleRoot(leaves []common.Hash) common.Hash {
+	if len(leaves) == 0 {
+		return common.Hash{}
+	}
+	level := append([]common.Hash(nil), leaves...)
+	for len(level) > 1 {
+		next := level[:0]
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				break
+			}
+			next = append(next, crypto.Keccak256Hash(level[i][:], level[i+1][:]))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// ComputeEventsRoot returns the merkle root of the hashes of the events of
+// the block, in order. Two indexers with the same events for a block get the
+// same root.
+func (b *Block) ComputeEventsRoot() common.Hash {
+	leaves := make([]common.Hash, len(b.Events))
+	for i := range b.Events {
+		leaves[i] = EventHash(&b.Events[i])
+	}
+	return MerkleRoot(leaves)
+}
+
+// SetEventsRoot sets EventsRoot to the merkle root of the events.
+func (b *Block) SetEventsRoot() {
+	b.EventsRoot = b.ComputeEventsRoot()
+}
+
+// WithEvents returns a copy of the block with a subset of its events. The
+// copy drops the Meta, which may refer to the other events, and has its
+// EventsRoot, if set, recomputed.
+func (b *Block) WithEvents(es []Event) *Block {
+	out := *b
+	out.Events = es
+	out.Meta = nil
+	if out.EventsRoot != (common.Hash{}) {
+		out.SetEventsRoot()
+	}
+	return &out
+}
+
+// VerifyEventsRoot checks that EventsRoot, if set, matches the events.
+func (b *Block) VerifyEventsRoot() error {
+	if b.EventsRoot == (common.Hash{}) {
+		return nil
+	}
+	if root := b.ComputeEventsRoot(); root != b.EventsRoot {
+		return fmt.Errorf("block %d: got events root %s; want %s", b.Number, root.Hex(), b.EventsRoot.Hex())
+	}
+	return nil
+}
+
+// AddEventsRoots sets the EventsRoot of every block in a BlockSlice.
+func AddEventsRoots(bs *BlockSlice) {
+	for _, b := range bs.Blocks {
+		b.SetEventsRoot()
+	}
+}
+
+// EventsRoot returns a merkle root over the number, hash and events root of
+// the blocks of the BlockSlice, so that two eventlogs can be compared by
+// exchanging a single hash. Blocks without EventsRoot set are hashed with
+// their computed one.
+func (bs *BlockSlice) EventsRoot() common.Hash {
+	leaves := make([]common.Hash, len(bs.Blocks))
+	var num [8]byte
+	for i, b := range bs.Blocks {
+		root := b.EventsRoot
+		if root == (common.Hash{}) {
+			root = b.ComputeEventsRoot()
+		}
+		for j := range num {
+			num[j] = byte(b.Number >> (56 - 8*j))
+		}
+		leaves[i] = crypto.Keccak256Hash(num[:], b.Hash[:], root[:])
+	}
+	return MerkleRoot(leaves)
+}
@@ -0,0 +1,7 @@
+//go:build !eventsdebug
+// +build !eventsdebug
+
+package events
+
+// debugCheck does nothing without the eventsdebug build tag.
+func (b *BlockSlice) debugCheck(op string) {}
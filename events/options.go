@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// Option configures the ChainStreamer of NewChainStreamer or
+// NewLiveEventLogWith. Options check their arguments, so a misconfigured
+// streamer fails at construction rather than when it streams.
+type Option func(*ChainStreamer) error
+
+// NewChainStreamer returns a ChainStreamer for the node at url, configured
+// by the options and checked with Validate. The url may be empty if
+// WithClient is given.
+func NewChainStreamer(url string, opts ...Option) (*ChainStreamer, error) {
+	cr := &ChainStreamer{
+		Ctx: context.Background(),
+		Url: url,
+	}
+	for _, o := range opts {
+		if err := o(cr); err != nil {
+			return nil, err
+		}
+	}
+	if err := cr.Validate(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// NewLiveEventLogWith returns a LiveEventLog of e and a ChainStreamer for
+// the node at url, configured by the options. The filter is that of e, so
+// WithFilter is an error.
+func NewLiveEventLogWith(e EventLog, url string, opts ...Option) (*LiveEventLog, error) {
+	cr, err := NewChainStreamer(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(cr.Filter, ethereum.FilterQuery{}) {
+		return nil, fmt.Errorf("got a filter; the filter of a LiveEventLog is that of its eventlog")
+	}
+	return NewLiveEventLog(e, *cr), nil
+}
+
+// WithContext sets the context of the calls to the node.
+func WithContext(ctx context.Context) Option {
+	return func(cr *ChainStreamer) error {
+		if ctx == nil {
+			return fmt.Errorf("got nil context")
+		}
+		cr.Ctx = ctx
+		return nil
+	}
+}
+
+// WithClient streams with c instead of dialing the url.
+func WithClient(c Client) Option {
+	return func(cr *ChainStreamer) error {
+		if c == nil {
+			return fmt.Errorf("got nil client")
+		}
+		cr.Client = c
+		return nil
+	}
+}
+
+// WithFilter sets the filter of the logs to stream.
+func WithFilter(q ethereum.FilterQuery) Option {
+	return func(cr *ChainStreamer) error {
+		cr.Filter = q
+		return nil
+	}
+}
+
+// WithBatchSize sets the number of blocks fetched per eth_getLogs call.
+func WithBatchSize(n uint64) Option {
+	return func(cr *ChainStreamer) error {
+		if n == 0 {
+			return fmt.Errorf("got batch size 0; want > 0")
+		}
+		cr.FetchBatchSize = n
+		return nil
+	}
+}
+
+// WithBatchOverlap sets the number of blocks refetched to detect reorgs.
+// It must be smaller than the batch size.
+func WithBatchOverlap(n uint64) Option {
+	return func(cr *ChainStreamer) error {
+		cr.BatchOverlap = n
+		return nil
+	}
+}
+
+// WithTxDetails fetches the transaction of every event.
+func WithTxDetails() Option {
+	return func(cr *ChainStreamer) error {
+		cr.FetchTxDetails = true
+		return nil
+	}
+}
+
+// WithHeaders fetches the header of every block; see TrackHeaders.
+func WithHeaders() Option {
+	return func(cr *ChainStreamer) error {
+		cr.TrackHeaders = true
+		return nil
+	}
+}
+
+// WithBuffer sets the Buffer and MaxBacklog of the subscription.
+func WithBuffer(n int, maxBacklog uint64) Option {
+	return func(cr *ChainStreamer) error {
+		if n < 0 {
+			return fmt.Errorf("got buffer %d; want >= 0", n)
+		}
+		cr.Buffer = n
+		cr.MaxBacklog = maxBacklog
+		return nil
+	}
+}
+
+// WithStats updates s as the stream progresses.
+func WithStats(s *StreamStats) Option {
+	return func(cr *ChainStreamer) error {
+		cr.Stats = s
+		return nil
+	}
+}
+
+// WithBudget limits the RPC calls of the stream to the budget.
+func WithBudget(b *Budget) Option {
+	return func(cr *ChainStreamer) error {
+		if b == nil {
+			return fmt.Errorf("got nil budget")
+		}
+		cr.Budget = b
+		return nil
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 
-	epb "github.com/jcjlcodes/eth-eventlog/proto/events"
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
 )
 
 // EventToProto creates a proto representation of an Event.
@@ -16,7 +16,7 @@ func EventToProto(e *Event) *epb.Event {
 	for i, t := range e.Topics {
 		topics[i] = t.Bytes()
 	}
-	return &epb.Event{
+	pb := &epb.Event{
 		Address: e.Address.Bytes(),
 		Topics:  topics,
 		Data:    e.Data,
@@ -31,7 +31,13 @@ func EventToProto(e *Event) *epb.Event {
 		TxValue: BigIntToString(e.TxValue),
 		TxFrom:  e.TxFrom.Bytes(),
 		TxGas:   e.TxGas,
+
+		TxMissing: e.TxMissing,
+	}
+	if e.TxDataHash != (common.Hash{}) {
+		pb.TxDataHash = e.TxDataHash.Bytes()
 	}
+	return pb
 }
 
 // EventFromProto creates an Event from its proto representation.
@@ -39,6 +45,9 @@ func EventFromProto(pb *epb.Event) (*Event, error) {
 	if len(pb.Address) != common.AddressLength {
 		return nil, fmt.Errorf("invalid address")
 	}
+	if pb.DataRef != 0 {
+		return nil, fmt.Errorf("got data_ref %d outside of a BlockSlice", pb.DataRef)
+	}
 	topics := make([]common.Hash, len(pb.Topics))
 	for i, t := range pb.Topics {
 		topics[i] = common.BytesToHash(t)
@@ -62,6 +71,9 @@ func EventFromProto(pb *epb.Event) (*Event, error) {
 		TxValue: txValue,
 		TxFrom:  common.BytesToAddress(pb.TxFrom),
 		TxGas:   pb.TxGas,
+
+		TxDataHash: common.BytesToHash(pb.TxDataHash),
+		TxMissing:  pb.TxMissing,
 	}, nil
 }
 
@@ -75,11 +87,20 @@ func BlockToProto(b *Block) *epb.Block {
 	for i, e := range b.Events {
 		events[i] = EventToProto(&e)
 	}
-	return &epb.Block{
+	pb := &epb.Block{
 		Number: b.Number,
 		Hash:   b.Hash.Bytes(),
 		Events: events,
+		Meta:   b.Meta,
 	}
+	if b.ParentHash != (common.Hash{}) {
+		pb.ParentHash = b.ParentHash.Bytes()
+	}
+	if b.EventsRoot != (common.Hash{}) {
+		pb.EventsRoot = b.EventsRoot.Bytes()
+	}
+	pb.Time = b.Time
+	return pb
 }
 
 func BlockFromProto(pb *epb.Block) (*Block, error) {
@@ -91,11 +112,74 @@ func BlockFromProto(pb *epb.Block) (*Block, error) {
 		}
 		events[i] = *e
 	}
-	return &Block{
-		Number: pb.Number,
-		Hash:   common.BytesToHash(pb.Hash),
-		Events: events,
-	}, nil
+	b := &Block{
+		Number:     pb.Number,
+		Hash:       common.BytesToHash(pb.Hash),
+		ParentHash: common.BytesToHash(pb.ParentHash),
+		Time:       pb.Time,
+		Events:     events,
+		Meta:       pb.Meta,
+		EventsRoot: common.BytesToHash(pb.EventsRoot),
+	}
+	if err := b.VerifyEventsRoot(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// MessageToProto creates a proto representation of a Message.
+func MessageToProto(m *Message) *epb.Message {
+	pb := &epb.Message{
+		Number:         m.Number,
+		ReplayComplete: m.ReplayComplete,
+	}
+	switch m.Action {
+	case Append:
+		pb.Action = epb.Message_APPEND
+		pb.Block = BlockToProto(m.Block)
+	case Rollback:
+		pb.Action = epb.Message_ROLLBACK
+		for _, b := range m.Dropped {
+			pb.Dropped = append(pb.Dropped, BlockToProto(b))
+		}
+	case SetNext:
+		pb.Action = epb.Message_SET_NEXT
+	}
+	return pb
+}
+
+// MessageFromProto creates a Message from its proto representation.
+func MessageFromProto(pb *epb.Message) (*Message, error) {
+	m := &Message{
+		Number:         pb.Number,
+		ReplayComplete: pb.ReplayComplete,
+	}
+	switch pb.Action {
+	case epb.Message_APPEND:
+		if pb.Block == nil {
+			return nil, fmt.Errorf("got Append message without block")
+		}
+		b, err := BlockFromProto(pb.Block)
+		if err != nil {
+			return nil, err
+		}
+		m.Action = Append
+		m.Block = b
+	case epb.Message_ROLLBACK:
+		m.Action = Rollback
+		for _, pbb := range pb.Dropped {
+			b, err := BlockFromProto(pbb)
+			if err != nil {
+				return nil, err
+			}
+			m.Dropped = append(m.Dropped, b)
+		}
+	case epb.Message_SET_NEXT:
+		m.Action = SetNext
+	default:
+		return nil, fmt.Errorf("unknown action %v", pb.Action)
+	}
+	return m, nil
 }
 
 func BlockSliceToProto(bs *BlockSlice) *epb.BlockSlice {
@@ -106,18 +190,23 @@ func BlockSliceToProto(bs *BlockSlice) *epb.BlockSlice {
 	}
 
 	// Careful, because bs.Blocks might be extended while we work.
-	blocks := make([]*epb.Block, len(bs.Blocks))
-	for i, b := range bs.Blocks {
-		if bs.Blocks[i].Number >= pb.End {
+	blocks := make([]*epb.Block, 0, len(bs.Blocks))
+	for _, b := range bs.Blocks {
+		if b.Number >= pb.End {
 			break
 		}
-		blocks[i] = BlockToProto(b)
+		blocks = append(blocks, BlockToProto(b))
 	}
 	pb.Blocks = blocks
 	return pb
 }
 
+// BlockSliceFromProto creates a BlockSlice from its proto representation,
+// resolving the data references of a DedupData BlockSlice in place.
 func BlockSliceFromProto(pb *epb.BlockSlice) (*BlockSlice, error) {
+	if err := resolveData(pb); err != nil {
+		return nil, err
+	}
 	blocks := make([]*Block, len(pb.Blocks))
 	for i, pbb := range pb.Blocks {
 		b, err := BlockFromProto(pbb)
@@ -126,12 +215,16 @@ func BlockSliceFromProto(pb *epb.BlockSlice) (*BlockSlice, error) {
 		}
 		blocks[i] = b
 	}
-	return &BlockSlice{
+	bs := &BlockSlice{
 		Start:            pb.Start,
 		End:              pb.End,
 		DistanceFromHead: pb.DistanceFromHead,
 		Blocks:           blocks,
-	}, nil
+	}
+	if err := bs.check(); err != nil {
+		return nil, err
+	}
+	return bs, nil
 }
 
 func FilterQueryToProto(q *ethereum.FilterQuery) *epb.FilterQuery {
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"google.golang.org/protobuf/encoding/protojson"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// jsonOptions use the field names of the .proto file, which do not change
+// with the generator, so the files can be edited and diffed by hand.
+var jsonOptions = protojson.MarshalOptions{
+	Multiline:       true,
+	Indent:          "  ",
+	UseProtoNames:   true,
+	EmitUnpopulated: true,
+}
+
+// MarshalJSON encodes the eventlog as protojson. SaveCheckpoint uses it for
+// paths ending in ".json" or ".json.gz".
+func (l *InMemoryEventLog) MarshalJSON() ([]byte, error) {
+	return jsonOptions.Marshal(l.ToProto())
+}
+
+// UnmarshalJSON decodes an eventlog encoded by MarshalJSON. Unknown fields
+// are an error, to catch typos in edited files.
+func (l *InMemoryEventLog) UnmarshalJSON(data []byte) error {
+	pb := &epb.EventLogFile{}
+	if err := protojson.Unmarshal(data, pb); err != nil {
+		return err
+	}
+	nl, err := InMemoryEventLogFromProto(pb)
+	if err != nil {
+		return err
+	}
+	*l = *nl
+	return nil
+}
+
+func isJSON(data []byte) bool {
+	return len(data) > 0 && data[0] == '{'
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ReceiptClient is a Client that also fetches blocks and receipts, as
+// needed to verify events against receipts. ethclient.Client implements it.
+type ReceiptClient interface {
+	Client
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// VerifyReceipts checks the events of a BlockSlice against the receipts of
+// every block in its range; see VerifyBlockReceipts. This fetches all
+// receipts of every block, so it is meant for auditing, not for streaming.
+func VerifyReceipts(ctx context.Context, client ReceiptClient, q ethereum.FilterQuery, bs *BlockSlice) error {
+	i := 0
+	for n := bs.Start; n < bs.End; n++ {
+		var b *Block
+		if i < len(bs.Blocks) && bs.Blocks[i].Number == n {
+			b = bs.Blocks[i]
+			i++
+		}
+		if err := VerifyBlockReceipts(ctx, client, q, n, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyBlockReceipts checks the events stored for block n, b, or nil if
+// none were stored, against the chain. It fetches the receipts of all
+// transactions of the block, checks that they hash to the receipts root of
+// the block header, and that the logs among them matching the filter are
+// exactly the stored events. So a provider can neither omit nor fabricate
+// logs without the header being wrong as well.
+func VerifyBlockReceipts(ctx context.Context, client ReceiptClient, q ethereum.FilterQuery, n uint64, b *Block) error {
+	var hash common.Hash
+	if b != nil {
+		hash = b.Hash
+	} else {
+		h, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return err
+		}
+		hash = h.Hash()
+	}
+	block, err := client.BlockByHash(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("block %d: %w", n, err)
+	}
+	if block.NumberU64() != n {
+		return fmt.Errorf("block %d: got block hash %s of block %d", n, hash.Hex(), block.NumberU64())
+	}
+
+	receipts := make(types.Receipts, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		r, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return fmt.Errorf("block %d: receipt of %s: %w", n, tx.Hash().Hex(), err)
+		}
+		if r.BlockHash != hash {
+			return fmt.Errorf("block %d: receipt of %s is in block %s", n, tx.Hash().Hex(), r.BlockHash.Hex())
+		}
+		receipts[i] = r
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+		return fmt.Errorf("block %d: got receipts root %s; want %s", n, root.Hex(), block.ReceiptHash().Hex())
+	}
+
+	// The log index and transaction hash are not part of the receipts, so
+	// they are derived from the position of the logs.
+	var want []Event
+	index := uint64(0)
+	for i, r := range receipts {
+		for _, l := range r.Logs {
+			if matchesFilter(q, l) {
+				want = append(want, Event{
+					Address: l.Address,
+					Topics:  l.Topics,
+					Data:    l.Data,
+					Index:   index,
+					TxHash:  block.Transactions()[i].Hash(),
+				})
+			}
+			index++
+		}
+	}
+	var got []Event
+	if b != nil {
+		got = b.Events
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("block %d: got %d events; want %d from receipts", n, len(got), len(want))
+	}
+	for i := range got {
+		if !sameEvent(&got[i], &want[i]) || got[i].Index != want[i].Index {
+			return fmt.Errorf("block %d: event %d does not match receipts", n, got[i].Index)
+		}
+	}
+	return nil
+}
+
+// matchesFilter reports whether a log matches the addresses and topics of a
+// filter query, as eth_getLogs does.
+func matchesFilter(q ethereum.FilterQuery, l *types.Log) bool {
+	if len(q.Addresses) > 0 {
+		found := false
+		for _, a := range q.Addresses {
+			if a == l.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, ts := range q.Topics {
+		if len(ts) == 0 {
+			continue
+		}
+		found := false
+		for _, t := range ts {
+			if t == l.Topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package events
+
+// Scheduler decides which block range a Backfill fetches next, so that
+// custom strategies, like fetching the newest blocks first or searching
+// around known activity, can be plugged in. The ranges it returns must not
+// overlap, and together cover the range of the Fetch.
+//
+// A Backfill calls Start, then Next and Fetched alternately until Next
+// returns false. A node may return fewer blocks than asked for; the
+// scheduler then has to return the rest of the range again later.
+type Scheduler interface {
+	// Start begins scheduling the blocks from..to (inclusive), in ranges
+	// of at most batchSize blocks.
+	Start(from, to, batchSize uint64)
+
+	// Next returns the blocks to fetch next, from..to (inclusive), or false
+	// if the whole range is fetched.
+	Next() (from, to uint64, ok bool)
+
+	// Fetched reports the blocks fetched for the last range, s.Start up to
+	// s.End (exclusive).
+	Fetched(s *BlockSlice)
+}
+
+// SequentialScheduler fetches a range in order, oldest first. It is the
+// default Scheduler of a Backfill.
+type SequentialScheduler struct {
+	next, to, batchSize uint64
+	done                bool
+}
+
+func (s *SequentialScheduler) Start(from, to, batchSize uint64) {
+	*s = SequentialScheduler{next: from, to: to, batchSize: batchSize, done: to < from}
+}
+
+func (s *SequentialScheduler) Next() (uint64, uint64, bool) {
+	if s.done {
+		return 0, 0, false
+	}
+	end := s.next + s.batchSize - 1
+	if end > s.to || end < s.next {
+		end = s.to
+	}
+	return s.next, end, true
+}
+
+func (s *SequentialScheduler) Fetched(b *BlockSlice) {
+	s.next = b.End
+	s.done = b.End > s.to
+}
+
+// NewestFirstScheduler fetches a range from the newest blocks backwards.
+type NewestFirstScheduler struct {
+	from, hi, batchSize uint64 // hi: the blocks from hi on are scheduled
+	rest, restEnd       uint64 // of the last range, not returned by the node
+	last                uint64 // end of the last range
+}
+
+func (s *NewestFirstScheduler) Start(from, to, batchSize uint64) {
+	*s = NewestFirstScheduler{from: from, hi: to + 1, batchSize: batchSize}
+	if to < from {
+		s.hi = from
+	}
+}
+
+func (s *NewestFirstScheduler) Next() (uint64, uint64, bool) {
+	if s.rest < s.restEnd {
+		s.last = s.restEnd - 1
+		return s.rest, s.restEnd - 1, true
+	}
+	if s.hi <= s.from {
+		return 0, 0, false
+	}
+	start := s.from
+	if s.hi-s.from > s.batchSize {
+		start = s.hi - s.batchSize
+	}
+	end := s.hi - 1
+	s.hi = start
+	s.last = end
+	return start, end, true
+}
+
+func (s *NewestFirstScheduler) Fetched(b *BlockSlice) {
+	s.rest, s.restEnd = b.End, s.last+1
+}
@@ -0,0 +1,201 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secrets provides credentials by name, e.g. the API key of a node
+// provider, so they need not appear in flags or config files.
+type Secrets interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// EnvSecrets reads secrets from environment variables.
+type EnvSecrets struct{}
+
+func (EnvSecrets) Secret(ctx context.Context, name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("secret %s: environment variable not set", name)
+	}
+	return v, nil
+}
+
+// FileSecrets reads each secret from the file of its name in Dir, as
+// mounted by Docker or Kubernetes secrets.
+type FileSecrets struct {
+	Dir string
+}
+
+func (fs FileSecrets) Secret(ctx context.Context, name string) (string, error) {
+	if name != filepath.Base(name) {
+		return "", fmt.Errorf("secret %s: invalid name", name)
+	}
+	bs, err := os.ReadFile(filepath.Join(fs.Dir, name))
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// SecretsFunc adapts a function to the Secrets interface, e.g. to fetch
+// secrets from Vault.
+type SecretsFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretsFunc) Secret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+var (
+	secretsMu sync.Mutex
+	secrets   Secrets = EnvSecrets{}
+	redacted          = make(map[string]bool)
+)
+
+// RegisterSecrets sets the Secrets used by ExpandURL. The default is
+// EnvSecrets.
+func RegisterSecrets(s Secrets) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = s
+}
+
+var secretRef = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// ExpandURL replaces references ${NAME} in a node URL with the secrets of
+// the registered Secrets. The secrets, and credentials found in the URL
+// itself, are added to the values removed by Redact. Dial expands URLs, so
+// ChainStreamer.Url may hold references.
+func ExpandURL(ctx context.Context, rawurl string) (string, error) {
+	secretsMu.Lock()
+	s := secrets
+	secretsMu.Unlock()
+	var err error
+	expanded := secretRef.ReplaceAllStringFunc(rawurl, func(ref string) string {
+		v, serr := s.Secret(ctx, secretRef.FindStringSubmatch(ref)[1])
+		if serr != nil {
+			if err == nil {
+				err = serr
+			}
+			return ref
+		}
+		AddRedaction(v)
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range urlCredentials(expanded) {
+		AddRedaction(c)
+	}
+	return expanded, nil
+}
+
+// minRedactionLength is the length below which values are not redacted, as
+// they would mangle unrelated output.
+const minRedactionLength = 6
+
+// AddRedaction adds a value to be removed by Redact.
+func AddRedaction(value string) {
+	if len(value) < minRedactionLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	redacted[value] = true
+}
+
+// Redact replaces the known secrets in s with "REDACTED".
+func Redact(s string) string {
+	secretsMu.Lock()
+	values := make([]string, 0, len(redacted))
+	for v := range redacted {
+		values = append(values, v)
+	}
+	secretsMu.Unlock()
+	// Longer values first, in case one secret contains another.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		s = strings.ReplaceAll(s, v, "REDACTED")
+	}
+	return s
+}
+
+// RedactingWriter returns a writer redacting the known secrets from each
+// write, e.g. for log.SetOutput. A secret split across writes is not
+// redacted, which is fine for the log package, writing one message at a
+// time.
+func RedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// apiKeySegment matches path segments and query values that look like API
+// keys, as used by node providers, e.g. /v3/<key>.
+var apiKeySegment = regexp.MustCompile(`^[A-Za-z0-9_-]{16,}$`)
+
+// urlCredentials returns the password, and the path segments and query
+// values looking like API keys, of a URL.
+func urlCredentials(rawurl string) []string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil
+	}
+	var creds []string
+	if p, ok := u.User.Password(); ok {
+		creds = append(creds, p)
+	}
+	for _, seg := range strings.Split(u.Path, "/") {
+		if apiKeySegment.MatchString(seg) {
+			creds = append(creds, seg)
+		}
+	}
+	for _, vs := range u.Query() {
+		for _, v := range vs {
+			if apiKeySegment.MatchString(v) {
+				creds = append(creds, v)
+			}
+		}
+	}
+	return creds
+}
+
+// redactError redacts the known secrets from the message of an error. The
+// error is still available to errors.Is and errors.As.
+func redactError(err error) error {
+	if err == nil || err == Canceled {
+		return err
+	}
+	return &redactedError{err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
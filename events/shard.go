@@ -0,0 +1,109 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ShardFunc returns which of k shards block n belongs to.
+type ShardFunc func(n uint64, k int) int
+
+// ModuloShard assigns consecutive blocks to consecutive shards.
+func ModuloShard(n uint64, k int) int {
+	return int(n % uint64(k))
+}
+
+// RangeShard assigns runs of size consecutive blocks to the same shard.
+func RangeShard(size uint64) ShardFunc {
+	return func(n uint64, k int) int {
+		return int(n / size % uint64(k))
+	}
+}
+
+// Sharder drains a subscription into several sinks in parallel, e.g. to
+// spread CPU heavy decoding over cores. Each block is appended to one sink,
+// chosen by Shard, while SetNext and Rollback messages go to every sink.
+//
+// A Rollback is a barrier: it is applied after all earlier messages were
+// applied by every sink, and no later message is applied before every sink
+// has applied the Rollback.
+type Sharder struct {
+	Sinks []Sink
+	Shard ShardFunc // defaults to ModuloShard
+
+	// Buffer is the number of messages queued per sink.
+	Buffer int
+}
+
+// Drain applies the messages of sub to the sinks until the subscription
+// ends or a sink returns an error.
+func (sh *Sharder) Drain(sub *Subscription) error {
+	shard := sh.Shard
+	if shard == nil {
+		shard = ModuloShard
+	}
+	k := len(sh.Sinks)
+	if k == 0 {
+		return fmt.Errorf("got 0 sinks; want at least 1")
+	}
+	queues := make([]chan *Message, k)
+	barrier := make(chan struct{}, k)
+	errc := make(chan error, k)
+	var wg sync.WaitGroup
+	for i, s := range sh.Sinks {
+		queues[i] = make(chan *Message, sh.Buffer)
+		wg.Add(1)
+		go func(s Sink, q chan *Message) {
+			defer wg.Done()
+			failed := false
+			for m := range q {
+				if !failed {
+					if err := Apply(s, m); err != nil {
+						failed = true
+						errc <- err
+					}
+				}
+				if m.Action == Rollback {
+					barrier <- struct{}{}
+				}
+			}
+		}(s, queues[i])
+	}
+	stop := func() {
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}
+
+	for m := range sub.C {
+		select {
+		case err := <-errc:
+			stop()
+			return err
+		default:
+		}
+		switch m.Action {
+		case Append:
+			queues[shard(m.Block.Number, k)] <- m
+		case Rollback:
+			for _, q := range queues {
+				q <- m
+			}
+			for range queues {
+				<-barrier
+			}
+		default:
+			for _, q := range queues {
+				q <- m
+			}
+		}
+	}
+	stop()
+	select {
+	case err := <-errc:
+		return err
+	default:
+	}
+	return <-sub.Err
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SignatureSuffix is appended to the path of a checkpoint file for the path
+// of its signature.
+const SignatureSuffix = ".sig"
+
+// ErrSignatureMissing is returned when loading a signed checkpoint whose
+// signature file does not exist. It does not match os.ErrNotExist, so that
+// deleting the signature does not pass for a missing checkpoint.
+var ErrSignatureMissing = errors.New("signature file missing")
+
+// SaveSignedCheckpoint is like SaveCheckpoint, and writes an ed25519
+// signature of the file next to it.
+func SaveSignedCheckpoint(l *InMemoryEventLog, path string, key ed25519.PrivateKey) error {
+	return SaveCheckpointWith(l, path, CheckpointOptions{SigningKey: key})
+}
+
+// writeSignedFile replaces the file at path and its signature. The
+// signature file first gets the signatures of both the old and the new
+// contents, so that the pair verifies whenever a crash interrupts it.
+func writeSignedFile(path string, data []byte, key ed25519.PrivateKey) error {
+	sig := hex.EncodeToString(ed25519.Sign(key, data)) + "\n"
+	if old, err := os.ReadFile(path); err == nil {
+		both := sig + hex.EncodeToString(ed25519.Sign(key, old)) + "\n"
+		if err := WriteFileAtomic(path+SignatureSuffix, []byte(both)); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else if err := WriteFileAtomic(path+SignatureSuffix, []byte(sig)); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, data); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path+SignatureSuffix, []byte(sig))
+}
+
+// SignCheckpoint writes an ed25519 signature of the contents of a checkpoint
+// file to the path with SignatureSuffix. The signature covers the file as
+// written, whatever its encoding.
+func SignCheckpoint(path string, key ed25519.PrivateKey) error {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig := ed25519.Sign(key, bs)
+	return WriteFileAtomic(path+SignatureSuffix, []byte(hex.EncodeToString(sig)+"\n"))
+}
+
+// LoadSignedCheckpoint is like LoadCheckpoint, but fails unless the file has
+// a valid signature by one of the given keys.
+func LoadSignedCheckpoint(path string, keys ...ed25519.PublicKey) (*InMemoryEventLog, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(path, bs, keys); err != nil {
+		return nil, err
+	}
+	return decodeCheckpoint(bs)
+}
+
+// VerifyCheckpoint checks that a checkpoint file has a valid signature by one
+// of the given keys.
+func VerifyCheckpoint(path string, keys ...ed25519.PublicKey) error {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return verifySignature(path, bs, keys)
+}
+
+// verifySignature checks data against the signatures of the signature
+// file of path, one per line.
+func verifySignature(path string, data []byte, keys []ed25519.PublicKey) error {
+	s, err := os.ReadFile(path + SignatureSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", path+SignatureSuffix, ErrSignatureMissing)
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Fields(string(s))
+	if len(lines) == 0 {
+		return fmt.Errorf("%s: invalid signature file", path+SignatureSuffix)
+	}
+	for _, line := range lines {
+		sig, err := hex.DecodeString(line)
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return fmt.Errorf("%s: invalid signature file", path+SignatureSuffix)
+		}
+		for _, k := range keys {
+			if ed25519.Verify(k, data, sig) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s: signature does not match any trusted key", path)
+}
+
+// ReadSigningKey reads an ed25519 private key from a file holding its
+// 32 byte seed in hex.
+func ReadSigningKey(path string) (ed25519.PrivateKey, error) {
+	seed, err := readHexFile(path, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ReadPublicKey reads an ed25519 public key from a file holding it in hex.
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	k, err := readHexFile(path, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(k), nil
+}
+
+func readHexFile(path string, size int) ([]byte, error) {
+	s, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := hex.DecodeString(strings.TrimSpace(string(s)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(bs) != size {
+		return nil, fmt.Errorf("%s: got %d bytes; want %d", path, len(bs), size)
+	}
+	return bs, nil
+}
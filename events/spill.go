@@ -0,0 +1,176 @@
+package events
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+const DefaultSpillMemory = 1024 // messages
+
+// SpillStreamer wraps a Streamer to decouple it from a slow consumer. It
+// reads messages as fast as the Streamer sends them, queueing them in memory
+// and, once Memory messages are queued, in a temporary file in Dir. The
+// consumer receives all messages, including rollbacks, in order. This lets
+// a stream keep going, e.g. through a database outage, without holding an
+// unbounded number of messages in memory.
+type SpillStreamer struct {
+	Streamer Streamer
+	Dir      string // os.TempDir() if empty
+	Memory   int    // DefaultSpillMemory if zero
+}
+
+func (ss *SpillStreamer) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	f, err := os.CreateTemp(ss.Dir, "eventlog-spill-*")
+	if err != nil {
+		return nil, err
+	}
+	sub, err := ss.Streamer.Stream(done, from)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	memory := ss.Memory
+	if memory <= 0 {
+		memory = DefaultSpillMemory
+	}
+	q := &spillQueue{file: f, memory: memory}
+
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := q.run(sub, c, done)
+		close(c)
+		f.Close()
+		os.Remove(f.Name())
+		if err != nil {
+			errc <- err
+			return
+		}
+		errc <- <-sub.Err
+	}()
+
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+// spillQueue is a FIFO queue of messages held in memory up to a limit, and
+// in a file beyond it.
+type spillQueue struct {
+	memory int
+	mem    []*Message
+	file   *os.File
+	w      *bufio.Writer
+	r      *bufio.Reader
+	onDisk int // messages in the file not yet read
+}
+
+func (q *spillQueue) run(sub *Subscription, c chan *Message, done chan struct{}) error {
+	in := sub.C
+	for in != nil || q.len() > 0 {
+		var out chan *Message
+		var next *Message
+		if len(q.mem) > 0 {
+			out = c
+			next = q.mem[0]
+		}
+		select {
+		case <-done:
+			return Canceled
+		case m, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			if err := q.push(m); err != nil {
+				return err
+			}
+		case out <- next:
+			q.mem[0] = nil
+			q.mem = q.mem[1:]
+			if len(q.mem) == 0 {
+				if err := q.refill(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (q *spillQueue) len() int {
+	return len(q.mem) + q.onDisk
+}
+
+func (q *spillQueue) push(m *Message) error {
+	if q.onDisk == 0 && len(q.mem) < q.memory {
+		q.mem = append(q.mem, m)
+		return nil
+	}
+	if q.w == nil {
+		q.w = bufio.NewWriter(q.file)
+	}
+	bs, err := proto.Marshal(MessageToProto(m))
+	if err != nil {
+		return err
+	}
+	var hdr [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(bs)))
+	if _, err := q.w.Write(hdr[:n]); err != nil {
+		return err
+	}
+	if _, err := q.w.Write(bs); err != nil {
+		return err
+	}
+	q.onDisk++
+	return nil
+}
+
+// refill moves up to memory messages from the file into memory. The file is
+// emptied once all its messages are read.
+func (q *spillQueue) refill() error {
+	if q.onDisk == 0 {
+		return nil
+	}
+	if err := q.w.Flush(); err != nil {
+		return err
+	}
+	if q.r == nil {
+		q.r = bufio.NewReader(io.NewSectionReader(q.file, 0, 1<<62))
+	}
+	for q.onDisk > 0 && len(q.mem) < q.memory {
+		n, err := binary.ReadUvarint(q.r)
+		if err != nil {
+			return err
+		}
+		bs := make([]byte, n)
+		if _, err := io.ReadFull(q.r, bs); err != nil {
+			return err
+		}
+		pb := &epb.Message{}
+		if err := proto.Unmarshal(bs, pb); err != nil {
+			return err
+		}
+		m, err := MessageFromProto(pb)
+		if err != nil {
+			return err
+		}
+		q.mem = append(q.mem, m)
+		q.onDisk--
+	}
+	if q.onDisk == 0 {
+		q.w, q.r = nil, nil
+		if err := q.file.Truncate(0); err != nil {
+			return err
+		}
+		_, err := q.file.Seek(0, io.SeekStart)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/proto"
+
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+// sqliteStreamPage is the number of blocks Stream reads per query, so that
+// it does not hold all the blocks in memory.
+const sqliteStreamPage = 1000
+
+// IdentifierRE matches the SQL identifiers accepted as table prefixes.
+var IdentifierRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLiteEventLog is an EventLog stored in an SQLite database, so that it
+// survives crashes and restarts without checkpoints. Append, Rollback,
+// SetNext and Prune are each one transaction. The blocks and their events
+// are stored as proto in the tables prefix+"eventlog_blocks" and
+// prefix+"eventlog_events", the events with their address and first topic,
+// which are indexed for EventsByAddress and EventsByTopic; the names differ
+// from those of sinks.SQLite, so both can share a database and prefix.
+//
+// The program provides the database handle, and imports an SQLite driver
+// for it:
+//
+//	db, err := sql.Open("sqlite3", path) // with _ "github.com/mattn/go-sqlite3"
+//	l, err := events.OpenSQLiteEventLog(ctx, db, "", from, filter)
+//
+// Close does not close the database.
+type SQLiteEventLog struct {
+	ctx    context.Context
+	db     *sql.DB
+	blocks string // table of blocks, without their events
+	events string // table of events
+	state  string // table of the single row with the range and filter
+
+	filter      ethereum.FilterQuery
+	first, next uint64
+}
+
+// OpenSQLiteEventLog opens the eventlog stored with the table prefix, or
+// creates an empty one starting at from if there is none. An existing
+// eventlog keeps its range, and must have the same filter.
+func OpenSQLiteEventLog(ctx context.Context, db *sql.DB, prefix string, from uint64, filter ethereum.FilterQuery) (*SQLiteEventLog, error) {
+	if prefix != "" && !IdentifierRE.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid table prefix %q", prefix)
+	}
+	l := &SQLiteEventLog{
+		ctx:    ctx,
+		db:     db,
+		blocks: prefix + "eventlog_blocks",
+		events: prefix + "eventlog_events",
+		state:  prefix + "eventlog_state",
+		filter: filter,
+	}
+	fq, err := proto.Marshal(FilterQueryToProto(&filter))
+	if err != nil {
+		return nil, err
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + l.blocks + ` (
+			number INTEGER PRIMARY KEY,
+			hash BLOB NOT NULL,
+			block BLOB NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + l.events + ` (
+			block_number INTEGER NOT NULL,
+			log_index INTEGER NOT NULL,
+			address BLOB NOT NULL,
+			topic0 BLOB,
+			event BLOB NOT NULL,
+			PRIMARY KEY (block_number, log_index)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + l.state + ` (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			first_block INTEGER NOT NULL,
+			next_block INTEGER NOT NULL,
+			filter BLOB NOT NULL
+		)`,
+	}
+	stmts = append(stmts, l.indexes()...)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op after Commit
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+	var first, next int64
+	var stored []byte
+	err = tx.QueryRowContext(ctx, `SELECT first_block, next_block, filter FROM `+l.state).Scan(&first, &next, &stored)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+l.state+` (id, first_block, next_block, filter) VALUES (1, ?, ?, ?)`,
+			int64(from), int64(from), fq); err != nil {
+			return nil, err
+		}
+		l.first, l.next = from, from
+	case err != nil:
+		return nil, err
+	default:
+		var pb epb.FilterQuery
+		if err := proto.Unmarshal(stored, &pb); err != nil {
+			return nil, fmt.Errorf("%s: filter: %w", l.state, err)
+		}
+		if !proto.Equal(&pb, FilterQueryToProto(&filter)) {
+			return nil, fmt.Errorf("stored eventlog has another filter; use another table prefix")
+		}
+		l.first, l.next = uint64(first), uint64(next)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// indexes returns the statements creating the indexes of the events table.
+func (l *SQLiteEventLog) indexes() []string {
+	return []string{
+		`CREATE INDEX IF NOT EXISTS ` + l.events + `_address ON ` + l.events + ` (address, block_number)`,
+		`CREATE INDEX IF NOT EXISTS ` + l.events + `_topic0 ON ` + l.events + ` (topic0, block_number)`,
+	}
+}
+
+// RebuildIndexes drops and recreates the indexes of the events table in one
+// transaction, e.g. after a bulk load or to repair them.
+func (l *SQLiteEventLog) RebuildIndexes() error {
+	tx, err := l.db.BeginTx(l.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	stmts := []string{
+		`DROP INDEX IF EXISTS ` + l.events + `_address`,
+		`DROP INDEX IF EXISTS ` + l.events + `_topic0`,
+	}
+	for _, stmt := range append(stmts, l.indexes()...) {
+		if _, err := tx.ExecContext(l.ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// EventsByAddress returns the events in blocks [from, to) emitted by addr.
+func (l *SQLiteEventLog) EventsByAddress(addr common.Address, from, to uint64) ([]Event, error) {
+	return l.query(`address = ?`, addr.Bytes(), from, to)
+}
+
+// EventsByTopic returns the events in blocks [from, to) whose first topic
+// is topic0.
+func (l *SQLiteEventLog) EventsByTopic(topic0 common.Hash, from, to uint64) ([]Event, error) {
+	return l.query(`topic0 = ?`, topic0.Bytes(), from, to)
+}
+
+// query returns the events in blocks [from, to) matching the condition on
+// an indexed column.
+func (l *SQLiteEventLog) query(cond string, arg interface{}, from, to uint64) ([]Event, error) {
+	if to > math.MaxInt64 {
+		to = math.MaxInt64
+	}
+	if from >= to {
+		return nil, nil
+	}
+	rows, err := l.db.QueryContext(l.ctx, `SELECT event FROM `+l.events+` WHERE `+cond+` AND block_number >= ? AND block_number < ? ORDER BY block_number, log_index`,
+		arg, int64(from), int64(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var es []Event
+	for rows.Next() {
+		var bs []byte
+		if err := rows.Scan(&bs); err != nil {
+			return nil, err
+		}
+		var pb epb.Event
+		if err := proto.Unmarshal(bs, &pb); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.events, err)
+		}
+		e, err := EventFromProto(&pb)
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, *e)
+	}
+	return es, rows.Err()
+}
+
+func (l *SQLiteEventLog) FirstBlock() uint64 {
+	return l.first
+}
+
+func (l *SQLiteEventLog) NextBlock() uint64 {
+	return l.next
+}
+
+func (l *SQLiteEventLog) Filter() ethereum.FilterQuery {
+	return l.filter
+}
+
+// update runs fn in a transaction and stores the range of the eventlog
+// afterwards.
+func (l *SQLiteEventLog) update(first, next uint64, fn func(tx *sql.Tx) error) error {
+	tx, err := l.db.BeginTx(l.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(l.ctx, `UPDATE `+l.state+` SET first_block = ?, next_block = ?`, int64(first), int64(next)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	l.first, l.next = first, next
+	return nil
+}
+
+// deleteRange deletes the blocks and events with numbers in from..to-1.
+func (l *SQLiteEventLog) deleteRange(tx *sql.Tx, from, to int64) error {
+	if _, err := tx.ExecContext(l.ctx, `DELETE FROM `+l.events+` WHERE block_number >= ? AND block_number < ?`, from, to); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(l.ctx, `DELETE FROM `+l.blocks+` WHERE number >= ? AND number < ?`, from, to)
+	return err
+}
+
+func (l *SQLiteEventLog) Append(b *Block) error {
+	if b.Number < l.next {
+		return fmt.Errorf("got blk.Number=%d; want blk.Number>=%d", b.Number, l.next)
+	}
+	header := *b
+	header.Events = nil
+	hb, err := proto.Marshal(BlockToProto(&header))
+	if err != nil {
+		return err
+	}
+	return l.update(l.first, b.Number+1, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(l.ctx, `INSERT INTO `+l.blocks+` (number, hash, block) VALUES (?, ?, ?)`,
+			int64(b.Number), b.Hash.Bytes(), hb); err != nil {
+			return err
+		}
+		insert, err := tx.PrepareContext(l.ctx, `INSERT INTO `+l.events+` (block_number, log_index, address, topic0, event) VALUES (?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer insert.Close()
+		for i := range b.Events {
+			e := &b.Events[i]
+			eb, err := proto.Marshal(EventToProto(e))
+			if err != nil {
+				return err
+			}
+			var topic0 interface{}
+			if len(e.Topics) > 0 {
+				topic0 = e.Topics[0].Bytes()
+			}
+			if _, err := insert.ExecContext(l.ctx, int64(b.Number), int64(e.Index), e.Address.Bytes(), topic0, eb); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback drops the blocks from n on, like BlockSlice.Rollback.
+func (l *SQLiteEventLog) Rollback(n uint64) error {
+	if n < l.first || n > l.next {
+		return &RangeError{Op: "Rollback", N: n, Min: l.first, Max: l.next}
+	}
+	return l.update(l.first, n, func(tx *sql.Tx) error {
+		return l.deleteRange(tx, int64(n), int64(l.next))
+	})
+}
+
+func (l *SQLiteEventLog) SetNext(n uint64) error {
+	if n < l.next {
+		return &RangeError{Op: "Extend", N: n, Min: l.next, Max: math.MaxUint64}
+	}
+	if n == l.next {
+		return nil
+	}
+	return l.update(l.first, n, func(*sql.Tx) error { return nil })
+}
+
+// Prune drops the blocks before a block number. Pruning beyond NextBlock
+// empties the eventlog.
+func (l *SQLiteEventLog) Prune(before uint64) error {
+	if before <= l.first {
+		return nil
+	}
+	if before > l.next {
+		before = l.next
+	}
+	return l.update(before, l.next, func(tx *sql.Tx) error {
+		return l.deleteRange(tx, int64(l.first), int64(before))
+	})
+}
+
+func (l *SQLiteEventLog) Close() error {
+	return nil
+}
+
+// Stream streams the stored blocks from block from on, then a SetNext to
+// the end. It reads them in one transaction, so the stream is consistent,
+// but the transaction stays open until the subscriber has all blocks.
+func (l *SQLiteEventLog) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	if from < l.first || from > l.next {
+		return nil, &RangeError{Op: "Stream", N: from, Min: l.first, Max: l.next}
+	}
+
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := l.stream(c, done, from)
+		close(c)
+		errc <- err
+	}()
+
+	return &Subscription{
+		C:    c,
+		Err:  errc,
+		Done: done,
+	}, nil
+}
+
+func (l *SQLiteEventLog) stream(c chan *Message, done chan struct{}, from uint64) error {
+	tx, err := l.db.BeginTx(l.ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // read only
+	for {
+		blocks, err := l.page(tx, from)
+		if err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			if err := sendOrDone(c, done, &Message{Action: Append, Block: b}); err != nil {
+				return err
+			}
+		}
+		if len(blocks) < sqliteStreamPage {
+			break
+		}
+		from = blocks[len(blocks)-1].Number + 1
+	}
+	var next int64
+	if err := tx.QueryRowContext(l.ctx, `SELECT next_block FROM `+l.state).Scan(&next); err != nil {
+		return err
+	}
+	return sendOrDone(c, done, &Message{Action: SetNext, Number: uint64(next)})
+}
+
+// page reads up to sqliteStreamPage blocks from block from on, with their
+// events.
+func (l *SQLiteEventLog) page(tx *sql.Tx, from uint64) ([]*Block, error) {
+	rows, err := tx.QueryContext(l.ctx, `SELECT block FROM `+l.blocks+` WHERE number >= ? ORDER BY number LIMIT ?`,
+		int64(from), sqliteStreamPage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var blocks []*Block
+	byNumber := make(map[uint64]*Block)
+	for rows.Next() {
+		var bs []byte
+		if err := rows.Scan(&bs); err != nil {
+			return nil, err
+		}
+		var pb epb.Block
+		if err := proto.Unmarshal(bs, &pb); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.blocks, err)
+		}
+		b := &Block{
+			Number:     pb.Number,
+			Hash:       common.BytesToHash(pb.Hash),
+			ParentHash: common.BytesToHash(pb.ParentHash),
+			Time:       pb.Time,
+			Meta:       pb.Meta,
+			EventsRoot: common.BytesToHash(pb.EventsRoot),
+		}
+		blocks = append(blocks, b)
+		byNumber[b.Number] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+
+	rows, err = tx.QueryContext(l.ctx, `SELECT block_number, event FROM `+l.events+` WHERE block_number >= ? AND block_number <= ? ORDER BY block_number, log_index`,
+		int64(blocks[0].Number), int64(blocks[len(blocks)-1].Number))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int64
+		var es []byte
+		if err := rows.Scan(&n, &es); err != nil {
+			return nil, err
+		}
+		var pb epb.Event
+		if err := proto.Unmarshal(es, &pb); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.events, err)
+		}
+		e, err := EventFromProto(&pb)
+		if err != nil {
+			return nil, err
+		}
+		b := byNumber[uint64(n)]
+		if b == nil {
+			return nil, fmt.Errorf("%s: event of block %d without block", l.events, n)
+		}
+		b.Events = append(b.Events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if err := b.VerifyEventsRoot(); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
@@ -0,0 +1,200 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// DefaultStateCacheEntries is the default size of the StateReader cache of
+// Static calls.
+const DefaultStateCacheEntries = 1 << 14
+
+// CallClient is implemented by clients that can call contracts, like
+// ethclient.Client.
+type CallClient interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// BatchCallClient is implemented by clients that can make several contract
+// calls in one request. The clients of ethrpc.Dial implement it. The
+// returned slices have one entry per call; an error of one call does not
+// fail the others.
+type BatchCallClient interface {
+	BatchCallContract(ctx context.Context, msgs []ethereum.CallMsg, blockNumber *big.Int) ([][]byte, []error, error)
+}
+
+// StateCall is a contract call made for the events it applies to, e.g. to
+// read the decimals of a token or the reserves of a pool.
+type StateCall struct {
+	// Name keys the results in the block Meta; see StateResult.
+	Name string
+
+	// Call returns the call to make for an event, or false if the event
+	// needs none.
+	Call func(e *Event) (ethereum.CallMsg, bool)
+
+	// Static marks calls whose result does not change between blocks,
+	// like the decimals of a token, so it is cached across blocks.
+	Static bool
+
+	// AllowFailure skips events whose call fails, e.g. reverts, instead of
+	// failing the block.
+	AllowFailure bool
+}
+
+// StateReader enriches events with contract state read at their block, for
+// results that are consistent with the events; a consumer calling at the
+// latest block reads state that may have changed since. Its BlockHook method
+// is meant as the ChainStreamer BlockHook. Calls are made with the state
+// after the block, which is the state an event's transaction left unless
+// later transactions of the block changed it. Old blocks need an archive
+// node.
+//
+// Equal calls in a block are made once, and batched into one request if
+// the client implements BatchCallClient. A StateReader is not safe for
+// concurrent use.
+type StateReader struct {
+	Calls []StateCall
+
+	// MaxCacheEntries is the size of the cache of Static calls; the cache
+	// is dropped when full. DefaultStateCacheEntries if zero.
+	MaxCacheEntries int
+
+	cache map[string][]byte
+}
+
+// StateResult returns the result of a StateCall for the event with the
+// given index, from the Meta of its block.
+func StateResult(b *Block, name string, index uint64) ([]byte, bool) {
+	v, ok := b.Meta[stateMetaKey(name, index)]
+	if !ok {
+		return nil, false
+	}
+	bs, err := hexutil.Decode(v)
+	if err != nil {
+		return nil, false
+	}
+	return bs, true
+}
+
+func stateMetaKey(name string, index uint64) string {
+	return fmt.Sprintf("%s/%d", name, index)
+}
+
+func callKey(msg ethereum.CallMsg) string {
+	to := ""
+	if msg.To != nil {
+		to = msg.To.Hex()
+	}
+	return to + ":" + hexutil.Encode(msg.Data)
+}
+
+// BlockHook makes the calls for the events of a block, and stores the
+// results in its Meta.
+func (r *StateReader) BlockHook(ctx context.Context, client Client, b *Block) error {
+	cc, ok := client.(CallClient)
+	if !ok {
+		return fmt.Errorf("client cannot call contracts")
+	}
+	type result struct {
+		call  int // index in Calls
+		key   string
+		event uint64
+	}
+	var (
+		results []result
+		msgs    []ethereum.CallMsg
+		keys    []string
+		pending = make(map[string]bool)
+		values  = make(map[string][]byte)
+		failed  = make(map[string]error)
+	)
+	for i := range b.Events {
+		e := &b.Events[i]
+		for j, c := range r.Calls {
+			msg, ok := c.Call(e)
+			if !ok {
+				continue
+			}
+			key := callKey(msg)
+			if c.Static {
+				key = "static:" + key
+			}
+			results = append(results, result{call: j, key: key, event: e.Index})
+			if pending[key] {
+				continue
+			}
+			pending[key] = true
+			if v, ok := r.cache[key]; ok && c.Static {
+				values[key] = v
+				continue
+			}
+			msgs = append(msgs, msg)
+			keys = append(keys, key)
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	n := new(big.Int).SetUint64(b.Number)
+	if bc, ok := client.(BatchCallClient); ok && len(msgs) > 1 {
+		vs, errs, err := bc.BatchCallContract(ctx, msgs, n)
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if errs[i] != nil {
+				failed[key] = errs[i]
+				continue
+			}
+			values[key] = vs[i]
+		}
+	} else {
+		for i, msg := range msgs {
+			v, err := cc.CallContract(ctx, msg, n)
+			if err != nil {
+				failed[keys[i]] = err
+				continue
+			}
+			values[keys[i]] = v
+		}
+	}
+
+	if b.Meta == nil {
+		b.Meta = make(map[string]string)
+	}
+	for _, res := range results {
+		c := r.Calls[res.call]
+		if err, ok := failed[res.key]; ok {
+			if c.AllowFailure {
+				continue
+			}
+			return fmt.Errorf("state call %s for event %d/%d: %w", c.Name, b.Number, res.event, err)
+		}
+		v := values[res.key]
+		if c.Static {
+			r.store(res.key, v)
+		}
+		b.Meta[stateMetaKey(c.Name, res.event)] = hexutil.Encode(v)
+	}
+	return nil
+}
+
+func (r *StateReader) store(key string, v []byte) {
+	max := r.MaxCacheEntries
+	if max == 0 {
+		max = DefaultStateCacheEntries
+	}
+	if _, ok := r.cache[key]; ok {
+		return
+	}
+	if r.cache == nil || len(r.cache) >= max {
+		r.cache = make(map[string][]byte)
+	}
+	r.cache[key] = v
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSilence is how long a stream may go without progress before
+// Healthz reports it as stuck.
+const DefaultMaxSilence = 4 * time.Duration(DefaultPollInterval) * time.Second
+
+// StreamStats records the progress of a ChainStreamer. It is safe for
+// concurrent use, so it can be read while the stream runs.
+type StreamStats struct {
+	mu           sync.Mutex
+	next         uint64
+	head         uint64
+	rollbacks    uint64
+	txMissing    uint64
+	started      time.Time
+	lastProgress time.Time
+	nextRate     rateEstimator
+	headRate     rateEstimator
+
+	config        EffectiveConfig
+	overlap       uint64
+	reorgDepths   map[uint64]uint64
+	maxReorgDepth uint64
+}
+
+// StatsSnapshot is a copy of the StreamStats at one point in time.
+type StatsSnapshot struct {
+	Next         uint64    `json:"next"`         // next block to stream
+	Head         uint64    `json:"head"`         // latest chain head seen
+	Lag          uint64    `json:"lag"`          // blocks between next and head
+	Rollbacks    uint64    `json:"rollbacks"`    // rollbacks sent
+	TxMissing    uint64    `json:"txMissing"`    // events without tx details
+	LastProgress time.Time `json:"lastProgress"` // last time a batch was processed
+
+	// Rate is the smoothed rate the stream advances at, in blocks per
+	// second. ETA is the time to catch up with the chain head at that rate,
+	// net of the chain growing meanwhile; it is zero when the stream is
+	// within a fetch batch of head, or the chain grows as fast.
+	Rate float64       `json:"rate"`
+	ETA  time.Duration `json:"eta"`
+
+	BatchOverlap     uint64            `json:"batchOverlap"`     // current overlap in blocks
+	MaxReorgDepth    uint64            `json:"maxReorgDepth"`    // deepest rollback seen
+	ReorgDepths      map[uint64]uint64 `json:"reorgDepths"`      // rollbacks by depth
+	SuggestedOverlap uint64            `json:"suggestedOverlap"` // see SuggestOverlap
+
+	Config EffectiveConfig `json:"config"` // as the stream started
+}
+
+// SuggestOverlap returns the batch overlap to use given the deepest reorg
+// seen: twice its depth, or the current overlap if that is larger. Reorgs
+// deeper than the overlap cannot be detected, so an overlap close to the
+// depth of observed reorgs is a near miss.
+func SuggestOverlap(overlap, maxReorgDepth uint64) uint64 {
+	if 2*maxReorgDepth > overlap {
+		return 2 * maxReorgDepth
+	}
+	return overlap
+}
+
+func (s *StreamStats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := StatsSnapshot{
+		Next:         s.next,
+		Head:         s.head,
+		Rollbacks:    s.rollbacks,
+		TxMissing:    s.txMissing,
+		LastProgress: s.lastProgress,
+		Rate:         s.nextRate.rate,
+
+		BatchOverlap:     s.overlap,
+		MaxReorgDepth:    s.maxReorgDepth,
+		ReorgDepths:      make(map[uint64]uint64, len(s.reorgDepths)),
+		SuggestedOverlap: SuggestOverlap(s.overlap, s.maxReorgDepth),
+
+		Config: s.config,
+	}
+	for d, n := range s.reorgDepths {
+		snap.ReorgDepths[d] = n
+	}
+	if s.head+1 > s.next {
+		snap.Lag = s.head + 1 - s.next
+	}
+	if snap.Lag > s.config.FetchBatchSize {
+		snap.ETA = ETA(snap.Lag, s.nextRate.rate-s.headRate.rate)
+	}
+	return snap
+}
+
+// Healthz returns an error if the stream has been started, but has not made
+// progress for longer than maxSilence (DefaultMaxSilence if zero). It is
+// meant for liveness probes.
+func (s *StreamStats) Healthz(maxSilence time.Duration) error {
+	if maxSilence == 0 {
+		maxSilence = DefaultMaxSilence
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := s.lastProgress
+	if last.Before(s.started) {
+		last = s.started
+	}
+	if last.IsZero() {
+		return nil
+	}
+	if silence := time.Since(last); silence > maxSilence {
+		return fmt.Errorf("no progress for %s (at block %d)", silence.Round(time.Second), s.next)
+	}
+	return nil
+}
+
+// Readyz returns an error until the stream has caught up to within maxLag
+// blocks of the chain head. It is meant for readiness probes.
+func (s *StreamStats) Readyz(maxLag uint64) error {
+	snap := s.Snapshot()
+	if snap.LastProgress.IsZero() {
+		return fmt.Errorf("stream has not made progress yet")
+	}
+	if snap.Lag > maxLag {
+		return fmt.Errorf("got lag=%d; want lag <= %d", snap.Lag, maxLag)
+	}
+	return nil
+}
+
+func (s *StreamStats) start(config EffectiveConfig) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = time.Now()
+	s.config = config
+	s.overlap = config.BatchOverlap
+}
+
+func (s *StreamStats) setOverlap(overlap uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overlap = overlap
+}
+
+func (s *StreamStats) progress(next, head uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.next = next
+	s.head = head
+	s.lastProgress = now
+	s.nextRate.observe(now, next)
+	s.headRate.observe(now, head)
+}
+
+func (s *StreamStats) rollback(depth uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbacks++
+	if s.reorgDepths == nil {
+		s.reorgDepths = make(map[uint64]uint64)
+	}
+	s.reorgDepths[depth]++
+	if depth > s.maxReorgDepth {
+		s.maxReorgDepth = depth
+	}
+}
+
+func (s *StreamStats) addTxMissing(n uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txMissing += n
+}
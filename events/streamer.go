@@ -1,6 +1,7 @@
 package events
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -16,6 +17,15 @@ type Message struct {
 	Action Action
 	Number uint64
 	Block  *Block
+
+	// Dropped holds the blocks a Rollback invalidated, if the streamer
+	// includes them (see ChainStreamer.RollbackBlocks).
+	Dropped []*Block
+
+	// ReplayComplete marks the SetNext message a LiveEventLog sends
+	// between the stored and the chain blocks; see
+	// LiveEventLog.MarkReplayComplete.
+	ReplayComplete bool
 }
 
 type Subscription struct {
@@ -53,3 +63,34 @@ func waitOrDone(done chan struct{}, d time.Duration) error {
 		return nil
 	}
 }
+
+// Apply sends a message to the matching method of a Sink.
+func Apply(s Sink, m *Message) error {
+	t, err := m.Typed()
+	if err != nil {
+		return err
+	}
+	switch t := t.(type) {
+	case AppendMsg:
+		return s.Append(t.Block)
+	case RollbackMsg:
+		if us, ok := s.(UndoSink); ok && t.Dropped != nil {
+			return us.Undo(t.Number, t.Dropped)
+		}
+		return s.Rollback(t.Number)
+	case SetNextMsg:
+		return s.SetNext(t.Number)
+	}
+	return fmt.Errorf("unknown message %T", t)
+}
+
+// Drain applies all messages of a subscription to a Sink. It returns the
+// first error from the Sink, or the error of the subscription.
+func Drain(sub *Subscription, s Sink) error {
+	for m := range sub.C {
+		if err := Apply(s, m); err != nil {
+			return err
+		}
+	}
+	return <-sub.Err
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// TieredEventLog keeps the most recent blocks of an eventlog in memory, for
+// fast streaming and rollbacks, and moves older blocks to a cold EventLog,
+// typically a persistent one. It streams from both as a single eventlog.
+//
+// Like InMemoryEventLog, it must not be written to concurrently.
+type TieredEventLog struct {
+	hot    *InMemoryEventLog
+	cold   EventLog
+	window uint64
+}
+
+// NewTieredEventLog returns an eventlog continuing cold, keeping at least
+// the last window blocks in memory.
+func NewTieredEventLog(cold EventLog, window uint64) *TieredEventLog {
+	return &TieredEventLog{
+		hot:    NewInMemoryEventLog(cold.NextBlock(), cold.Filter()),
+		cold:   cold,
+		window: window,
+	}
+}
+
+func (l *TieredEventLog) FirstBlock() uint64 {
+	if first := l.cold.FirstBlock(); first < l.cold.NextBlock() {
+		return first
+	}
+	return l.hot.FirstBlock()
+}
+
+func (l *TieredEventLog) NextBlock() uint64 {
+	return l.hot.NextBlock()
+}
+
+func (l *TieredEventLog) Filter() ethereum.FilterQuery {
+	return l.cold.Filter()
+}
+
+func (l *TieredEventLog) Append(b *Block) error {
+	if err := l.hot.Append(b); err != nil {
+		return err
+	}
+	return l.migrate()
+}
+
+// Rollback rolls back the hot blocks, and the cold ones if n is before the
+// window.
+func (l *TieredEventLog) Rollback(n uint64) error {
+	if n >= l.hot.FirstBlock() {
+		return l.hot.Rollback(n)
+	}
+	if err := l.cold.Rollback(n); err != nil {
+		return err
+	}
+	l.hot.Reset(n)
+	return nil
+}
+
+func (l *TieredEventLog) SetNext(n uint64) error {
+	if err := l.hot.SetNext(n); err != nil {
+		return err
+	}
+	return l.migrate()
+}
+
+// CanPrune reports whether the cold eventlog supports Prune.
+func (l *TieredEventLog) CanPrune() bool {
+	return CanPrune(l.cold)
+}
+
+// Prune prunes the cold eventlog, and the hot blocks if before is within
+// the window. The cold eventlog then ends where the hot blocks start, empty
+// if all its blocks were pruned.
+func (l *TieredEventLog) Prune(before uint64) error {
+	if !l.CanPrune() {
+		return ErrPruneUnsupported
+	}
+	if before <= l.hot.FirstBlock() {
+		return l.cold.Prune(before)
+	}
+	if err := l.hot.Prune(before); err != nil {
+		return err
+	}
+	first := l.hot.FirstBlock()
+	if err := l.cold.SetNext(first); err != nil {
+		return err
+	}
+	return l.cold.Prune(first)
+}
+
+// migrate moves the blocks before the window to the cold eventlog.
+func (l *TieredEventLog) migrate() error {
+	next := l.hot.NextBlock()
+	if next-l.hot.FirstBlock() <= 2*l.window {
+		return nil
+	}
+	boundary := next - l.window
+	for _, b := range l.hot.blockSlice.Blocks {
+		if b.Number >= boundary {
+			break
+		}
+		if err := l.cold.Append(b); err != nil {
+			return err
+		}
+	}
+	if err := l.cold.SetNext(boundary); err != nil {
+		return err
+	}
+	return l.hot.Prune(boundary)
+}
+
+// Stream streams the cold blocks from the cold eventlog, and the rest from
+// memory.
+func (l *TieredEventLog) Stream(done chan struct{}, from uint64) (*Subscription, error) {
+	if first := l.FirstBlock(); from < first {
+		return nil, &RangeError{Op: "Stream", N: from, Min: first, Max: l.NextBlock()}
+	}
+	c := make(chan *Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		err := l.stream(c, done, from)
+		close(c)
+		errc <- err
+	}()
+
+	return &Subscription{C: c, Err: errc, Done: done}, nil
+}
+
+func (l *TieredEventLog) stream(c chan *Message, done chan struct{}, from uint64) error {
+	next := from
+	for next < l.hot.FirstBlock() {
+		start := next
+		sub, err := l.cold.Stream(done, next)
+		if err != nil {
+			return err
+		}
+		for m := range sub.C {
+			if err := sendOrDone(c, done, m); err != nil {
+				return err
+			}
+			switch m.Action {
+			case Append:
+				next = m.Block.Number + 1
+			default:
+				next = m.Number
+			}
+		}
+		if err := <-sub.Err; err != nil {
+			return err
+		}
+		if next == start {
+			return fmt.Errorf("cold eventlog streamed nothing from block %d; want blocks up to %d", start, l.hot.FirstBlock())
+		}
+	}
+	sub, err := l.hot.Stream(done, next)
+	if err != nil {
+		return err
+	}
+	for m := range sub.C {
+		if err := sendOrDone(c, done, m); err != nil {
+			return err
+		}
+	}
+	return <-sub.Err
+}
+
+func (l *TieredEventLog) Close() error {
+	return l.cold.Close()
+}
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// LogTimestampClient is implemented by clients that report the
+// blockTimestamp field some providers include in logs. The clients of
+// ethrpc.Dial implement it.
+type LogTimestampClient interface {
+	// FilterLogsTimestamps is FilterLogs, also returning the block
+	// timestamps found in the logs, by block hash.
+	FilterLogsTimestamps(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, map[common.Hash]uint64, error)
+}
+
+// filterLogs calls FilterLogs, or FilterLogsTimestamps if the client
+// implements LogTimestampClient, adding the timestamps found to times.
+func filterLogs(ctx context.Context, client Client, q ethereum.FilterQuery, times map[common.Hash]uint64) ([]types.Log, error) {
+	tc, ok := client.(LogTimestampClient)
+	if !ok {
+		return client.FilterLogs(ctx, q)
+	}
+	logs, ts, err := tc.FilterLogsTimestamps(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for h, t := range ts {
+		times[h] = t
+	}
+	return logs, nil
+}
+
+// AddBlockTimestamps sets the Time of every block in a BlockSlice that does
+// not have it yet from the logs, by fetching its header.
+func AddBlockTimestamps(ctx context.Context, client Client, bs *BlockSlice) error {
+	for _, b := range bs.Blocks {
+		if b.Time != 0 {
+			continue
+		}
+		h, err := client.HeaderByHash(ctx, b.Hash)
+		if err != nil {
+			return err
+		}
+		b.Time = h.Time
+	}
+	return nil
+}
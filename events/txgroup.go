@@ -0,0 +1,27 @@
+package events
+
+import "github.com/ethereum/go-ethereum/common"
+
+// TxGroup holds the events of one transaction within a block.
+type TxGroup struct {
+	TxHash common.Hash
+	Events []Event
+}
+
+// TxGroups groups the block's events by transaction, in block order. The
+// Events of each group are subslices of b.Events, not copies.
+func (b *Block) TxGroups() []TxGroup {
+	var groups []TxGroup
+	start := 0
+	for i := 1; i <= len(b.Events); i++ {
+		if i < len(b.Events) && b.Events[i].TxHash == b.Events[start].TxHash {
+			continue
+		}
+		groups = append(groups, TxGroup{
+			TxHash: b.Events[start].TxHash,
+			Events: b.Events[start:i:i],
+		})
+		start = i
+	}
+	return groups
+}
@@ -0,0 +1,69 @@
+package events
+
+import "fmt"
+
+// TypedMessage is a Message as one of its variants: AppendMsg, RollbackMsg
+// or SetNextMsg. A type switch over the variants covers every action, and
+// no variant has fields that do not apply to it. The interface is sealed,
+// so no other types implement it.
+//
+// Streams still carry *Message; Typed and TypedMessage.Message convert
+// between the two.
+type TypedMessage interface {
+	// Message returns the variant as a Message.
+	Message() *Message
+
+	typedMessage()
+}
+
+// AppendMsg appends a block.
+type AppendMsg struct {
+	Block *Block
+}
+
+// RollbackMsg removes the blocks from Number on. Dropped holds the removed
+// blocks, if the streamer includes them.
+type RollbackMsg struct {
+	Number  uint64
+	Dropped []*Block
+}
+
+// SetNextMsg sets the next block number without a block. ReplayComplete
+// marks the end of the stored blocks of a LiveEventLog.
+type SetNextMsg struct {
+	Number         uint64
+	ReplayComplete bool
+}
+
+func (m AppendMsg) Message() *Message {
+	return &Message{Action: Append, Block: m.Block}
+}
+
+func (m RollbackMsg) Message() *Message {
+	return &Message{Action: Rollback, Number: m.Number, Dropped: m.Dropped}
+}
+
+func (m SetNextMsg) Message() *Message {
+	return &Message{Action: SetNext, Number: m.Number, ReplayComplete: m.ReplayComplete}
+}
+
+func (AppendMsg) typedMessage()   {}
+func (RollbackMsg) typedMessage() {}
+func (SetNextMsg) typedMessage()  {}
+
+// Typed returns the message as its TypedMessage variant. It fails for
+// unknown actions and Append messages without a block.
+func (m *Message) Typed() (TypedMessage, error) {
+	switch m.Action {
+	case Append:
+		if m.Block == nil {
+			return nil, fmt.Errorf("got Append message without block")
+		}
+		return AppendMsg{Block: m.Block}, nil
+	case Rollback:
+		return RollbackMsg{Number: m.Number, Dropped: m.Dropped}, nil
+	case SetNext:
+		return SetNextMsg{Number: m.Number, ReplayComplete: m.ReplayComplete}, nil
+	}
+	return nil, fmt.Errorf("unknown action %d", m.Action)
+}
@@ -0,0 +1,95 @@
+// Package eventtest provides helpers for testing code that produces or
+// consumes event streams.
+package eventtest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// UpdateEnv is the environment variable that, when set to 1, makes
+// AssertGolden rewrite golden files instead of comparing with them. It is
+// not a flag, so that tests can still declare their own -update.
+const UpdateEnv = "EVENTTEST_UPDATE"
+
+// Render drains a subscription into text: each Append renders the block with
+// its events decoded by d (which may be nil), other messages one per line.
+func Render(d *decode.Decoder, sub *events.Subscription) ([]byte, error) {
+	if d == nil {
+		d = decode.NewDecoder()
+	}
+	var buf bytes.Buffer
+	for m := range sub.C {
+		if m.Action == events.Append {
+			buf.WriteString(d.FormatBlock(m.Block, true))
+			continue
+		}
+		buf.WriteString(m.String() + "\n")
+	}
+	return buf.Bytes(), <-sub.Err
+}
+
+// RenderStream streams from a Streamer and renders the messages with Render.
+func RenderStream(d *decode.Decoder, s events.Streamer, from uint64) ([]byte, error) {
+	done := make(chan struct{})
+	defer close(done)
+	sub, err := s.Stream(done, from)
+	if err != nil {
+		return nil, err
+	}
+	return Render(d, sub)
+}
+
+// AssertGolden compares got with the contents of a golden file, and reports
+// the first differing line. Run the test with EVENTTEST_UPDATE=1 to write
+// got to the file instead.
+func AssertGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with %s=1 to create it)", err, UpdateEnv)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gl := strings.Split(string(got), "\n")
+	wl := strings.Split(string(want), "\n")
+	for i := 0; i < len(gl) || i < len(wl); i++ {
+		var g, w string
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if g != w {
+			t.Fatalf("%s:%d: got %q; want %q", path, i+1, g, w)
+		}
+	}
+}
+
+// AssertStreamGolden renders a stream with RenderStream and compares it with
+// a golden file using AssertGolden.
+func AssertStreamGolden(t testing.TB, path string, d *decode.Decoder, s events.Streamer, from uint64) {
+	t.Helper()
+	got, err := RenderStream(d, s, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertGolden(t, path, got)
+}
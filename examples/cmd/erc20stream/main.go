@@ -15,6 +15,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/jcjlcodes/eth-eventlog/events"
+	_ "github.com/jcjlcodes/eth-eventlog/events/ethrpc"
 )
 
 var nodeFlag = flag.String("node", "", "Ethereum JSON-RPC node url")
@@ -73,12 +74,9 @@ func run() error {
 	for m := range sub.C {
 		switch m.Action {
 		case events.Append:
-			file.WriteString(fmt.Sprintf("Block %d\n", m.Block.Number))
-			for _, ev := range m.Block.Events {
-				file.WriteString(fmt.Sprintf("  %d/%d %s\n", ev.BlockNumber, ev.Index, ev.BlockHash.Hex()))
-			}
+			file.WriteString(events.FormatBlock(m.Block, false))
 		case events.Rollback:
-			file.WriteString(fmt.Sprintf("Rollback %d\n", m.Number))
+			file.WriteString(m.String() + "\n")
 			endAt = m.Number + 20
 		}
 		if endAt != 0 && eventlog.NextBlock() >= endAt {
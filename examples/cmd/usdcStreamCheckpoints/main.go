@@ -16,6 +16,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/jcjlcodes/eth-eventlog/events"
+	_ "github.com/jcjlcodes/eth-eventlog/events/ethrpc"
 )
 
 var nodeFlag = flag.String("node", "", "Ethereum JSON-RPC node url")
@@ -83,14 +84,7 @@ func run() error {
 	for m := range sub.C {
 		switch m.Action {
 		case events.Append:
-			file.WriteString(fmt.Sprintf("Block %d\n", m.Block.Number))
-			for _, ev := range m.Block.Events {
-				if *txFlag {
-					file.WriteString(fmt.Sprintf("  %d/%d (%d bytes) %s\n", ev.BlockNumber, ev.Index, len(ev.TxData), ev.TxFrom.Hex()))
-				} else {
-					file.WriteString(fmt.Sprintf("  %d/%d %s\n", ev.BlockNumber, ev.Index, ev.BlockHash.Hex()))
-				}
-			}
+			file.WriteString(events.FormatBlock(m.Block, *txFlag))
 
 			if m.Block.Number > lastCheckpoint+10 {
 				if err := saveProto(
@@ -101,10 +95,8 @@ func run() error {
 				lastCheckpoint = m.Block.Number
 			}
 
-		case events.Rollback:
-			file.WriteString(fmt.Sprintf("Rollback %d\n", m.Number))
-		case events.SetNext:
-			file.WriteString(fmt.Sprintf("SetNext %d\n", m.Number))
+		case events.Rollback, events.SetNext:
+			file.WriteString(m.String() + "\n")
 		}
 	}
 	if err := <-sub.Err; err != nil {
@@ -0,0 +1,72 @@
+// Package leader lets replicas of an indexer run hot/standby: only the
+// replica holding the leadership streams and writes to the sinks, and a
+// standby takes over when the leader dies.
+//
+// An Elector wraps a lock service. This package implements one with a
+// Postgres advisory lock; others, e.g. on etcd's concurrency.Election, take
+// a few lines:
+//
+//	func (e *etcdElector) Campaign(ctx context.Context) (context.Context, error) {
+//		if err := e.election.Campaign(ctx, e.name); err != nil {
+//			return nil, err
+//		}
+//		lost, cancel := context.WithCancel(ctx)
+//		go func() {
+//			select {
+//			case <-e.session.Done():
+//			case <-lost.Done():
+//			}
+//			cancel()
+//		}()
+//		return lost, nil
+//	}
+package leader
+
+import (
+	"context"
+	"log"
+)
+
+// Elector elects one leader among replicas.
+type Elector interface {
+	// Campaign blocks until this replica is the leader, or ctx is done.
+	// The returned context is canceled when the leadership is lost.
+	Campaign(ctx context.Context) (context.Context, error)
+
+	// Resign gives up the leadership.
+	Resign(ctx context.Context) error
+}
+
+// Run calls fn whenever this replica is the leader, with a context that is
+// canceled when the leadership is lost, until ctx is done or fn returns
+// while still leading. fn should resume from the shared state of the last
+// leader, e.g. load the latest checkpoint, as another replica may have led
+// in between:
+//
+//	leader.Run(ctx, elector, func(ctx context.Context) error {
+//		p, err := cfg.Build(ctx) // loads the checkpoint
+//		if err != nil {
+//			return err
+//		}
+//		done := make(chan struct{})
+//		go func() { <-ctx.Done(); close(done) }()
+//		return p.Run(done)
+//	})
+func Run(ctx context.Context, e Elector, fn func(ctx context.Context) error) error {
+	for {
+		lead, err := e.Campaign(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("elected leader")
+		err = fn(lead)
+		if lead.Err() == nil || ctx.Err() != nil {
+			if rerr := e.Resign(context.Background()); rerr != nil && err == nil {
+				err = rerr
+			}
+			return err
+		}
+		log.Printf("lost leadership (%v); campaigning again", err)
+		e.Resign(context.Background())
+	}
+}
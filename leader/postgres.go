@@ -0,0 +1,107 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DefaultLockInterval is the default interval of a PostgresLock between
+// attempts to take the lock, and between checks that it is still held.
+const DefaultLockInterval = 5 * time.Second
+
+// PostgresLock is an Elector holding a session level Postgres advisory
+// lock. The lock lives on one connection of DB, and is released by Postgres
+// when that connection dies, so a standby takes over from a crashed leader.
+// The program imports a Postgres driver for DB.
+//
+// There is no fencing token: the leader checks every Interval that it still
+// holds the lock, and cancels its context as soon as a check fails or takes
+// longer than Interval. Postgres must take longer than that to drop a
+// broken connection, e.g. with tcp_keepalives_idle well above Interval, so
+// that a standby cannot lead while the old leader still writes.
+type PostgresLock struct {
+	DB       *sql.DB
+	Key      int64         // advisory lock key, the same for all replicas
+	Interval time.Duration // DefaultLockInterval if zero
+
+	conn   *sql.Conn
+	cancel context.CancelFunc
+}
+
+func NewPostgresLock(db *sql.DB, key int64) *PostgresLock {
+	return &PostgresLock{DB: db, Key: key}
+}
+
+func (l *PostgresLock) interval() time.Duration {
+	if l.Interval == 0 {
+		return DefaultLockInterval
+	}
+	return l.Interval
+}
+
+func (l *PostgresLock) Campaign(ctx context.Context) (context.Context, error) {
+	conn, err := l.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var ok bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.Key).Scan(&ok); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(l.interval()):
+		}
+	}
+
+	lead, cancel := context.WithCancel(ctx)
+	l.conn, l.cancel = conn, cancel
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-lead.Done():
+				return
+			case <-time.After(l.interval()):
+			}
+			if !l.held(lead, conn) {
+				return
+			}
+		}
+	}()
+	return lead, nil
+}
+
+// held reports whether the connection still holds the lock, answering
+// within the interval.
+func (l *PostgresLock) held(ctx context.Context, conn *sql.Conn) bool {
+	ctx, cancel := context.WithTimeout(ctx, l.interval())
+	defer cancel()
+	var ok bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND granted
+		AND classid = (($1::bigint >> 32) & 4294967295)::oid
+		AND objid = ($1::bigint & 4294967295)::oid AND objsubid = 1)`, l.Key).Scan(&ok)
+	return err == nil && ok
+}
+
+func (l *PostgresLock) Resign(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	l.cancel()
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.Key)
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	l.conn = nil
+	return err
+}
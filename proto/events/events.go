@@ -0,0 +1,34 @@
+// Package events aliases the messages of the versioned package
+// github.com/jcjlcodes/eth-eventlog/proto/events/v1, for code written before
+// the proto definitions were versioned.
+//
+// Deprecated: import proto/events/v1 instead.
+package events
+
+import eventsv1 "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+
+type (
+	Event             = eventsv1.Event
+	Block             = eventsv1.Block
+	BlockSlice        = eventsv1.BlockSlice
+	FilterQuery       = eventsv1.FilterQuery
+	FilterQuery_Topic = eventsv1.FilterQuery_Topic
+	BlockRange        = eventsv1.BlockRange
+	RangeManifest     = eventsv1.RangeManifest
+	EventLogFile      = eventsv1.EventLogFile
+	Message           = eventsv1.Message
+	Message_Action    = eventsv1.Message_Action
+	EventLogDelta     = eventsv1.EventLogDelta
+	WALEntry          = eventsv1.WALEntry
+)
+
+const (
+	Message_APPEND   = eventsv1.Message_APPEND
+	Message_ROLLBACK = eventsv1.Message_ROLLBACK
+	Message_SET_NEXT = eventsv1.Message_SET_NEXT
+)
+
+var (
+	Message_Action_name  = eventsv1.Message_Action_name
+	Message_Action_value = eventsv1.Message_Action_value
+)
@@ -1,669 +0,0 @@
-// Code generated by protoc-gen-go. DO NOT EDIT.
-// versions:
-// 	protoc-gen-go v1.27.1
-// 	protoc        v3.17.3
-// source: events.proto
-
-package events
-
-import (
-	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
-	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
-	reflect "reflect"
-	sync "sync"
-)
-
-const (
-	// Verify that this generated code is sufficiently up-to-date.
-	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
-	// Verify that runtime/protoimpl is sufficiently up-to-date.
-	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
-)
-
-// 	TxHash  common.Hash
-// 	TxIndex uint64 // index of tx in block
-// 	TxData  []byte
-// 	TxValue *big.Int
-// 	TxFrom  common.Address
-// 	TxGas   uint64
-// }
-type Event struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Address     []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
-	Topics      [][]byte `protobuf:"bytes,2,rep,name=topics,proto3" json:"topics,omitempty"`
-	Data        []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
-	BlockNumber uint64   `protobuf:"varint,4,opt,name=block_number,json=blockNumber,proto3" json:"block_number,omitempty"`
-	BlockHash   []byte   `protobuf:"bytes,5,opt,name=block_hash,json=blockHash,proto3" json:"block_hash,omitempty"`
-	Index       uint64   `protobuf:"varint,6,opt,name=index,proto3" json:"index,omitempty"`
-	TxHash      []byte   `protobuf:"bytes,7,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
-	TxIndex     uint64   `protobuf:"varint,8,opt,name=tx_index,json=txIndex,proto3" json:"tx_index,omitempty"`
-	TxData      []byte   `protobuf:"bytes,9,opt,name=tx_data,json=txData,proto3" json:"tx_data,omitempty"`
-	TxValue     string   `protobuf:"bytes,10,opt,name=tx_value,json=txValue,proto3" json:"tx_value,omitempty"` // decimal, or hex with 0x prefix
-	TxFrom      []byte   `protobuf:"bytes,11,opt,name=tx_from,json=txFrom,proto3" json:"tx_from,omitempty"`
-	TxGas       uint64   `protobuf:"varint,12,opt,name=tx_gas,json=txGas,proto3" json:"tx_gas,omitempty"`
-}
-
-func (x *Event) Reset() {
-	*x = Event{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[0]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *Event) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*Event) ProtoMessage() {}
-
-func (x *Event) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use Event.ProtoReflect.Descriptor instead.
-func (*Event) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{0}
-}
-
-func (x *Event) GetAddress() []byte {
-	if x != nil {
-		return x.Address
-	}
-	return nil
-}
-
-func (x *Event) GetTopics() [][]byte {
-	if x != nil {
-		return x.Topics
-	}
-	return nil
-}
-
-func (x *Event) GetData() []byte {
-	if x != nil {
-		return x.Data
-	}
-	return nil
-}
-
-func (x *Event) GetBlockNumber() uint64 {
-	if x != nil {
-		return x.BlockNumber
-	}
-	return 0
-}
-
-func (x *Event) GetBlockHash() []byte {
-	if x != nil {
-		return x.BlockHash
-	}
-	return nil
-}
-
-func (x *Event) GetIndex() uint64 {
-	if x != nil {
-		return x.Index
-	}
-	return 0
-}
-
-func (x *Event) GetTxHash() []byte {
-	if x != nil {
-		return x.TxHash
-	}
-	return nil
-}
-
-func (x *Event) GetTxIndex() uint64 {
-	if x != nil {
-		return x.TxIndex
-	}
-	return 0
-}
-
-func (x *Event) GetTxData() []byte {
-	if x != nil {
-		return x.TxData
-	}
-	return nil
-}
-
-func (x *Event) GetTxValue() string {
-	if x != nil {
-		return x.TxValue
-	}
-	return ""
-}
-
-func (x *Event) GetTxFrom() []byte {
-	if x != nil {
-		return x.TxFrom
-	}
-	return nil
-}
-
-func (x *Event) GetTxGas() uint64 {
-	if x != nil {
-		return x.TxGas
-	}
-	return 0
-}
-
-// type Block struct {
-// 	Number uint64
-// 	Hash   common.Hash
-// 	Events []Event
-// }
-type Block struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Number uint64   `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
-	Hash   []byte   `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
-	Events []*Event `protobuf:"bytes,3,rep,name=events,proto3" json:"events,omitempty"`
-}
-
-func (x *Block) Reset() {
-	*x = Block{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[1]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *Block) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*Block) ProtoMessage() {}
-
-func (x *Block) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use Block.ProtoReflect.Descriptor instead.
-func (*Block) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{1}
-}
-
-func (x *Block) GetNumber() uint64 {
-	if x != nil {
-		return x.Number
-	}
-	return 0
-}
-
-func (x *Block) GetHash() []byte {
-	if x != nil {
-		return x.Hash
-	}
-	return nil
-}
-
-func (x *Block) GetEvents() []*Event {
-	if x != nil {
-		return x.Events
-	}
-	return nil
-}
-
-type BlockSlice struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Start            uint64   `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
-	End              uint64   `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
-	DistanceFromHead uint64   `protobuf:"varint,3,opt,name=distance_from_head,json=distanceFromHead,proto3" json:"distance_from_head,omitempty"`
-	Blocks           []*Block `protobuf:"bytes,4,rep,name=blocks,proto3" json:"blocks,omitempty"`
-}
-
-func (x *BlockSlice) Reset() {
-	*x = BlockSlice{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[2]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *BlockSlice) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*BlockSlice) ProtoMessage() {}
-
-func (x *BlockSlice) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use BlockSlice.ProtoReflect.Descriptor instead.
-func (*BlockSlice) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{2}
-}
-
-func (x *BlockSlice) GetStart() uint64 {
-	if x != nil {
-		return x.Start
-	}
-	return 0
-}
-
-func (x *BlockSlice) GetEnd() uint64 {
-	if x != nil {
-		return x.End
-	}
-	return 0
-}
-
-func (x *BlockSlice) GetDistanceFromHead() uint64 {
-	if x != nil {
-		return x.DistanceFromHead
-	}
-	return 0
-}
-
-func (x *BlockSlice) GetBlocks() []*Block {
-	if x != nil {
-		return x.Blocks
-	}
-	return nil
-}
-
-type FilterQuery struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Addresses [][]byte             `protobuf:"bytes,1,rep,name=addresses,proto3" json:"addresses,omitempty"`
-	FromBlock string               `protobuf:"bytes,2,opt,name=from_block,json=fromBlock,proto3" json:"from_block,omitempty"` // may be <nil>
-	ToBlock   string               `protobuf:"bytes,3,opt,name=to_block,json=toBlock,proto3" json:"to_block,omitempty"`       // may be <nil>
-	Topics    []*FilterQuery_Topic `protobuf:"bytes,4,rep,name=topics,proto3" json:"topics,omitempty"`
-}
-
-func (x *FilterQuery) Reset() {
-	*x = FilterQuery{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[3]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *FilterQuery) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*FilterQuery) ProtoMessage() {}
-
-func (x *FilterQuery) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use FilterQuery.ProtoReflect.Descriptor instead.
-func (*FilterQuery) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *FilterQuery) GetAddresses() [][]byte {
-	if x != nil {
-		return x.Addresses
-	}
-	return nil
-}
-
-func (x *FilterQuery) GetFromBlock() string {
-	if x != nil {
-		return x.FromBlock
-	}
-	return ""
-}
-
-func (x *FilterQuery) GetToBlock() string {
-	if x != nil {
-		return x.ToBlock
-	}
-	return ""
-}
-
-func (x *FilterQuery) GetTopics() []*FilterQuery_Topic {
-	if x != nil {
-		return x.Topics
-	}
-	return nil
-}
-
-type EventLogFile struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Filter     *FilterQuery `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
-	BlockSlice *BlockSlice  `protobuf:"bytes,2,opt,name=block_slice,json=blockSlice,proto3" json:"block_slice,omitempty"`
-}
-
-func (x *EventLogFile) Reset() {
-	*x = EventLogFile{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[4]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *EventLogFile) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*EventLogFile) ProtoMessage() {}
-
-func (x *EventLogFile) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[4]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use EventLogFile.ProtoReflect.Descriptor instead.
-func (*EventLogFile) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{4}
-}
-
-func (x *EventLogFile) GetFilter() *FilterQuery {
-	if x != nil {
-		return x.Filter
-	}
-	return nil
-}
-
-func (x *EventLogFile) GetBlockSlice() *BlockSlice {
-	if x != nil {
-		return x.BlockSlice
-	}
-	return nil
-}
-
-type FilterQuery_Topic struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Data [][]byte `protobuf:"bytes,1,rep,name=data,proto3" json:"data,omitempty"`
-}
-
-func (x *FilterQuery_Topic) Reset() {
-	*x = FilterQuery_Topic{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_events_proto_msgTypes[5]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
-}
-
-func (x *FilterQuery_Topic) String() string {
-	return protoimpl.X.MessageStringOf(x)
-}
-
-func (*FilterQuery_Topic) ProtoMessage() {}
-
-func (x *FilterQuery_Topic) ProtoReflect() protoreflect.Message {
-	mi := &file_events_proto_msgTypes[5]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
-	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use FilterQuery_Topic.ProtoReflect.Descriptor instead.
-func (*FilterQuery_Topic) Descriptor() ([]byte, []int) {
-	return file_events_proto_rawDescGZIP(), []int{3, 0}
-}
-
-func (x *FilterQuery_Topic) GetData() [][]byte {
-	if x != nil {
-		return x.Data
-	}
-	return nil
-}
-
-var File_events_proto protoreflect.FileDescriptor
-
-var file_events_proto_rawDesc = []byte{
-	0x0a, 0x0c, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06,
-	0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x22, 0xbd, 0x02, 0x0a, 0x05, 0x45, 0x76, 0x65, 0x6e, 0x74,
-	0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f,
-	0x70, 0x69, 0x63, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69,
-	0x63, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x21, 0x0a, 0x0c, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
-	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x62, 0x6c,
-	0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x62, 0x6c, 0x6f,
-	0x63, 0x6b, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x62,
-	0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65,
-	0x78, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x17,
-	0x0a, 0x07, 0x74, 0x78, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52,
-	0x06, 0x74, 0x78, 0x48, 0x61, 0x73, 0x68, 0x12, 0x19, 0x0a, 0x08, 0x74, 0x78, 0x5f, 0x69, 0x6e,
-	0x64, 0x65, 0x78, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x74, 0x78, 0x49, 0x6e, 0x64,
-	0x65, 0x78, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x78, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x09, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x78, 0x44, 0x61, 0x74, 0x61, 0x12, 0x19, 0x0a, 0x08, 0x74,
-	0x78, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74,
-	0x78, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x74, 0x78, 0x5f, 0x66, 0x72, 0x6f,
-	0x6d, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x78, 0x46, 0x72, 0x6f, 0x6d, 0x12,
-	0x15, 0x0a, 0x06, 0x74, 0x78, 0x5f, 0x67, 0x61, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x05, 0x74, 0x78, 0x47, 0x61, 0x73, 0x22, 0x5a, 0x0a, 0x05, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12,
-	0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x25, 0x0a, 0x06, 0x65,
-	0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x65, 0x76,
-	0x65, 0x6e, 0x74, 0x73, 0x2e, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x52, 0x06, 0x65, 0x76, 0x65, 0x6e,
-	0x74, 0x73, 0x22, 0x89, 0x01, 0x0a, 0x0a, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x6c, 0x69, 0x63,
-	0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x05, 0x73, 0x74, 0x61, 0x72, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x65, 0x6e, 0x64, 0x12, 0x2c, 0x0a, 0x12, 0x64, 0x69, 0x73,
-	0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x46,
-	0x72, 0x6f, 0x6d, 0x48, 0x65, 0x61, 0x64, 0x12, 0x25, 0x0a, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
-	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73,
-	0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x22, 0xb5,
-	0x01, 0x0a, 0x0b, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x1c,
-	0x0a, 0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
-	0x0c, 0x52, 0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x1d, 0x0a, 0x0a,
-	0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x09, 0x66, 0x72, 0x6f, 0x6d, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x19, 0x0a, 0x08, 0x74,
-	0x6f, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74,
-	0x6f, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x31, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73,
-	0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x2e,
-	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x51, 0x75, 0x65, 0x72, 0x79, 0x2e, 0x54, 0x6f, 0x70, 0x69,
-	0x63, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x1a, 0x1b, 0x0a, 0x05, 0x54, 0x6f, 0x70,
-	0x69, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c,
-	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x70, 0x0a, 0x0c, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4c,
-	0x6f, 0x67, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x2b, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x2e,
-	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x06, 0x66, 0x69, 0x6c,
-	0x74, 0x65, 0x72, 0x12, 0x33, 0x0a, 0x0b, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x73, 0x6c, 0x69,
-	0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x65, 0x76, 0x65, 0x6e, 0x74,
-	0x73, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x0a, 0x62, 0x6c,
-	0x6f, 0x63, 0x6b, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x42, 0x0e, 0x5a, 0x0c, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
-}
-
-var (
-	file_events_proto_rawDescOnce sync.Once
-	file_events_proto_rawDescData = file_events_proto_rawDesc
-)
-
-func file_events_proto_rawDescGZIP() []byte {
-	file_events_proto_rawDescOnce.Do(func() {
-		file_events_proto_rawDescData = protoimpl.X.CompressGZIP(file_events_proto_rawDescData)
-	})
-	return file_events_proto_rawDescData
-}
-
-var file_events_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
-var file_events_proto_goTypes = []interface{}{
-	(*Event)(nil),             // 0: events.Event
-	(*Block)(nil),             // 1: events.Block
-	(*BlockSlice)(nil),        // 2: events.BlockSlice
-	(*FilterQuery)(nil),       // 3: events.FilterQuery
-	(*EventLogFile)(nil),      // 4: events.EventLogFile
-	(*FilterQuery_Topic)(nil), // 5: events.FilterQuery.Topic
-}
-var file_events_proto_depIdxs = []int32{
-	0, // 0: events.Block.events:type_name -> events.Event
-	1, // 1: events.BlockSlice.blocks:type_name -> events.Block
-	5, // 2: events.FilterQuery.topics:type_name -> events.FilterQuery.Topic
-	3, // 3: events.EventLogFile.filter:type_name -> events.FilterQuery
-	2, // 4: events.EventLogFile.block_slice:type_name -> events.BlockSlice
-	5, // [5:5] is the sub-list for method output_type
-	5, // [5:5] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
-}
-
-func init() { file_events_proto_init() }
-func file_events_proto_init() {
-	if File_events_proto != nil {
-		return
-	}
-	if !protoimpl.UnsafeEnabled {
-		file_events_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Event); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_events_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Block); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_events_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BlockSlice); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_events_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FilterQuery); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_events_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*EventLogFile); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_events_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FilterQuery_Topic); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-	}
-	type x struct{}
-	out := protoimpl.TypeBuilder{
-		File: protoimpl.DescBuilder{
-			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_events_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   6,
-			NumExtensions: 0,
-			NumServices:   0,
-		},
-		GoTypes:           file_events_proto_goTypes,
-		DependencyIndexes: file_events_proto_depIdxs,
-		MessageInfos:      file_events_proto_msgTypes,
-	}.Build()
-	File_events_proto = out.File
-	file_events_proto_rawDesc = nil
-	file_events_proto_goTypes = nil
-	file_events_proto_depIdxs = nil
-}
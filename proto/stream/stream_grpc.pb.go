@@ -0,0 +1,244 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package stream
+
+import (
+	context "context"
+	v1 "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// EventStreamClient is the client API for EventStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventStreamClient interface {
+	// Subscribe streams messages starting at from_block.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (EventStream_SubscribeClient, error)
+	// Control streams messages like Subscribe, while letting the client
+	// steer delivery. The first request must be a START command.
+	Control(ctx context.Context, opts ...grpc.CallOption) (EventStream_ControlClient, error)
+	// Ack stores the position of a named consumer. A later subscription of
+	// the same consumer resumes from there.
+	Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error)
+}
+
+type eventStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventStreamClient(cc grpc.ClientConnInterface) EventStreamClient {
+	return &eventStreamClient{cc}
+}
+
+func (c *eventStreamClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (EventStream_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EventStream_ServiceDesc.Streams[0], "/stream.EventStream/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventStreamSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventStream_SubscribeClient interface {
+	Recv() (*v1.Message, error)
+	grpc.ClientStream
+}
+
+type eventStreamSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventStreamSubscribeClient) Recv() (*v1.Message, error) {
+	m := new(v1.Message)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventStreamClient) Control(ctx context.Context, opts ...grpc.CallOption) (EventStream_ControlClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EventStream_ServiceDesc.Streams[1], "/stream.EventStream/Control", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventStreamControlClient{stream}
+	return x, nil
+}
+
+type EventStream_ControlClient interface {
+	Send(*ControlRequest) error
+	Recv() (*v1.Message, error)
+	grpc.ClientStream
+}
+
+type eventStreamControlClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventStreamControlClient) Send(m *ControlRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *eventStreamControlClient) Recv() (*v1.Message, error) {
+	m := new(v1.Message)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventStreamClient) Ack(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*AckResponse, error) {
+	out := new(AckResponse)
+	err := c.cc.Invoke(ctx, "/stream.EventStream/Ack", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EventStreamServer is the server API for EventStream service.
+// All implementations must embed UnimplementedEventStreamServer
+// for forward compatibility
+type EventStreamServer interface {
+	// Subscribe streams messages starting at from_block.
+	Subscribe(*SubscribeRequest, EventStream_SubscribeServer) error
+	// Control streams messages like Subscribe, while letting the client
+	// steer delivery. The first request must be a START command.
+	Control(EventStream_ControlServer) error
+	// Ack stores the position of a named consumer. A later subscription of
+	// the same consumer resumes from there.
+	Ack(context.Context, *AckRequest) (*AckResponse, error)
+	mustEmbedUnimplementedEventStreamServer()
+}
+
+// UnimplementedEventStreamServer must be embedded to have forward compatible implementations.
+type UnimplementedEventStreamServer struct {
+}
+
+func (UnimplementedEventStreamServer) Subscribe(*SubscribeRequest, EventStream_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedEventStreamServer) Control(EventStream_ControlServer) error {
+	return status.Errorf(codes.Unimplemented, "method Control not implemented")
+}
+func (UnimplementedEventStreamServer) Ack(context.Context, *AckRequest) (*AckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ack not implemented")
+}
+func (UnimplementedEventStreamServer) mustEmbedUnimplementedEventStreamServer() {}
+
+// UnsafeEventStreamServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventStreamServer will
+// result in compilation errors.
+type UnsafeEventStreamServer interface {
+	mustEmbedUnimplementedEventStreamServer()
+}
+
+func RegisterEventStreamServer(s grpc.ServiceRegistrar, srv EventStreamServer) {
+	s.RegisterService(&EventStream_ServiceDesc, srv)
+}
+
+func _EventStream_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventStreamServer).Subscribe(m, &eventStreamSubscribeServer{stream})
+}
+
+type EventStream_SubscribeServer interface {
+	Send(*v1.Message) error
+	grpc.ServerStream
+}
+
+type eventStreamSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventStreamSubscribeServer) Send(m *v1.Message) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EventStream_Control_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventStreamServer).Control(&eventStreamControlServer{stream})
+}
+
+type EventStream_ControlServer interface {
+	Send(*v1.Message) error
+	Recv() (*ControlRequest, error)
+	grpc.ServerStream
+}
+
+type eventStreamControlServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventStreamControlServer) Send(m *v1.Message) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *eventStreamControlServer) Recv() (*ControlRequest, error) {
+	m := new(ControlRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _EventStream_Ack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventStreamServer).Ack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stream.EventStream/Ack",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventStreamServer).Ack(ctx, req.(*AckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EventStream_ServiceDesc is the grpc.ServiceDesc for EventStream service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventStream_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "stream.EventStream",
+	HandlerType: (*EventStreamServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Ack",
+			Handler:    _EventStream_Ack_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _EventStream_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Control",
+			Handler:       _EventStream_Control_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "stream.proto",
+}
@@ -0,0 +1,598 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Expr is a compiled filter expression over events, like
+//
+//	address == 0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48 && topic0 == Transfer && value > 1e6
+//
+// Comparisons are joined by && and ||, negated by ! and grouped by
+// parentheses. Their left side is one of
+//
+//	address         the emitting contract
+//	topic0..topic3  a topic; topic0 may be compared to an event name
+//	event           the decoded event name
+//	block           the block number
+//	<argument>      a decoded event argument, e.g. from or value
+//
+// and their right side an address, a hash, an integer (decimal, 0x hex, or
+// with an exponent like 1e6), a name, or a quoted string. Integers compare
+// with == != < <= > >=, other values only with == and !=. A comparison with
+// an argument the event does not have is false.
+//
+// Besides matching events client side, an expression yields the node side
+// filter its equality comparisons imply; see Filter.
+type Expr struct {
+	src     string
+	root    exprNode
+	decoder *decode.Decoder
+}
+
+// ParseExpr compiles an expression. Event names are resolved with the
+// ABIs, which also decode the arguments compared.
+func ParseExpr(s string, abis ...*abi.ABI) (*Expr, error) {
+	toks, err := lexExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", s, err)
+	}
+	p := &exprParser{toks: toks, abis: abis}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", s, err)
+	}
+	return &Expr{src: s, root: root, decoder: decode.NewDecoder(abis...)}, nil
+}
+
+func (x *Expr) String() string {
+	return x.src
+}
+
+// Decoder returns the decoder built from the ABIs of the expression.
+func (x *Expr) Decoder() *decode.Decoder {
+	return x.decoder
+}
+
+// Match reports whether an event matches, decoding it first.
+func (x *Expr) Match(e *events.Event) bool {
+	de, err := x.decoder.Decode(e)
+	if err != nil {
+		de = &decode.Event{Event: e}
+	}
+	return x.root.eval(de)
+}
+
+// Select returns the block with only its matching events, or nil if none
+// matches. A block losing events is a copy made by Block.WithEvents; a
+// block keeping all is b itself.
+func (x *Expr) Select(b *events.Block) *events.Block {
+	var kept []events.Event
+	for i := range b.Events {
+		if x.Match(&b.Events[i]) {
+			kept = append(kept, b.Events[i])
+		}
+	}
+	switch {
+	case len(kept) == 0:
+		return nil
+	case len(kept) == len(b.Events):
+		return b
+	}
+	return b.WithEvents(kept)
+}
+
+// SelectMessage applies Select to an Append message, making it a SetNext
+// past the block if no event matches. Other messages are returned as is.
+func (x *Expr) SelectMessage(m *events.Message) *events.Message {
+	if m.Action != events.Append {
+		return m
+	}
+	b := x.Select(m.Block)
+	if b == nil {
+		return &events.Message{Action: events.SetNext, Number: m.Block.Number + 1}
+	}
+	if b == m.Block {
+		return m
+	}
+	return &events.Message{Action: events.Append, Block: b}
+}
+
+// Matches reports whether a decoded event matches. It can be used as the
+// Match function of a sinks.Notifier.
+func (x *Expr) Matches(de *decode.Event) bool {
+	return x.root.eval(de)
+}
+
+// Filter returns the filter query of the addresses and topics every
+// matching event has, to let the node drop the others. It selects a
+// superset of the matching events; parts of the expression it cannot
+// express, like argument comparisons or negations, select all events. It
+// fails if no event can match, which a filter query cannot express.
+func (x *Expr) Filter() (ethereum.FilterQuery, error) {
+	f := x.root.filter()
+	if f.none() {
+		return ethereum.FilterQuery{}, fmt.Errorf("expression %q matches no events", x.src)
+	}
+	return f.query(), nil
+}
+
+// Narrow returns q restricted to the events Filter selects, e.g. to combine
+// the expression with the contracts a program watches anyway. It fails if
+// no event of q can match.
+func (x *Expr) Narrow(q ethereum.FilterQuery) (ethereum.FilterQuery, error) {
+	f := exprFilter{addresses: q.Addresses}
+	for i := 0; i < len(q.Topics) && i < len(f.topics); i++ {
+		f.topics[i] = q.Topics[i]
+	}
+	f = f.and(x.root.filter())
+	if f.none() {
+		return ethereum.FilterQuery{}, fmt.Errorf("expression %q matches no events of the filter", x.src)
+	}
+	out := f.query()
+	out.FromBlock, out.ToBlock, out.BlockHash = q.FromBlock, q.ToBlock, q.BlockHash
+	return out, nil
+}
+
+// exprFilter holds the values of the address and topics matching events
+// may have; nil allows any, and an empty set none.
+type exprFilter struct {
+	addresses []common.Address
+	topics    [4][]common.Hash
+}
+
+func (f exprFilter) and(g exprFilter) exprFilter {
+	out := exprFilter{addresses: intersectAddresses(f.addresses, g.addresses)}
+	for i := range out.topics {
+		out.topics[i] = intersectHashes(f.topics[i], g.topics[i])
+	}
+	return out
+}
+
+func (f exprFilter) or(g exprFilter) exprFilter {
+	var out exprFilter
+	if f.addresses != nil && g.addresses != nil {
+		out.addresses = append(append([]common.Address{}, f.addresses...), g.addresses...)
+		out.addresses = intersectAddresses(out.addresses, out.addresses)
+	}
+	for i := range out.topics {
+		if f.topics[i] != nil && g.topics[i] != nil {
+			ts := append(append([]common.Hash{}, f.topics[i]...), g.topics[i]...)
+			out.topics[i] = intersectHashes(ts, ts)
+		}
+	}
+	return out
+}
+
+// none reports whether no event can match, because a set is empty.
+func (f exprFilter) none() bool {
+	if f.addresses != nil && len(f.addresses) == 0 {
+		return true
+	}
+	for _, ts := range f.topics {
+		if ts != nil && len(ts) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (f exprFilter) query() ethereum.FilterQuery {
+	q := ethereum.FilterQuery{Addresses: f.addresses}
+	n := len(f.topics)
+	for n > 0 && f.topics[n-1] == nil {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		q.Topics = append(q.Topics, f.topics[i])
+	}
+	return q
+}
+
+// intersectAddresses returns the distinct addresses of a also in b. A nil
+// set allows any address; an empty intersection is an empty, non-nil set
+// allowing none.
+func intersectAddresses(a, b []common.Address) []common.Address {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	in := make(map[common.Address]bool, len(b))
+	for _, x := range b {
+		in[x] = true
+	}
+	out := []common.Address{}
+	for _, x := range a {
+		if in[x] {
+			out = append(out, x)
+			delete(in, x)
+		}
+	}
+	return out
+}
+
+func intersectHashes(a, b []common.Hash) []common.Hash {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	in := make(map[common.Hash]bool, len(b))
+	for _, x := range b {
+		in[x] = true
+	}
+	out := []common.Hash{}
+	for _, x := range a {
+		if in[x] {
+			out = append(out, x)
+			delete(in, x)
+		}
+	}
+	return out
+}
+
+type exprNode interface {
+	eval(de *decode.Event) bool
+	filter() exprFilter
+}
+
+type andNode struct{ l, r exprNode }
+type orNode struct{ l, r exprNode }
+type notNode struct{ x exprNode }
+
+func (n andNode) eval(de *decode.Event) bool { return n.l.eval(de) && n.r.eval(de) }
+func (n orNode) eval(de *decode.Event) bool  { return n.l.eval(de) || n.r.eval(de) }
+func (n notNode) eval(de *decode.Event) bool { return !n.x.eval(de) }
+
+func (n andNode) filter() exprFilter { return n.l.filter().and(n.r.filter()) }
+func (n orNode) filter() exprFilter  { return n.l.filter().or(n.r.filter()) }
+func (n notNode) filter() exprFilter { return exprFilter{} }
+
+// literal is the right side of a comparison.
+type literal struct {
+	text string
+	num  *big.Int // nil unless an integer
+}
+
+// cmpNode compares a field of the event with a literal.
+type cmpNode struct {
+	field string
+	op    string
+	lit   literal
+
+	address common.Address // for address
+	topic   int            // for topic0..topic3
+	hashes  []common.Hash  // for topics and events by name
+}
+
+func (n *cmpNode) filter() exprFilter {
+	var f exprFilter
+	if n.op != "==" {
+		return f
+	}
+	switch n.field {
+	case "address":
+		f.addresses = []common.Address{n.address}
+	case "topic0", "topic1", "topic2", "topic3":
+		f.topics[n.topic] = n.hashes
+	case "event":
+		f.topics[0] = n.hashes
+	}
+	return f
+}
+
+func (n *cmpNode) eval(de *decode.Event) bool {
+	switch n.field {
+	case "address":
+		return compareEq(n.op, de.Address == n.address)
+	case "topic0", "topic1", "topic2", "topic3":
+		if n.topic >= len(de.Topics) {
+			return false
+		}
+		return compareEq(n.op, containsHash(n.hashes, de.Topics[n.topic]))
+	case "event":
+		if de.Name == "" {
+			return false
+		}
+		return compareEq(n.op, de.Name == n.lit.text)
+	case "block":
+		return compareNum(n.op, new(big.Int).SetUint64(de.BlockNumber), n.lit.num)
+	}
+	v, ok := de.Args[n.field]
+	if !ok {
+		return false
+	}
+	if i, ok := ToBigInt(v); ok {
+		return n.lit.num != nil && compareNum(n.op, i, n.lit.num)
+	}
+	switch v := v.(type) {
+	case common.Address:
+		return common.IsHexAddress(n.lit.text) && compareEq(n.op, v == common.HexToAddress(n.lit.text))
+	case [32]byte:
+		return isHex(n.lit.text) && compareEq(n.op, common.Hash(v) == common.HexToHash(n.lit.text))
+	case []byte:
+		return isHex(n.lit.text) && compareEq(n.op, bytes.Equal(v, common.FromHex(n.lit.text)))
+	case bool:
+		return (n.lit.text == "true" || n.lit.text == "false") && compareEq(n.op, v == (n.lit.text == "true"))
+	case string:
+		return compareEq(n.op, v == n.lit.text)
+	}
+	return false
+}
+
+func compareEq(op string, eq bool) bool {
+	if op == "!=" {
+		return !eq
+	}
+	return eq
+}
+
+func compareNum(op string, v, lit *big.Int) bool {
+	c := v.Cmp(lit)
+	switch op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "!=":
+		return c != 0
+	}
+	return c == 0
+}
+
+func containsHash(hs []common.Hash, h common.Hash) bool {
+	for _, x := range hs {
+		if x == h {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string) bool {
+	if !strings.HasPrefix(s, "0x") || len(s) == 2 {
+		return false
+	}
+	for _, c := range s[2:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota // name, number, address or hash
+	tokString                  // quoted string
+	tokOp                      // operator or parenthesis
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lexExpr(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||") ||
+			strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!=") ||
+			strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, token{tokOp, s[i : i+2]})
+			i += 2
+		case strings.IndexByte("<>!()", c) >= 0:
+			toks = append(toks, token{tokOp, s[i : i+1]})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("string at %d: %w", i, err)
+			}
+			toks = append(toks, token{tokString, text})
+			i = j + 1
+		case isWordByte(c):
+			j := i
+			for j < len(s) && (isWordByte(s[j]) ||
+				(s[j] == '+' || s[j] == '-') && s[i] >= '0' && s[i] <= '9' && (s[j-1] == 'e' || s[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, token{tokWord, s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return toks, nil
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+	abis []*abi.ABI
+}
+
+func (p *exprParser) peekOp(op string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokOp && p.toks[p.pos].text == op
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	l, err := p.and()
+	for err == nil && p.peekOp("||") {
+		p.pos++
+		var r exprNode
+		if r, err = p.and(); err == nil {
+			l = orNode{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	l, err := p.unary()
+	for err == nil && p.peekOp("&&") {
+		p.pos++
+		var r exprNode
+		if r, err = p.unary(); err == nil {
+			l = andNode{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	switch {
+	case p.peekOp("!"):
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	case p.peekOp("("):
+		p.pos++
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekOp(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	}
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (exprNode, error) {
+	if p.pos+3 > len(p.toks) {
+		return nil, fmt.Errorf("incomplete comparison at end")
+	}
+	field, op, val := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+	if field.kind != tokWord {
+		return nil, fmt.Errorf("unexpected %q; want a field name", field.text)
+	}
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unexpected %q after %s; want a comparison", op.text, field.text)
+	}
+	if op.kind != tokOp || val.kind == tokOp {
+		return nil, fmt.Errorf("unexpected %q in comparison with %s", val.text, field.text)
+	}
+	p.pos += 3
+	n := &cmpNode{field: field.text, op: op.text, lit: literal{text: val.text}}
+	if val.kind == tokWord {
+		n.lit.num = parseInt(val.text)
+	}
+	ordered := op.text != "==" && op.text != "!="
+	if ordered && n.lit.num == nil {
+		return nil, fmt.Errorf("%s %s %s: want an integer", field.text, op.text, val.text)
+	}
+
+	switch field.text {
+	case "address":
+		if !common.IsHexAddress(val.text) || ordered {
+			return nil, fmt.Errorf("%s %s %s: want == or != an address", field.text, op.text, val.text)
+		}
+		n.address = common.HexToAddress(val.text)
+	case "topic0", "topic1", "topic2", "topic3":
+		n.topic = int(field.text[5] - '0')
+		switch {
+		case ordered:
+			return nil, fmt.Errorf("%s %s %s: want == or !=", field.text, op.text, val.text)
+		case isHex(val.text) && len(val.text) <= 66:
+			n.hashes = []common.Hash{common.HexToHash(val.text)}
+		case n.topic == 0:
+			if n.hashes = p.eventIDs(val.text); n.hashes == nil {
+				return nil, fmt.Errorf("%s == %s: unknown event", field.text, val.text)
+			}
+		default:
+			return nil, fmt.Errorf("%s %s %s: want a hash", field.text, op.text, val.text)
+		}
+	case "event":
+		if ordered {
+			return nil, fmt.Errorf("%s %s %s: want == or !=", field.text, op.text, val.text)
+		}
+		if n.hashes = p.eventIDs(val.text); n.hashes == nil {
+			return nil, fmt.Errorf("%s == %s: unknown event", field.text, val.text)
+		}
+	case "block":
+		if n.lit.num == nil {
+			return nil, fmt.Errorf("%s %s %s: want a block number", field.text, op.text, val.text)
+		}
+	}
+	return n, nil
+}
+
+// eventIDs returns the signature hashes of the events of the ABIs named
+// name, or nil if there are none.
+func (p *exprParser) eventIDs(name string) []common.Hash {
+	var ids []common.Hash
+	for _, a := range p.abis {
+		if ev, ok := a.Events[name]; ok && !containsHash(ids, ev.ID) {
+			ids = append(ids, ev.ID)
+		}
+	}
+	return ids
+}
+
+// parseInt parses a decimal, 0x hex or exponent integer like 1e6, returning
+// nil for other words.
+func parseInt(s string) *big.Int {
+	if strings.HasPrefix(s, "0x") {
+		n, ok := new(big.Int).SetString(s[2:], 16)
+		if !ok {
+			return nil
+		}
+		return n
+	}
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !r.IsInt() {
+		return nil
+	}
+	return r.Num()
+}
@@ -0,0 +1,257 @@
+// Package rules implements threshold alerts on decoded events. A Rule matches
+// events by contract address and event name (or topic0), reads one decoded
+// argument and compares it against a threshold. Rules are usually loaded
+// from a JSON config file:
+//
+//	{
+//	  "abis": ["erc20"],
+//	  "rules": [{
+//	    "name": "big USDC transfer",
+//	    "address": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+//	    "event": "Transfer",
+//	    "field": "value",
+//	    "op": ">=",
+//	    "threshold": "1000000000000"
+//	  }]
+//	}
+//
+// The package also compiles filter expressions combining such comparisons,
+// see Expr.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Rule describes a single threshold alert.
+type Rule struct {
+	Name      string          `json:"name"`
+	Address   *common.Address `json:"address,omitempty"` // nil matches any address
+	Event     string          `json:"event,omitempty"`   // decoded event name
+	Topic0    *common.Hash    `json:"topic0,omitempty"`  // event signature hash
+	Field     string          `json:"field"`             // decoded argument to compare
+	Op        string          `json:"op"`                // one of > >= < <= == !=
+	Threshold string          `json:"threshold"`         // decimal, or hex with 0x prefix
+
+	threshold *big.Int
+}
+
+// Config is the contents of a rules config file.
+type Config struct {
+	ABIs  []string `json:"abis"` // builtin ABI names or paths to ABI JSON files
+	Rules []*Rule  `json:"rules"`
+}
+
+// LoadConfig reads a JSON rules config file.
+func LoadConfig(fn string) (*Config, error) {
+	bs, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return &cfg, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Field == "" {
+		return fmt.Errorf("rule %q: missing field", r.Name)
+	}
+	switch r.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return fmt.Errorf("rule %q: got op=%q; want one of > >= < <= == !=", r.Name, r.Op)
+	}
+	if r.Threshold == "" {
+		return fmt.Errorf("rule %q: missing threshold", r.Name)
+	}
+	t, ok := parseThreshold(r.Threshold)
+	if !ok {
+		return fmt.Errorf("rule %q: got threshold=%q; want decimal or 0x hex integer", r.Name, r.Threshold)
+	}
+	r.threshold = t
+	return nil
+}
+
+// parseThreshold parses a decimal integer, or a hex one with a 0x prefix.
+// Unlike base 0, a leading zero does not mean octal.
+func parseThreshold(s string) (*big.Int, bool) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return new(big.Int).SetString(s[2:], 16)
+	}
+	return new(big.Int).SetString(s, 10)
+}
+
+// Match reports whether a decoded event triggers the rule, and returns the
+// compared value.
+func (r *Rule) Match(de *decode.Event) (*big.Int, bool) {
+	if r.Address != nil && de.Address != *r.Address {
+		return nil, false
+	}
+	if r.Event != "" && de.Name != r.Event {
+		return nil, false
+	}
+	if r.Topic0 != nil && (len(de.Topics) == 0 || de.Topics[0] != *r.Topic0) {
+		return nil, false
+	}
+	v, ok := ToBigInt(de.Args[r.Field])
+	if !ok {
+		return nil, false
+	}
+	c := v.Cmp(r.threshold)
+	switch r.Op {
+	case ">":
+		ok = c > 0
+	case ">=":
+		ok = c >= 0
+	case "<":
+		ok = c < 0
+	case "<=":
+		ok = c <= 0
+	case "==":
+		ok = c == 0
+	case "!=":
+		ok = c != 0
+	}
+	return v, ok
+}
+
+// ToBigInt converts a decoded integer argument to a big.Int.
+func ToBigInt(v interface{}) (*big.Int, bool) {
+	switch x := v.(type) {
+	case *big.Int:
+		return x, x != nil
+	case uint8:
+		return new(big.Int).SetUint64(uint64(x)), true
+	case uint16:
+		return new(big.Int).SetUint64(uint64(x)), true
+	case uint32:
+		return new(big.Int).SetUint64(uint64(x)), true
+	case uint64:
+		return new(big.Int).SetUint64(x), true
+	case int8:
+		return big.NewInt(int64(x)), true
+	case int16:
+		return big.NewInt(int64(x)), true
+	case int32:
+		return big.NewInt(int64(x)), true
+	case int64:
+		return big.NewInt(x), true
+	}
+	return nil, false
+}
+
+// Alert is passed to the callback of an Engine when a rule fires.
+type Alert struct {
+	Rule  *Rule
+	Event *decode.Event
+	Value *big.Int
+}
+
+// Engine is a Sink evaluating rules against every appended block.
+type Engine struct {
+	rules   []*Rule
+	decoder *decode.Decoder
+	fire    func(*Alert) error
+}
+
+// NewEngine validates the rules of a config and returns an Engine calling
+// fire for every match.
+func NewEngine(cfg *Config, fire func(*Alert) error) (*Engine, error) {
+	abis := make([]*abi.ABI, len(cfg.ABIs))
+	for i, name := range cfg.ABIs {
+		a, err := decode.LoadABI(name)
+		if err != nil {
+			return nil, err
+		}
+		abis[i] = a
+	}
+	for _, r := range cfg.Rules {
+		if err := r.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &Engine{
+		rules:   cfg.Rules,
+		decoder: decode.NewDecoder(abis...),
+		fire:    fire,
+	}, nil
+}
+
+// Filter returns a filter query selecting the contracts the rules watch. If
+// any rule matches all addresses, so does the filter.
+func (e *Engine) Filter() ethereum.FilterQuery {
+	var q ethereum.FilterQuery
+	for _, r := range e.rules {
+		if r.Address == nil {
+			return ethereum.FilterQuery{}
+		}
+		q.Addresses = append(q.Addresses, *r.Address)
+	}
+	return q
+}
+
+// Matches reports whether any rule matches a decoded event. It can be used
+// as the Match function of a sinks.Notifier.
+func (e *Engine) Matches(de *decode.Event) bool {
+	for _, r := range e.rules {
+		if _, ok := r.Match(de); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Decoder returns the decoder built from the ABIs of the config.
+func (e *Engine) Decoder() *decode.Decoder {
+	return e.decoder
+}
+
+func (e *Engine) Append(b *events.Block) error {
+	decoded, err := e.decoder.DecodeBlock(b)
+	if err != nil {
+		return err
+	}
+	for _, de := range decoded {
+		for _, r := range e.rules {
+			v, ok := r.Match(de)
+			if !ok {
+				continue
+			}
+			if err := e.fire(&Alert{Rule: r, Event: de, Value: v}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) Rollback(n uint64) error {
+	return nil
+}
+
+func (e *Engine) SetNext(n uint64) error {
+	return nil
+}
+
+// Run streams from s and evaluates the rules until the stream ends.
+func (e *Engine) Run(s events.Streamer, done chan struct{}, from uint64) error {
+	sub, err := s.Stream(done, from)
+	if err != nil {
+		return err
+	}
+	return events.Drain(sub, e)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+func addressesFromProto(pb [][]byte) ([]common.Address, error) {
+	addresses := make([]common.Address, len(pb))
+	for i, a := range pb {
+		if len(a) != common.AddressLength {
+			return nil, fmt.Errorf("invalid address %x", a)
+		}
+		addresses[i] = common.BytesToAddress(a)
+	}
+	return addresses, nil
+}
+
+// addressFilter restricts the events of a stream to a set of contract
+// addresses. An empty filter passes all events.
+type addressFilter map[common.Address]bool
+
+func newAddressFilter(addresses []common.Address) addressFilter {
+	f := make(addressFilter)
+	f.add(addresses)
+	return f
+}
+
+func (f addressFilter) add(addresses []common.Address) {
+	for _, a := range addresses {
+		f[a] = true
+	}
+}
+
+// apply returns the message with the events of other contracts removed, or
+// nil if an Append message has no events left. The block keeps its header;
+// see events.Block.WithEvents.
+func (f addressFilter) apply(m *events.Message) *events.Message {
+	if len(f) == 0 || m.Action != events.Append {
+		return m
+	}
+	var kept []events.Event
+	for _, e := range m.Block.Events {
+		if f[e.Address] {
+			kept = append(kept, e)
+		}
+	}
+	switch {
+	case len(kept) == 0:
+		return nil
+	case len(kept) == len(m.Block.Events):
+		return m
+	}
+	return &events.Message{
+		Action: events.Append,
+		Block:  m.Block.WithEvents(kept),
+	}
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// feedKeyPrefix prefixes the names of feeds in the Positions of a server.
+const feedKeyPrefix = "feed:"
+
+// FeedRetryDelay is the time a feed waits before resuming after its
+// endpoint or the source failed.
+const FeedRetryDelay = 5 * time.Second
+
+// Feed is a subscription registered with the server, which pushes its
+// messages to an endpoint instead of a client pulling them. Its position is
+// kept in the Positions of the server under "feed:" and its Name, apart
+// from the positions of consumers.
+type Feed struct {
+	Name      string           `json:"name"`
+	Addresses []common.Address `json:"addresses,omitempty"` // all events if empty
+	FromBlock uint64           `json:"fromBlock"`           // used until a position is saved
+
+	// Endpoint is the HTTP URL every message is POSTed to, as protojson.
+	// A message is acknowledged once the endpoint returns a 2xx status.
+	Endpoint string `json:"endpoint"`
+}
+
+// FeedStore persists the registered feeds of a server.
+type FeedStore interface {
+	Put(f *Feed) error
+	Delete(name string) error
+	All() ([]*Feed, error)
+}
+
+// MemoryFeeds is a FeedStore that does not survive restarts.
+type MemoryFeeds struct {
+	mu    sync.Mutex
+	feeds map[string]*Feed
+}
+
+func NewMemoryFeeds() *MemoryFeeds {
+	return &MemoryFeeds{
+		feeds: make(map[string]*Feed),
+	}
+}
+
+func (s *MemoryFeeds) Put(f *Feed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feeds[f.Name] = f
+	return nil
+}
+
+func (s *MemoryFeeds) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.feeds, name)
+	return nil
+}
+
+// All returns the feeds sorted by name.
+func (s *MemoryFeeds) All() ([]*Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make([]*Feed, 0, len(s.feeds))
+	for _, f := range s.feeds {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, nil
+}
+
+// FileFeeds is a FeedStore keeping all feeds in a JSON file, which is
+// rewritten atomically on every change.
+type FileFeeds struct {
+	mem  *MemoryFeeds
+	path string
+}
+
+// OpenFileFeeds loads the feeds stored at path, if the file exists.
+func OpenFileFeeds(path string) (*FileFeeds, error) {
+	s := &FileFeeds{
+		mem:  NewMemoryFeeds(),
+		path: path,
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, &s.mem.feeds); err != nil {
+		return nil, err
+	}
+	if s.mem.feeds == nil { // the file holds null
+		s.mem.feeds = make(map[string]*Feed)
+	}
+	return s, nil
+}
+
+func (s *FileFeeds) Put(f *Feed) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	s.mem.feeds[f.Name] = f
+	return s.write()
+}
+
+func (s *FileFeeds) Delete(name string) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	delete(s.mem.feeds, name)
+	return s.write()
+}
+
+func (s *FileFeeds) All() ([]*Feed, error) {
+	return s.mem.All()
+}
+
+func (s *FileFeeds) write() error {
+	bs, err := json.MarshalIndent(s.mem.feeds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return events.WriteFileAtomic(s.path, bs)
+}
+
+// AddFeed registers a feed and starts pushing its messages. A feed of the
+// same name is replaced.
+func (s *Server) AddFeed(f *Feed) error {
+	if f.Name == "" || f.Endpoint == "" {
+		return fmt.Errorf("got feed name %q, endpoint %q; want both", f.Name, f.Endpoint)
+	}
+	if strings.Contains(f.Name, "/") {
+		return fmt.Errorf("got feed name %q; want no %q", f.Name, "/")
+	}
+	if err := s.Feeds.Put(f); err != nil {
+		return err
+	}
+	s.startFeed(f)
+	return nil
+}
+
+// RemoveFeed stops a feed and deletes it from the registry. Its position is
+// kept.
+func (s *Server) RemoveFeed(name string) error {
+	s.mu.Lock()
+	rf := s.feeds[name]
+	delete(s.feeds, name)
+	s.mu.Unlock()
+	rf.stop()
+	return s.Feeds.Delete(name)
+}
+
+// StartFeeds starts all feeds of the registry, each from its saved
+// position. Call it once when the server starts, after setting Feeds and
+// Positions, to restore the feeds registered before a restart.
+func (s *Server) StartFeeds() error {
+	all, err := s.Feeds.All()
+	if err != nil {
+		return err
+	}
+	for _, f := range all {
+		s.startFeed(f)
+	}
+	return nil
+}
+
+// StopFeeds stops all running feeds, leaving them registered, and waits
+// for them.
+func (s *Server) StopFeeds() {
+	s.mu.Lock()
+	running := s.feeds
+	s.feeds = make(map[string]*runningFeed)
+	s.mu.Unlock()
+	for _, rf := range running {
+		rf.stop()
+	}
+}
+
+// runningFeed is the goroutine pushing a feed.
+type runningFeed struct {
+	done    chan struct{} // closed to stop it
+	stopped chan struct{} // closed when it returned
+}
+
+// stop stops the goroutine and waits for it, so that it no longer saves
+// the position of the feed. It does nothing on nil.
+func (rf *runningFeed) stop() {
+	if rf == nil {
+		return
+	}
+	close(rf.done)
+	<-rf.stopped
+}
+
+// startFeed starts pushing a feed, after stopping the goroutine of a feed
+// of the same name.
+func (s *Server) startFeed(f *Feed) {
+	rf := &runningFeed{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.mu.Lock()
+	old := s.feeds[f.Name]
+	s.feeds[f.Name] = rf
+	s.mu.Unlock()
+	old.stop()
+	go func() {
+		defer close(rf.stopped)
+		s.runFeed(f, rf.done)
+	}()
+}
+
+// runFeed pushes the messages of a feed until done is closed, resuming from
+// the saved position after failures.
+func (s *Server) runFeed(f *Feed, done chan struct{}) {
+	for {
+		err := s.pushFeed(f, done)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		log.Printf("feed %s: %v; retrying in %v", f.Name, err, FeedRetryDelay)
+		select {
+		case <-done:
+			return
+		case <-time.After(FeedRetryDelay):
+		}
+	}
+}
+
+func (s *Server) pushFeed(f *Feed, done chan struct{}) error {
+	from, err := s.startBlock(feedKeyPrefix+f.Name, f.FromBlock)
+	if err != nil {
+		return err
+	}
+	// Cancel the subscription both when the feed stops and when a push
+	// fails.
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := make(chan struct{})
+	defer close(stop)
+	streamDone := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-stop:
+		}
+		close(streamDone)
+		cancel()
+	}()
+
+	sub, err := s.source.Stream(streamDone, from)
+	if err != nil {
+		return err
+	}
+	filter := newAddressFilter(f.Addresses)
+	next := from
+	for m := range sub.C {
+		next = nextBlock(next, m)
+		if m = filter.apply(m); m == nil {
+			continue
+		}
+		if err := s.post(ctx, f.Endpoint, m); err != nil {
+			return err
+		}
+		if err := s.Positions.Save(feedKeyPrefix+f.Name, next); err != nil {
+			return err
+		}
+	}
+	if err := <-sub.Err; err != nil {
+		return err
+	}
+	return fmt.Errorf("stream ended at block %d", next)
+}
+
+func (s *Server) post(ctx context.Context, url string, m *events.Message) error {
+	body, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(events.MessageToProto(m))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("endpoint returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
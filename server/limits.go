@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Limits bounds the resources a single client may use. Zero values mean
+// unlimited.
+//
+// BlocksPerSecond is the block-range quota: it limits how fast the streams of
+// a client may advance through the chain. A client at head advances slowly
+// and is never throttled, while a client replaying from genesis is held to
+// the quota and cannot starve the others.
+type Limits struct {
+	MaxStreams        int     // concurrent streams
+	MessagesPerSecond float64 // sustained rate of messages sent
+	MessageBurst      float64 // defaults to MessagesPerSecond
+	BlocksPerSecond   float64 // sustained rate of blocks streamed
+	BlockBurst        float64 // defaults to BlocksPerSecond
+}
+
+// clientIdleTimeout is how long the state of a client without streams is
+// kept. By then its limiters have refilled, unless the quota is tiny.
+const clientIdleTimeout = 10 * time.Minute
+
+// clientState is shared by all streams of a client.
+type clientState struct {
+	streams  int
+	idle     time.Time // when streams dropped to zero
+	messages *limiter
+	blocks   *limiter
+}
+
+// acquire registers a new stream of the client making the request, and
+// returns a function to release it. It also forgets clients idle for
+// clientIdleTimeout.
+func (s *Server) acquire(ctx context.Context) (*clientState, func(), error) {
+	name := clientName(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for n, cl := range s.clients {
+		if cl.streams == 0 && now.Sub(cl.idle) > clientIdleTimeout {
+			delete(s.clients, n)
+		}
+	}
+	cl, ok := s.clients[name]
+	if !ok {
+		cl = &clientState{
+			messages: newLimiter(s.Limits.MessagesPerSecond, s.Limits.MessageBurst),
+			blocks:   newLimiter(s.Limits.BlocksPerSecond, s.Limits.BlockBurst),
+		}
+		s.clients[name] = cl
+	}
+	if s.Limits.MaxStreams > 0 && cl.streams >= s.Limits.MaxStreams {
+		return nil, nil, status.Errorf(codes.ResourceExhausted, "client %q has %d streams; limit is %d", name, cl.streams, s.Limits.MaxStreams)
+	}
+	cl.streams++
+	release := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		cl.streams--
+		if cl.streams == 0 {
+			cl.idle = time.Now()
+		}
+	}
+	return cl, release, nil
+}
+
+// clientName identifies the client of a request: by authenticated name, or
+// else by remote host. Not by consumer name, which the client chooses, so
+// that it could evade the limits with a new one per stream.
+func clientName(ctx context.Context) string {
+	if client, ok := ClientFromContext(ctx); ok {
+		return client
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			return p.Addr.String()
+		}
+		return host
+	}
+	return ""
+}
+
+// wait blocks until the client may send a message that advances its stream
+// from block prev.
+func (cl *clientState) wait(ctx context.Context, prev uint64, m *events.Message) error {
+	if next := nextBlock(prev, m); next > prev {
+		if err := cl.blocks.wait(ctx, float64(next-prev)); err != nil {
+			return err
+		}
+	}
+	return cl.messages.wait(ctx, 1)
+}
+
+// limiter is a token bucket which may go into debt: a request for more
+// tokens than the burst succeeds after the debt is paid off at the rate.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns nil, which never blocks, if rate is zero.
+func newLimiter(rate, burst float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+	return &limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (l *limiter) wait(ctx context.Context, n float64) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= n
+	d := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// ClientInfo describes a client known to the server.
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Streams int    `json:"streams"` // open streams
+}
+
+// Clients returns the clients with open streams or recently closed ones,
+// sorted by name.
+func (s *Server) Clients() []ClientInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]ClientInfo, 0, len(s.clients))
+	for name, cl := range s.clients {
+		clients = append(clients, ClientInfo{Name: name, Streams: cl.streams})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Name < clients[j].Name
+	})
+	return clients
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// PositionStore persists the acknowledged positions of named consumers. A
+// position is the first block number the consumer has not yet processed.
+//
+// Positions are not rolled back: a consumer that disconnects before a chain
+// reorganization below its position will not see the Rollback on resume.
+// Consumers should only acknowledge blocks they consider final.
+type PositionStore interface {
+	Load(consumer string) (uint64, bool, error)
+	Save(consumer string, next uint64) error
+	All() (map[string]uint64, error)
+}
+
+// MemoryPositions is a PositionStore that does not survive restarts.
+type MemoryPositions struct {
+	mu        sync.Mutex
+	positions map[string]uint64
+}
+
+func NewMemoryPositions() *MemoryPositions {
+	return &MemoryPositions{
+		positions: make(map[string]uint64),
+	}
+}
+
+func (p *MemoryPositions) Load(consumer string) (uint64, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n, ok := p.positions[consumer]
+	return n, ok, nil
+}
+
+func (p *MemoryPositions) Save(consumer string, next uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.positions[consumer] = next
+	return nil
+}
+
+func (p *MemoryPositions) All() (map[string]uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	all := make(map[string]uint64, len(p.positions))
+	for k, v := range p.positions {
+		all[k] = v
+	}
+	return all, nil
+}
+
+// FilePositions is a PositionStore keeping all positions in a JSON file,
+// which is rewritten atomically on every Save, with events.WriteFileAtomic.
+type FilePositions struct {
+	mem  *MemoryPositions
+	path string
+}
+
+// OpenFilePositions loads the positions stored at path, if the file exists.
+func OpenFilePositions(path string) (*FilePositions, error) {
+	p := &FilePositions{
+		mem:  NewMemoryPositions(),
+		path: path,
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, &p.mem.positions); err != nil {
+		return nil, err
+	}
+	if p.mem.positions == nil { // the file holds null
+		p.mem.positions = make(map[string]uint64)
+	}
+	return p, nil
+}
+
+func (p *FilePositions) Load(consumer string) (uint64, bool, error) {
+	return p.mem.Load(consumer)
+}
+
+func (p *FilePositions) Save(consumer string, next uint64) error {
+	p.mem.mu.Lock()
+	defer p.mem.mu.Unlock()
+	p.mem.positions[consumer] = next
+	bs, err := json.MarshalIndent(p.mem.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return events.WriteFileAtomic(p.path, bs)
+}
+
+func (p *FilePositions) All() (map[string]uint64, error) {
+	return p.mem.All()
+}
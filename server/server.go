@@ -0,0 +1,266 @@
+// Package server exposes a Streamer to remote clients over gRPC.
+//
+// Every client subscription calls Stream on the source Streamer, so the
+// source must support concurrent streams.
+//
+// Clients may name themselves as consumers. The server then remembers the
+// position each consumer acknowledges, and a consumer reconnecting later
+// resumes from its acknowledged position, like a Kafka consumer group.
+//
+// Feeds are subscriptions registered with the server that push their
+// messages to an HTTP endpoint. They are kept in a FeedStore, so that a
+// restarted server resumes them from their positions.
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	spb "github.com/jcjlcodes/eth-eventlog/proto/stream"
+)
+
+// Server implements the EventStream gRPC service.
+type Server struct {
+	spb.UnimplementedEventStreamServer
+
+	// Positions stores the acknowledged positions of named consumers, with
+	// the names of authenticated clients qualified as "client/consumer",
+	// and the positions of feeds as "feed:name". It
+	// defaults to a MemoryPositions; replace it before serving to persist
+	// positions across restarts.
+	Positions PositionStore
+
+	// Limits applies to every client, identified by its authenticated name
+	// or remote host.
+	Limits Limits
+
+	// Feeds stores the registered feeds. It defaults to a MemoryFeeds;
+	// replace it before StartFeeds to restore feeds across restarts.
+	Feeds FeedStore
+
+	// HTTPClient posts the messages of feeds; http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	source events.Streamer
+
+	mu      sync.Mutex
+	clients map[string]*clientState
+	feeds   map[string]*runningFeed
+}
+
+func NewServer(source events.Streamer) *Server {
+	return &Server{
+		Positions: NewMemoryPositions(),
+		Feeds:     NewMemoryFeeds(),
+		source:    source,
+		clients:   make(map[string]*clientState),
+		feeds:     make(map[string]*runningFeed),
+	}
+}
+
+// Register registers the EventStream service with a gRPC server.
+func (s *Server) Register(g *grpc.Server) {
+	spb.RegisterEventStreamServer(g, s)
+}
+
+func (s *Server) Subscribe(req *spb.SubscribeRequest, stream spb.EventStream_SubscribeServer) error {
+	addresses, err := addressesFromProto(req.Addresses)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	filter := newAddressFilter(addresses)
+	key, err := consumerKey(stream.Context(), req.Consumer)
+	if err != nil {
+		return err
+	}
+	from, err := s.startBlock(key, req.FromBlock)
+	if err != nil {
+		return err
+	}
+	cl, release, err := s.acquire(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// gRPC cancels the stream context when the handler returns.
+	done := make(chan struct{})
+	go func() {
+		<-stream.Context().Done()
+		close(done)
+	}()
+
+	sub, err := s.source.Stream(done, from)
+	if err != nil {
+		return err
+	}
+	next := from
+	for m := range sub.C {
+		if err := cl.wait(stream.Context(), next, m); err != nil {
+			return err
+		}
+		next = nextBlock(next, m)
+		if m = filter.apply(m); m == nil {
+			continue
+		}
+		if err := stream.Send(events.MessageToProto(m)); err != nil {
+			return err
+		}
+	}
+	return streamErr(<-sub.Err)
+}
+
+func (s *Server) Control(stream spb.EventStream_ControlServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if req.Command != spb.ControlRequest_START {
+		return status.Errorf(codes.InvalidArgument, "got command %v; want START", req.Command)
+	}
+	addresses, err := addressesFromProto(req.Addresses)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	key, err := consumerKey(stream.Context(), req.Consumer)
+	if err != nil {
+		return err
+	}
+	from, err := s.startBlock(key, req.FromBlock)
+	if err != nil {
+		return err
+	}
+	cl, release, err := s.acquire(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx := stream.Context()
+	cmds := make(chan *spb.ControlRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			r, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case cmds <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	sess := &session{
+		source:    s.source,
+		positions: s.Positions,
+		consumer:  key,
+		filter:    newAddressFilter(addresses),
+	}
+	if err := sess.start(from); err != nil {
+		return err
+	}
+	defer sess.stop()
+
+	for {
+		var c chan *events.Message
+		if !sess.paused {
+			c = sess.sub.C
+		}
+		select {
+		case m, ok := <-c:
+			if !ok {
+				return streamErr(<-sess.sub.Err)
+			}
+			if err := cl.wait(ctx, sess.next, m); err != nil {
+				return err
+			}
+			sess.track(m)
+			if m = sess.filter.apply(m); m == nil {
+				continue
+			}
+			if err := stream.Send(events.MessageToProto(m)); err != nil {
+				return err
+			}
+		case r := <-cmds:
+			if err := sess.command(stream, r); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if err != io.EOF {
+				return err
+			}
+			// The client closed its side; keep streaming without commands.
+			recvErr = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) Ack(ctx context.Context, req *spb.AckRequest) (*spb.AckResponse, error) {
+	if req.Consumer == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing consumer")
+	}
+	key, err := consumerKey(ctx, req.Consumer)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Positions.Save(key, req.NextBlock); err != nil {
+		return nil, err
+	}
+	return &spb.AckResponse{}, nil
+}
+
+// startBlock returns the position stored under a key, or from if there is
+// none or no key.
+func (s *Server) startBlock(key string, from uint64) (uint64, error) {
+	if key == "" {
+		return from, nil
+	}
+	n, ok, err := s.Positions.Load(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return from, nil
+	}
+	return n, nil
+}
+
+// consumerKey is the key of the position of a consumer: its name, qualified
+// by the authenticated client, so that a client can neither resume from
+// nor acknowledge the positions of another. Names with a "/" or the prefix
+// of feed keys are rejected, so that no two keys clash.
+func consumerKey(ctx context.Context, consumer string) (string, error) {
+	if consumer == "" {
+		return "", nil
+	}
+	if strings.Contains(consumer, "/") || strings.HasPrefix(consumer, feedKeyPrefix) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid consumer name %q; want no %q and no %q prefix", consumer, "/", feedKeyPrefix)
+	}
+	if client, ok := ClientFromContext(ctx); ok {
+		return client + "/" + consumer, nil
+	}
+	return consumer, nil
+}
+
+// streamErr maps the end of a subscription to a gRPC status.
+func streamErr(err error) error {
+	if err == nil || errors.Is(err, events.Canceled) {
+		return nil
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
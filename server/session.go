@@ -0,0 +1,102 @@
+package server
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	spb "github.com/jcjlcodes/eth-eventlog/proto/stream"
+)
+
+// session is the state of a Control stream.
+type session struct {
+	source    events.Streamer
+	positions PositionStore
+	consumer  string
+	filter    addressFilter
+	paused    bool
+
+	done chan struct{}
+	sub  *events.Subscription
+	next uint64 // next block number the client expects
+}
+
+func (s *session) start(from uint64) error {
+	done := make(chan struct{})
+	sub, err := s.source.Stream(done, from)
+	if err != nil {
+		close(done)
+		return err
+	}
+	s.done = done
+	s.sub = sub
+	s.next = from
+	return nil
+}
+
+// stop cancels the current subscription. Its goroutine ends at the next
+// send; the buffered error channel means nobody needs to wait for it.
+func (s *session) stop() {
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+}
+
+func (s *session) track(m *events.Message) {
+	s.next = nextBlock(s.next, m)
+}
+
+// nextBlock returns the stream position after a message.
+func nextBlock(prev uint64, m *events.Message) uint64 {
+	switch m.Action {
+	case events.Append:
+		return m.Block.Number + 1
+	case events.Rollback, events.SetNext:
+		return m.Number
+	}
+	return prev
+}
+
+func (s *session) command(stream spb.EventStream_ControlServer, r *spb.ControlRequest) error {
+	switch r.Command {
+	case spb.ControlRequest_PAUSE:
+		s.paused = true
+	case spb.ControlRequest_RESUME:
+		s.paused = false
+	case spb.ControlRequest_ADD_ADDRESSES:
+		addresses, err := addressesFromProto(r.Addresses)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		// An empty filter passes all events; adding to it would narrow
+		// the stream instead.
+		if len(s.filter) > 0 {
+			s.filter.add(addresses)
+		}
+	case spb.ControlRequest_SEEK:
+		// Tell the client where the stream continues, so that its view stays
+		// consistent: seeking back is a rollback, seeking ahead skips blocks.
+		m := &events.Message{Action: events.SetNext, Number: r.FromBlock}
+		if r.FromBlock < s.next {
+			m.Action = events.Rollback
+		}
+		s.stop()
+		if err := s.start(r.FromBlock); err != nil {
+			return err
+		}
+		if err := stream.Send(events.MessageToProto(m)); err != nil {
+			return err
+		}
+	case spb.ControlRequest_ACK:
+		if s.consumer == "" {
+			return status.Error(codes.InvalidArgument, "ACK without consumer")
+		}
+		if err := s.positions.Save(s.consumer, r.FromBlock); err != nil {
+			return err
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "unexpected command %v", r.Command)
+	}
+	return nil
+}
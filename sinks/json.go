@@ -0,0 +1,41 @@
+package sinks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// eventJSON is the JSON representation of an Event used in sink payloads.
+type eventJSON struct {
+	Address     string        `json:"address"`
+	Topics      []common.Hash `json:"topics"`
+	Data        hexutil.Bytes `json:"data"`
+	BlockNumber uint64        `json:"blockNumber"`
+	BlockHash   common.Hash   `json:"blockHash"`
+	Index       uint64        `json:"index"`
+	TxHash      common.Hash   `json:"txHash"`
+	TxIndex     uint64        `json:"txIndex"`
+}
+
+func toEventJSON(e *events.Event, af events.AddressFormat) *eventJSON {
+	return &eventJSON{
+		Address:     af.Format(e.Address),
+		Topics:      e.Topics,
+		Data:        e.Data,
+		BlockNumber: e.BlockNumber,
+		BlockHash:   e.BlockHash,
+		Index:       e.Index,
+		TxHash:      e.TxHash,
+		TxIndex:     e.TxIndex,
+	}
+}
+
+// messageJSON is the JSON payload published for a stream message.
+type messageJSON struct {
+	Action string       `json:"action"`
+	Number uint64       `json:"number"`
+	Hash   *common.Hash `json:"hash,omitempty"`
+	Events []*eventJSON `json:"events,omitempty"`
+}
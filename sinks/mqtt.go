@@ -0,0 +1,144 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"text/template"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// DefaultMQTTTopic is the topic template used when MQTTSink.Topic is nil.
+var DefaultMQTTTopic = template.Must(template.New("topic").Parse(`eventlog/{{.Address.Hex}}`))
+
+const DefaultMQTTRollbackTopic = "eventlog/rollback"
+const DefaultMQTTTimeout = 10 * time.Second
+
+// MQTTTopicData is the data passed to the topic template of an MQTTSink.
+type MQTTTopicData struct {
+	Address common.Address
+	Block   uint64
+}
+
+// MQTTSink is a Sink publishing stream messages to an MQTT broker as JSON.
+// For each appended block, the events are grouped by contract address and
+// published to the topic rendered by Topic. Rollbacks are published to
+// RollbackTopic. SetNext messages are not published.
+type MQTTSink struct {
+	Broker    string // tcp://host:1883 or ssl://host:8883
+	ClientID  string
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+
+	QoS           byte // 0 or 1
+	Retain        bool
+	Topic         *template.Template // defaults to DefaultMQTTTopic
+	RollbackTopic string             // defaults to DefaultMQTTRollbackTopic
+	Timeout       time.Duration      // defaults to DefaultMQTTTimeout
+
+	// ChecksumAddresses writes the addresses in the payload EIP-55 mixed
+	// case, rather than all lowercase.
+	ChecksumAddresses bool
+
+	conn *mqttConn
+}
+
+func (s *MQTTSink) addressFormat() events.AddressFormat {
+	if s.ChecksumAddresses {
+		return events.ChecksumAddress
+	}
+	return events.LowercaseAddress
+}
+
+func (s *MQTTSink) Append(b *events.Block) error {
+	var order []common.Address
+	groups := make(map[common.Address][]*eventJSON)
+	for i := range b.Events {
+		e := &b.Events[i]
+		if _, ok := groups[e.Address]; !ok {
+			order = append(order, e.Address)
+		}
+		groups[e.Address] = append(groups[e.Address], toEventJSON(e, s.addressFormat()))
+	}
+	tmpl := s.Topic
+	if tmpl == nil {
+		tmpl = DefaultMQTTTopic
+	}
+	for _, addr := range order {
+		var topic bytes.Buffer
+		if err := tmpl.Execute(&topic, &MQTTTopicData{Address: addr, Block: b.Number}); err != nil {
+			return err
+		}
+		hash := b.Hash
+		if err := s.publish(topic.String(), &messageJSON{
+			Action: "append",
+			Number: b.Number,
+			Hash:   &hash,
+			Events: groups[addr],
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MQTTSink) Rollback(n uint64) error {
+	topic := s.RollbackTopic
+	if topic == "" {
+		topic = DefaultMQTTRollbackTopic
+	}
+	return s.publish(topic, &messageJSON{
+		Action: "rollback",
+		Number: n,
+	})
+}
+
+func (s *MQTTSink) SetNext(n uint64) error {
+	return nil
+}
+
+// Close disconnects from the broker.
+func (s *MQTTSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.close()
+	s.conn = nil
+	return err
+}
+
+// publish publishes a payload, (re)connecting to the broker as needed. A
+// failed publish is retried once on a new connection.
+func (s *MQTTSink) publish(topic string, m *messageJSON) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			timeout := s.Timeout
+			if timeout == 0 {
+				timeout = DefaultMQTTTimeout
+			}
+			conn, err := dialMQTT(s.Broker, s.TLSConfig, s.ClientID, s.Username, s.Password, timeout)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		err := s.conn.publish(topic, payload, s.QoS, s.Retain)
+		if err == nil {
+			return nil
+		}
+		s.conn.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sinks
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttDisconnect = 14
+)
+
+// mqttConn is a minimal MQTT 3.1.1 client, supporting only what a publisher
+// needs: CONNECT, PUBLISH at QoS 0 or 1, and DISCONNECT.
+type mqttConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	packetID uint16
+	timeout  time.Duration
+}
+
+func dialMQTT(broker string, tlsConfig *tls.Config, clientID, username, password string, timeout time.Duration) (*mqttConn, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = d.Dial("tcp", withDefaultPort(u.Host, "1883"))
+	case "ssl", "tls", "mqtts":
+		conn, err = tls.DialWithDialer(d, "tcp", withDefaultPort(u.Host, "8883"), tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	if err := c.connect(clientID, username, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func withDefaultPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func (c *mqttConn) connect(clientID, username, password string) error {
+	flags := byte(0x02) // clean session
+	var payload []byte
+	payload = appendMQTTString(payload, clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = appendMQTTString(payload, username)
+	}
+	if password != "" {
+		flags |= 0x40
+		payload = appendMQTTString(payload, password)
+	}
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	body = append(body, 4, flags, 0, 0) // protocol level 4, no keep alive
+	body = append(body, payload...)
+	if err := c.write(mqttConnect<<4, body); err != nil {
+		return err
+	}
+	typ, resp, err := c.read()
+	if err != nil {
+		return err
+	}
+	if typ != mqttConnack || len(resp) != 2 {
+		return fmt.Errorf("mqtt: got packet type %d; want CONNACK", typ)
+	}
+	if resp[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused, return code %d", resp[1])
+	}
+	return nil
+}
+
+func (c *mqttConn) publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return fmt.Errorf("mqtt: got qos=%d; want 0 or 1", qos)
+	}
+	header := byte(mqttPublish<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	var body []byte
+	body = appendMQTTString(body, topic)
+	if qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		body = append(body, byte(c.packetID>>8), byte(c.packetID))
+	}
+	body = append(body, payload...)
+	if err := c.write(header, body); err != nil {
+		return err
+	}
+	if qos == 0 {
+		return nil
+	}
+	for {
+		typ, resp, err := c.read()
+		if err != nil {
+			return err
+		}
+		if typ == mqttPuback && len(resp) == 2 && uint16(resp[0])<<8|uint16(resp[1]) == c.packetID {
+			return nil
+		}
+	}
+}
+
+func (c *mqttConn) close() error {
+	c.write(mqttDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func (c *mqttConn) write(header byte, body []byte) error {
+	if len(body) > 268435455 {
+		return errors.New("mqtt: packet too large")
+	}
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+	if c.timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func (c *mqttConn) read() (byte, []byte, error) {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,138 @@
+// Package sinks contains events.Sink implementations that deliver stream
+// messages to external systems.
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+	"time"
+
+	"github.com/jcjlcodes/eth-eventlog/decode"
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// DefaultNoticeTemplate renders a *decode.Event.
+var DefaultNoticeTemplate = template.Must(template.New("notice").Parse(
+	`{{.Name}} in block {{.BlockNumber}} tx {{.TxHash.Hex}}{{range $k, $v := .Args}} {{$k}}={{$v}}{{end}}`))
+
+// DefaultCorrectionTemplate renders a *Correction.
+var DefaultCorrectionTemplate = template.Must(template.New("correction").Parse(
+	`Chain reorganization at block {{.Number}}, retracting {{len .Retracted}} notice(s):` +
+		`{{range .Retracted}}
+- {{.Name}} in block {{.BlockNumber}} tx {{.TxHash.Hex}}{{end}}`))
+
+// Correction is the template data of a correction notice, posted when a
+// Rollback removes blocks for which notices were posted.
+type Correction struct {
+	Number    uint64          // first block rolled back
+	Retracted []*decode.Event // posted events in the rolled back blocks
+}
+
+// Notifier is a Sink that posts a notice to a Webhook for every decoded event
+// accepted by Match. Posts are rate-limited to one per MinInterval; the
+// Notifier blocks rather than dropping notices.
+type Notifier struct {
+	Ctx     context.Context
+	Webhook Webhook
+	Decoder *decode.Decoder
+	Match   func(*decode.Event) bool // nil matches every event
+
+	Template           *template.Template // defaults to DefaultNoticeTemplate
+	CorrectionTemplate *template.Template // defaults to DefaultCorrectionTemplate
+	MinInterval        time.Duration
+
+	// RetainBlocks is how many blocks behind the stream position notices are
+	// remembered for corrections. Defaults to events.MaxEventlogSize.
+	RetainBlocks uint64
+
+	lastPost time.Time
+	posted   []*decode.Event
+}
+
+func (n *Notifier) Append(b *events.Block) error {
+	decoded, err := n.Decoder.DecodeBlock(b)
+	if err != nil {
+		return err
+	}
+	for _, de := range decoded {
+		if n.Match != nil && !n.Match(de) {
+			continue
+		}
+		tmpl := n.Template
+		if tmpl == nil {
+			tmpl = DefaultNoticeTemplate
+		}
+		if err := n.post(tmpl, de); err != nil {
+			return err
+		}
+		n.posted = append(n.posted, de)
+	}
+	n.forget(b.Number + 1)
+	return nil
+}
+
+func (n *Notifier) Rollback(number uint64) error {
+	var i int
+	for i = len(n.posted); i > 0; i-- {
+		if n.posted[i-1].BlockNumber < number {
+			break
+		}
+	}
+	retracted := n.posted[i:]
+	n.posted = n.posted[:i]
+	if len(retracted) == 0 {
+		return nil
+	}
+	tmpl := n.CorrectionTemplate
+	if tmpl == nil {
+		tmpl = DefaultCorrectionTemplate
+	}
+	return n.post(tmpl, &Correction{
+		Number:    number,
+		Retracted: retracted,
+	})
+}
+
+func (n *Notifier) SetNext(number uint64) error {
+	n.forget(number)
+	return nil
+}
+
+// forget drops posted notices too old to be rolled back.
+func (n *Notifier) forget(next uint64) {
+	retain := n.RetainBlocks
+	if retain == 0 {
+		retain = events.MaxEventlogSize
+	}
+	if next < retain {
+		return
+	}
+	var i int
+	for i = 0; i < len(n.posted); i++ {
+		if n.posted[i].BlockNumber >= next-retain {
+			break
+		}
+	}
+	n.posted = n.posted[i:]
+}
+
+func (n *Notifier) post(tmpl *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	ctx := n.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if wait := time.Until(n.lastPost.Add(n.MinInterval)); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	n.lastPost = time.Now()
+	return n.Webhook.Post(ctx, buf.String())
+}
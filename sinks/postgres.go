@@ -0,0 +1,181 @@
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// Postgres is a reference Sink storing events in a Postgres table, with
+// exactly-once delivery. It implements SequencedSink: wrapped in a WAL,
+// every message is applied in one transaction together with its WAL
+// sequence number, so a message redelivered after a crash is recognized
+// and skipped. The writes are idempotent by themselves too: an Append
+// replaces the rows of its block and any later ones, keyed by block number
+// and log index, so replaying messages converges to the same table.
+//
+// The program provides the database handle, and imports a Postgres driver
+// for it:
+//
+//	db, err := sql.Open("postgres", url) // with _ "github.com/lib/pq"
+//	pg, err := sinks.NewPostgres(ctx, db, "eventlog_")
+//	wal, err := sinks.OpenWAL(path, pg)
+type Postgres struct {
+	ctx    context.Context
+	db     *sql.DB
+	events string // table of events
+	state  string // table of the single row with seq and next_block
+}
+
+// NewPostgres creates the tables prefix+"events" and prefix+"state", if
+// they do not exist.
+func NewPostgres(ctx context.Context, db *sql.DB, prefix string) (*Postgres, error) {
+	if prefix != "" && !events.IdentifierRE.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid table prefix %q", prefix)
+	}
+	p := &Postgres{
+		ctx:    ctx,
+		db:     db,
+		events: prefix + "events",
+		state:  prefix + "state",
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + p.events + ` (
+			block_number BIGINT NOT NULL,
+			log_index BIGINT NOT NULL,
+			block_hash BYTEA NOT NULL,
+			address BYTEA NOT NULL,
+			topic0 BYTEA,
+			topic1 BYTEA,
+			topic2 BYTEA,
+			topic3 BYTEA,
+			data BYTEA NOT NULL,
+			tx_hash BYTEA NOT NULL,
+			tx_index BIGINT NOT NULL,
+			PRIMARY KEY (block_number, log_index)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + p.state + ` (
+			id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+			seq BIGINT NOT NULL,
+			next_block BIGINT NOT NULL
+		)`,
+		`INSERT INTO ` + p.state + ` (id, seq, next_block) VALUES (TRUE, 0, 0) ON CONFLICT DO NOTHING`,
+	}
+	for _, s := range stmts {
+		if _, err := db.ExecContext(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// LastSeq returns the sequence number of the last message applied with
+// ApplySeq.
+func (p *Postgres) LastSeq() (uint64, error) {
+	var seq int64
+	err := p.db.QueryRowContext(p.ctx, `SELECT seq FROM `+p.state).Scan(&seq)
+	return uint64(seq), err
+}
+
+// NextBlock returns the block after the last one applied, to resume
+// streaming from.
+func (p *Postgres) NextBlock() (uint64, error) {
+	var next int64
+	err := p.db.QueryRowContext(p.ctx, `SELECT next_block FROM `+p.state).Scan(&next)
+	return uint64(next), err
+}
+
+// ApplySeq applies a message and records its sequence number in one
+// transaction. Messages with a sequence number not above the recorded one
+// were applied before, and are skipped. A zero seq is not recorded.
+func (p *Postgres) ApplySeq(seq uint64, m *events.Message) error {
+	tx, err := p.db.BeginTx(p.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	var last int64
+	if err := tx.QueryRowContext(p.ctx, `SELECT seq FROM `+p.state+` FOR UPDATE`).Scan(&last); err != nil {
+		return err
+	}
+	if seq != 0 && seq <= uint64(last) {
+		return nil
+	}
+	if err := p.apply(tx, m); err != nil {
+		return err
+	}
+	if seq != 0 {
+		if _, err := tx.ExecContext(p.ctx, `UPDATE `+p.state+` SET seq = $1`, int64(seq)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) apply(tx *sql.Tx, m *events.Message) error {
+	var next uint64
+	switch m.Action {
+	case events.Append:
+		b := m.Block
+		if err := p.deleteFrom(tx, b.Number); err != nil {
+			return err
+		}
+		for i := range b.Events {
+			if err := p.insert(tx, &b.Events[i]); err != nil {
+				return err
+			}
+		}
+		next = b.Number + 1
+	case events.Rollback:
+		if err := p.deleteFrom(tx, m.Number); err != nil {
+			return err
+		}
+		next = m.Number
+	case events.SetNext:
+		next = m.Number
+	default:
+		return fmt.Errorf("unknown action %d", m.Action)
+	}
+	_, err := tx.ExecContext(p.ctx, `UPDATE `+p.state+` SET next_block = $1`, int64(next))
+	return err
+}
+
+func (p *Postgres) deleteFrom(tx *sql.Tx, n uint64) error {
+	_, err := tx.ExecContext(p.ctx, `DELETE FROM `+p.events+` WHERE block_number >= $1`, int64(n))
+	return err
+}
+
+func (p *Postgres) insert(tx *sql.Tx, e *events.Event) error {
+	if len(e.Topics) > 4 {
+		return fmt.Errorf("event %d/%d has %d topics; want at most 4", e.BlockNumber, e.Index, len(e.Topics))
+	}
+	var topics [4]interface{}
+	for i, t := range e.Topics {
+		topics[i] = t.Bytes()
+	}
+	data := e.Data
+	if data == nil {
+		data = []byte{}
+	}
+	_, err := tx.ExecContext(p.ctx, `INSERT INTO `+p.events+` (
+			block_number, log_index, block_hash, address, topic0, topic1, topic2, topic3, data, tx_hash, tx_index
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		int64(e.BlockNumber), int64(e.Index), e.BlockHash.Bytes(), e.Address.Bytes(),
+		topics[0], topics[1], topics[2], topics[3], data, e.TxHash.Bytes(), int64(e.TxIndex))
+	return err
+}
+
+func (p *Postgres) Append(b *events.Block) error {
+	return p.ApplySeq(0, &events.Message{Action: events.Append, Block: b})
+}
+
+func (p *Postgres) Rollback(n uint64) error {
+	return p.ApplySeq(0, &events.Message{Action: events.Rollback, Number: n})
+}
+
+func (p *Postgres) SetNext(n uint64) error {
+	return p.ApplySeq(0, &events.Message{Action: events.SetNext, Number: n})
+}
@@ -0,0 +1,225 @@
+package sinks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+)
+
+// DefaultSQLiteBatch is the default number of messages Import applies per
+// transaction.
+const DefaultSQLiteBatch = 1000
+
+// SQLite is a Sink storing events in SQLite tables, making captures
+// queryable with SQL. Every call is one transaction; ApplyBatch and Import
+// apply many messages per transaction, which is much faster. Like
+// Postgres, an Append replaces the rows of its block and any later ones,
+// so replaying messages, e.g. an interrupted Import, converges to the same
+// tables.
+//
+// The program provides the database handle, and imports an SQLite driver
+// for it:
+//
+//	db, err := sql.Open("sqlite3", path) // with _ "github.com/mattn/go-sqlite3"
+//	s, err := sinks.NewSQLite(ctx, db, "")
+//	n, err := s.Import(f, 0)
+type SQLite struct {
+	ctx    context.Context
+	db     *sql.DB
+	events string // table of events
+	blocks string // table of blocks with events
+	state  string // table of the single row with next_block
+}
+
+// NewSQLite creates the tables prefix+"events", prefix+"blocks" and
+// prefix+"state", if they do not exist.
+func NewSQLite(ctx context.Context, db *sql.DB, prefix string) (*SQLite, error) {
+	if prefix != "" && !events.IdentifierRE.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid table prefix %q", prefix)
+	}
+	s := &SQLite{
+		ctx:    ctx,
+		db:     db,
+		events: prefix + "events",
+		blocks: prefix + "blocks",
+		state:  prefix + "state",
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + s.events + ` (
+			block_number INTEGER NOT NULL,
+			log_index INTEGER NOT NULL,
+			block_hash BLOB NOT NULL,
+			address BLOB NOT NULL,
+			topic0 BLOB,
+			topic1 BLOB,
+			topic2 BLOB,
+			topic3 BLOB,
+			data BLOB NOT NULL,
+			tx_hash BLOB NOT NULL,
+			tx_index INTEGER NOT NULL,
+			PRIMARY KEY (block_number, log_index)
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + s.events + `_address ON ` + s.events + ` (address, block_number)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.blocks + ` (
+			number INTEGER PRIMARY KEY,
+			hash BLOB NOT NULL,
+			parent_hash BLOB,
+			time INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + s.state + ` (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			next_block INTEGER NOT NULL
+		)`,
+		`INSERT OR IGNORE INTO ` + s.state + ` (id, next_block) VALUES (1, 0)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NextBlock returns the block after the last one applied, to resume
+// streaming from.
+func (s *SQLite) NextBlock() (uint64, error) {
+	var next int64
+	err := s.db.QueryRowContext(s.ctx, `SELECT next_block FROM `+s.state).Scan(&next)
+	return uint64(next), err
+}
+
+// ApplyBatch applies messages in one transaction.
+func (s *SQLite) ApplyBatch(ms []*events.Message) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	insert, err := tx.PrepareContext(s.ctx, `INSERT INTO `+s.events+` (
+			block_number, log_index, block_hash, address, topic0, topic1, topic2, topic3, data, tx_hash, tx_index
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	next := int64(-1)
+	for _, m := range ms {
+		n, err := s.apply(tx, insert, m)
+		if err != nil {
+			return err
+		}
+		next = int64(n)
+	}
+	if next >= 0 {
+		if _, err := tx.ExecContext(s.ctx, `UPDATE `+s.state+` SET next_block = ?`, next); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// apply applies a message, returning the next block after it.
+func (s *SQLite) apply(tx *sql.Tx, insert *sql.Stmt, m *events.Message) (uint64, error) {
+	switch m.Action {
+	case events.Append:
+		b := m.Block
+		if err := s.deleteFrom(tx, b.Number); err != nil {
+			return 0, err
+		}
+		var parent interface{}
+		if b.ParentHash != (common.Hash{}) {
+			parent = b.ParentHash.Bytes()
+		}
+		if _, err := tx.ExecContext(s.ctx, `INSERT INTO `+s.blocks+` (number, hash, parent_hash, time) VALUES (?, ?, ?, ?)`,
+			int64(b.Number), b.Hash.Bytes(), parent, int64(b.Time)); err != nil {
+			return 0, err
+		}
+		for i := range b.Events {
+			if err := s.insert(insert, &b.Events[i]); err != nil {
+				return 0, err
+			}
+		}
+		return b.Number + 1, nil
+	case events.Rollback:
+		return m.Number, s.deleteFrom(tx, m.Number)
+	case events.SetNext:
+		return m.Number, nil
+	}
+	return 0, fmt.Errorf("unknown action %d", m.Action)
+}
+
+func (s *SQLite) deleteFrom(tx *sql.Tx, n uint64) error {
+	if _, err := tx.ExecContext(s.ctx, `DELETE FROM `+s.events+` WHERE block_number >= ?`, int64(n)); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(s.ctx, `DELETE FROM `+s.blocks+` WHERE number >= ?`, int64(n))
+	return err
+}
+
+func (s *SQLite) insert(insert *sql.Stmt, e *events.Event) error {
+	if len(e.Topics) > 4 {
+		return fmt.Errorf("event %d/%d has %d topics; want at most 4", e.BlockNumber, e.Index, len(e.Topics))
+	}
+	var topics [4]interface{}
+	for i, t := range e.Topics {
+		topics[i] = t.Bytes()
+	}
+	data := e.Data
+	if data == nil {
+		data = []byte{}
+	}
+	_, err := insert.ExecContext(s.ctx,
+		int64(e.BlockNumber), int64(e.Index), e.BlockHash.Bytes(), e.Address.Bytes(),
+		topics[0], topics[1], topics[2], topics[3], data, e.TxHash.Bytes(), int64(e.TxIndex))
+	return err
+}
+
+// Import applies a delimited proto stream, as written by a
+// events.DelimitedWriter, in transactions of batch messages
+// (DefaultSQLiteBatch if zero). Only one batch is held in memory. It
+// returns the number of messages applied.
+func (s *SQLite) Import(r io.Reader, batch int) (int, error) {
+	if batch <= 0 {
+		batch = DefaultSQLiteBatch
+	}
+	dr := events.NewDelimitedReader(r)
+	ms := make([]*events.Message, 0, batch)
+	total := 0
+	for {
+		m, err := dr.Next()
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		if m != nil {
+			ms = append(ms, m)
+		}
+		if len(ms) == batch || err == io.EOF && len(ms) > 0 {
+			if err := s.ApplyBatch(ms); err != nil {
+				return total, err
+			}
+			total += len(ms)
+			ms = ms[:0]
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+	}
+}
+
+func (s *SQLite) Append(b *events.Block) error {
+	return s.ApplyBatch([]*events.Message{{Action: events.Append, Block: b}})
+}
+
+func (s *SQLite) Rollback(n uint64) error {
+	return s.ApplyBatch([]*events.Message{{Action: events.Rollback, Number: n}})
+}
+
+func (s *SQLite) SetNext(n uint64) error {
+	return s.ApplyBatch([]*events.Message{{Action: events.SetNext, Number: n}})
+}
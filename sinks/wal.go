@@ -0,0 +1,247 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jcjlcodes/eth-eventlog/events"
+	epb "github.com/jcjlcodes/eth-eventlog/proto/events/v1"
+)
+
+const DefaultWALMaxSize = 64 << 20 // bytes
+
+// SequencedSink is implemented by sinks that store the WAL sequence number of
+// each message together with its effect, e.g. in the same database
+// transaction. The WAL then skips messages the sink already has, making
+// delivery effectively-once even if the WAL acknowledgement was lost.
+type SequencedSink interface {
+	ApplySeq(seq uint64, m *events.Message) error
+	LastSeq() (uint64, error)
+}
+
+// WAL is a Sink that writes every message to a write-ahead log file before
+// delivering it to the wrapped sink, and an acknowledgement after the sink
+// returns. When opened, it redelivers the messages that were logged but not
+// acknowledged, so no message is lost across crashes.
+//
+// Without a SequencedSink, delivery is at-least-once: a crash between
+// delivery and acknowledgement redelivers the message.
+type WAL struct {
+	// MaxSize is the file size after which the log is truncated, once all
+	// messages are acknowledged.
+	MaxSize int64
+
+	sink events.Sink
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+	seq  uint64 // last logged sequence number
+}
+
+// OpenWAL opens or creates a write-ahead log, and redelivers unacknowledged
+// messages to sink.
+func OpenWAL(path string, sink events.Sink) (*WAL, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{
+		MaxSize: DefaultWALMaxSize,
+		sink:    sink,
+		path:    path,
+		f:       f,
+	}
+	unacked, err := w.read()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.w = bufio.NewWriter(f)
+
+	var skipUntil uint64
+	if ss, ok := sink.(SequencedSink); ok {
+		if skipUntil, err = ss.LastSeq(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	// The sink may be ahead of a lost or truncated log; numbering from
+	// there keeps it from skipping new messages as already applied.
+	if skipUntil > w.seq {
+		w.seq = skipUntil
+	}
+	for _, e := range unacked {
+		if e.Seq > skipUntil {
+			m, err := events.MessageFromProto(e.Message)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			if err := w.deliver(e.Seq, m); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("redelivering message %d: %w", e.Seq, err)
+			}
+		}
+		if err := w.write(&epb.WALEntry{Ack: e.Seq}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// read scans the log, returning the unacknowledged entries. A partially
+// written entry at the end, left by a crash, is cut off.
+func (w *WAL) read() ([]*epb.WALEntry, error) {
+	r := bufio.NewReader(w.f)
+	var unacked []*epb.WALEntry
+	var offset int64
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			break
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			break
+		}
+		e := &epb.WALEntry{}
+		if err := proto.Unmarshal(buf, e); err != nil {
+			break
+		}
+		offset += int64(uvarintLen(n)) + int64(n)
+		if e.Message != nil {
+			w.seq = e.Seq
+			unacked = append(unacked, e)
+			continue
+		}
+		if e.Ack > w.seq {
+			w.seq = e.Ack
+		}
+		i := 0
+		for i < len(unacked) && unacked[i].Seq <= e.Ack {
+			i++
+		}
+		unacked = unacked[i:]
+	}
+	if err := w.f.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err := w.f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	w.size = offset
+	return unacked, nil
+}
+
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// encodeEntry returns an entry with its length prefix.
+func encodeEntry(e *epb.WALEntry) ([]byte, error) {
+	bs, err := proto.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var hdr [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(bs)))
+	return append(hdr[:n:n], bs...), nil
+}
+
+func (w *WAL) write(e *epb.WALEntry) error {
+	bs, err := encodeEntry(e)
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(bs); err != nil {
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	w.size += int64(len(bs))
+	return w.f.Sync()
+}
+
+func (w *WAL) deliver(seq uint64, m *events.Message) error {
+	if ss, ok := w.sink.(SequencedSink); ok {
+		return ss.ApplySeq(seq, m)
+	}
+	return events.Apply(w.sink, m)
+}
+
+// apply logs, delivers and acknowledges a message.
+func (w *WAL) apply(m *events.Message) error {
+	if w.f == nil {
+		return errors.New("wal is closed")
+	}
+	seq := w.seq + 1
+	if err := w.write(&epb.WALEntry{Seq: seq, Message: events.MessageToProto(m)}); err != nil {
+		return err
+	}
+	w.seq = seq
+	if err := w.deliver(seq, m); err != nil {
+		return err
+	}
+	if err := w.write(&epb.WALEntry{Ack: seq}); err != nil {
+		return err
+	}
+	if w.MaxSize > 0 && w.size > w.MaxSize {
+		return w.truncate()
+	}
+	return nil
+}
+
+// truncate replaces the log with one keeping only the last sequence number.
+// The new log is written to a temporary file and renamed over the old one,
+// so that a crash cannot leave an empty log.
+func (w *WAL) truncate() error {
+	bs, err := encodeEntry(&epb.WALEntry{Ack: w.seq})
+	if err != nil {
+		return err
+	}
+	if err := events.WriteFileAtomic(w.path, bs); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0644)
+	w.f.Close()
+	if err != nil {
+		w.f = nil
+		return err
+	}
+	w.f = f
+	w.w.Reset(f)
+	w.size = int64(len(bs))
+	return nil
+}
+
+func (w *WAL) Append(b *events.Block) error {
+	return w.apply(&events.Message{Action: events.Append, Block: b})
+}
+
+func (w *WAL) Rollback(n uint64) error {
+	return w.apply(&events.Message{Action: events.Rollback, Number: n})
+}
+
+func (w *WAL) SetNext(n uint64) error {
+	return w.apply(&events.Message{Action: events.SetNext, Number: n})
+}
+
+// Close closes the log file.
+func (w *WAL) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
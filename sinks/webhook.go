@@ -0,0 +1,90 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Webhook posts a text message to a chat service.
+type Webhook interface {
+	Post(ctx context.Context, text string) error
+}
+
+// DiscordWebhook posts to a Discord channel webhook URL.
+type DiscordWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *DiscordWebhook) Post(ctx context.Context, text string) error {
+	return postJSON(ctx, w.Client, w.URL, map[string]string{"content": text})
+}
+
+// SlackWebhook posts to a Slack incoming webhook URL.
+type SlackWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *SlackWebhook) Post(ctx context.Context, text string) error {
+	return postJSON(ctx, w.Client, w.URL, map[string]string{"text": text})
+}
+
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// TelegramWebhook posts to a Telegram chat using the Bot API.
+type TelegramWebhook struct {
+	BaseURL string // defaults to DefaultTelegramURL
+	Token   string
+	ChatID  string
+	Client  *http.Client
+}
+
+func (w *TelegramWebhook) Post(ctx context.Context, text string) error {
+	base := w.BaseURL
+	if base == "" {
+		base = DefaultTelegramURL
+	}
+	u := fmt.Sprintf("%s/bot%s/sendMessage", base, w.Token)
+	err := postJSON(ctx, w.Client, u, map[string]string{
+		"chat_id": w.ChatID,
+		"text":    text,
+	})
+	// The bot token is part of the URL, which net/http puts in its errors.
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = fmt.Sprintf("%s/bot<redacted>/sendMessage", base)
+	}
+	return err
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}